@@ -0,0 +1,75 @@
+// +build acceptance
+
+package acceptance
+
+import (
+	"context"
+	"fmt"
+	mailgun "github.com/mailgun/mailgun-go"
+	"os"
+	"testing"
+	"text/tabwriter"
+	"time"
+)
+
+func TestGetClickmap(t *testing.T) {
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	messageID := reqEnv(t, "MG_MESSAGE_ID")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+
+	entries, err := mg.GetClickmap(context.Background(), domain, messageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "URL\tTotalClicks\tUniqueClicks\t\n")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t\n", e.URL, e.TotalClicks, e.UniqueClicks)
+	}
+	tw.Flush()
+}
+
+func TestGetOpensBreakdown(t *testing.T) {
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	messageID := reqEnv(t, "MG_MESSAGE_ID")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+
+	b, err := mg.GetOpensBreakdown(context.Background(), domain, messageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mobile, desktop := b.MobileVsDesktop()
+	fmt.Printf("TestGetOpensBreakdown: %d opens, %d unique, %d mobile, %d desktop\n", b.Total, b.Unique, mobile, desktop)
+
+	tw := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Client\tOpens\t\n")
+	for client, n := range b.ByClient {
+		fmt.Fprintf(tw, "%s\t%d\t\n", client, n)
+	}
+	tw.Flush()
+}
+
+func TestGetClicksForURL(t *testing.T) {
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	link := reqEnv(t, "MG_CLICKED_URL")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+
+	stats, err := mg.GetClicksForURL(context.Background(), domain, link, mailgun.StatsOptions{
+		Start: time.Now().Add(-7 * 24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("TestGetClicksForURL: %d clicks, %d unique\n", stats.TotalClicks, stats.UniqueClicks)
+
+	tw := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "MessageID\tTotalClicks\tUniqueClicks\t\n")
+	for _, m := range stats.ByMessage {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t\n", m.MessageID, m.TotalClicks, m.UniqueClicks)
+	}
+	tw.Flush()
+}
@@ -33,12 +33,12 @@ func TestGetSingleBounce(t *testing.T) {
 	if err == nil {
 		t.Fatal("Did not expect a bounce to exist")
 	}
-	ure, ok := err.(*mailgun.UnexpectedResponseError)
+	apiErr, ok := mailgun.IsAPIError(err)
 	if !ok {
-		t.Fatal("Expected UnexpectedResponseError")
+		t.Fatal("Expected APIError")
 	}
-	if ure.Actual != 404 {
-		t.Fatalf("Expected 404 response code; got %d", ure.Actual)
+	if apiErr.StatusCode != 404 {
+		t.Fatalf("Expected 404 response code; got %d", apiErr.StatusCode)
 	}
 }
 
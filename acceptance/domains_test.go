@@ -3,6 +3,7 @@
 package acceptance
 
 import (
+	"context"
 	"crypto/rand"
 	"fmt"
 	"github.com/mailgun/mailgun-go"
@@ -53,12 +54,12 @@ func TestGetSingleDomainNotExist(t *testing.T) {
 	if err == nil {
 		t.Fatal("Did not expect a domain to exist")
 	}
-	ure, ok := err.(*mailgun.UnexpectedResponseError)
+	apiErr, ok := mailgun.IsAPIError(err)
 	if !ok {
-		t.Fatal("Expected UnexpectedResponseError")
+		t.Fatal("Expected APIError")
 	}
-	if ure.Actual != 404 {
-		t.Fatalf("Expected 404 response code; got %d", ure.Actual)
+	if apiErr.StatusCode != 404 {
+		t.Fatalf("Expected 404 response code; got %d", apiErr.StatusCode)
 	}
 }
 
@@ -81,6 +82,76 @@ func TestAddDeleteDomain(t *testing.T) {
 	}
 }
 
+func TestGetDomainPolicy(t *testing.T) {
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	policy, err := mg.GetDomainPolicy(context.Background(), domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("TestGetDomainPolicy: %#v\n", policy)
+}
+
+func TestUpdateDomainPolicy(t *testing.T) {
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	policy, err := mg.GetDomainPolicy(context.Background(), domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write the current settings back, so as to leave the domain as we found it.
+	err = mg.UpdateDomainPolicy(context.Background(), domain, mailgun.DomainPolicyUpdate{
+		ClickTracking:       policy.ClickTracking,
+		OpenTracking:        &policy.OpenTracking,
+		UnsubscribeTracking: &policy.UnsubscribeTracking,
+		ConnectionSettings:  &policy.ConnectionSettings,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetRateLimitStatus(t *testing.T) {
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	status, err := mg.GetRateLimitStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("TestGetRateLimitStatus: %#v\n", status)
+}
+
+func TestDiagnoseDelivery(t *testing.T) {
+	toUser := reqEnv(t, "MG_EMAIL_TO")
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	report, err := mg.DiagnoseDelivery(context.Background(), domain, "test@"+domain, toUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("TestDiagnoseDelivery: %#v\n", report)
+	for _, r := range report.Recommendations {
+		fmt.Printf("TestDiagnoseDelivery:   %s\n", r)
+	}
+}
+
+func TestGetDeliveryForecast(t *testing.T) {
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	forecast, err := mg.GetDeliveryForecast(context.Background(), domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("TestGetDeliveryForecast: %#v\n", forecast)
+	fmt.Printf("TestGetDeliveryForecast: ready to send at 90%% inbox rate: %v\n", forecast.IsReadyToSend(0.9))
+}
+
 // randomString generates a string of given length, but random content.
 // All content will be within the ASCII graphic character set.
 // (Implementation from Even Shaw's contribution on
@@ -3,6 +3,7 @@
 package acceptance
 
 import (
+	"context"
 	"github.com/mailgun/mailgun-go"
 	"testing"
 )
@@ -50,3 +51,16 @@ func TestParseAddresses(t *testing.T) {
 		t.Fatalf("Expected 1 address to be unparsable; got %d", len(unparsableAddresses))
 	}
 }
+
+func TestValidatePhone(t *testing.T) {
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_PUBLIC_API_KEY")
+	mg := mailgun.NewMailgun(domain, "", apiKey)
+	pv, err := mg.ValidatePhone(context.Background(), "+14155550123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pv.CountryCode != "US" {
+		t.Fatal("Expected a US number; got ", pv.CountryCode)
+	}
+}
@@ -3,6 +3,7 @@
 package acceptance
 
 import (
+	"context"
 	"fmt"
 	"github.com/mailgun/mailgun-go"
 	"os"
@@ -39,3 +40,24 @@ func TestEventIterator(t *testing.T) {
 		t.Fatal("Expected to be at the beginning")
 	}
 }
+
+func TestGetRecipientHistory(t *testing.T) {
+	toUser := reqEnv(t, "MG_EMAIL_TO")
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	events, err := mg.GetRecipientHistory(context.Background(), domain, toUser, mailgun.EventOptions{Limit: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) > 50 {
+		t.Fatalf("Expected at most 50 events; got %d", len(events))
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Event\tTimestamp\t")
+	for _, event := range events {
+		fmt.Fprintf(tw, "%s\t%v\t\n", event["event"], event["timestamp"])
+	}
+	tw.Flush()
+}
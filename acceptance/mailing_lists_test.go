@@ -3,8 +3,11 @@
 package acceptance
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	mailgun "github.com/mailgun/mailgun-go"
+	"strings"
 	"testing"
 )
 
@@ -203,3 +206,40 @@ func TestMailingLists(t *testing.T) {
 		t.Fatalf("Expected [%#v], Got [%#v]", newList, theList)
 	}
 }
+
+func TestExportMailingList(t *testing.T) {
+	mg, address := setup(t)
+	defer teardown(t, mg, address)
+
+	err := mg.CreateMember(true, address, mailgun.Member{
+		Address:    "joe@example.com",
+		Name:       "Joe Example",
+		Subscribed: mailgun.Subscribed,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = mg.ExportMailingList(context.Background(), address, mailgun.ExportCSV, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "joe@example.com,Joe Example,true") {
+		t.Fatalf("Expected Joe in the export; got:\n%s", buf.String())
+	}
+}
+
+func TestImportMailingList(t *testing.T) {
+	mg, address := setup(t)
+	defer teardown(t, mg, address)
+
+	csv := "email,name,subscribed,plan\njoe@example.com,Joe,yes,gold\n,Nobody,yes,\n"
+	result, err := mg.ImportMailingList(context.Background(), address, strings.NewReader(csv), mailgun.ImportOptions{Upsert: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 1 || result.Failed != 1 {
+		t.Fatalf("Expected 1 member added and 1 row failed; got %#v", result)
+	}
+}
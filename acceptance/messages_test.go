@@ -3,6 +3,7 @@
 package acceptance
 
 import (
+	"context"
 	"fmt"
 	mailgun "github.com/mailgun/mailgun-go"
 	"io/ioutil"
@@ -36,7 +37,7 @@ func TestSendLegacyPlain(t *testing.T) {
 	publicApiKey := reqEnv(t, "MG_PUBLIC_API_KEY")
 	mg := mailgun.NewMailgun(domain, apiKey, publicApiKey)
 	m := mailgun.NewMessage(fromUser, exampleSubject, exampleText, toUser)
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -51,7 +52,7 @@ func TestSendLegacyPlainWithTracking(t *testing.T) {
 	mg := mailgun.NewMailgun(domain, apiKey, publicApiKey)
 	m := mailgun.NewMessage(fromUser, exampleSubject, exampleText, toUser)
 	m.SetTracking(true)
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -66,7 +67,7 @@ func TestSendLegacyPlainAt(t *testing.T) {
 	mg := mailgun.NewMailgun(domain, apiKey, publicApiKey)
 	m := mailgun.NewMessage(fromUser, exampleSubject, exampleText, toUser)
 	m.SetDeliveryTime(time.Now().Add(5 * time.Minute))
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -81,7 +82,7 @@ func TestSendLegacyHtml(t *testing.T) {
 	mg := mailgun.NewMailgun(domain, apiKey, publicApiKey)
 	m := mailgun.NewMessage(fromUser, exampleSubject, exampleText, toUser)
 	m.SetHtml(exampleHtml)
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -96,7 +97,7 @@ func TestSendLegacyTracking(t *testing.T) {
 	mg := mailgun.NewMailgun(domain, apiKey, publicApiKey)
 	m := mailgun.NewMessage(fromUser, exampleSubject, exampleText+"Tracking!\n", toUser)
 	m.SetTracking(false)
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -113,7 +114,7 @@ func TestSendLegacyTag(t *testing.T) {
 	m.AddTag("FooTag")
 	m.AddTag("BarTag")
 	m.AddTag("BlortTag")
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -126,7 +127,7 @@ func TestSendLegacyMIME(t *testing.T) {
 	apiKey := reqEnv(t, "MG_API_KEY")
 	mg := mailgun.NewMailgun(domain, apiKey, "")
 	m := mailgun.NewMIMEMessage(ioutil.NopCloser(strings.NewReader(exampleMime)), toUser)
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -195,7 +196,7 @@ func TestSendMGPlain(t *testing.T) {
 	publicApiKey := reqEnv(t, "MG_PUBLIC_API_KEY")
 	mg := mailgun.NewMailgun(domain, apiKey, publicApiKey)
 	m := mg.NewMessage(fromUser, exampleSubject, exampleText, toUser)
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -210,7 +211,7 @@ func TestSendMGPlainWithTracking(t *testing.T) {
 	mg := mailgun.NewMailgun(domain, apiKey, publicApiKey)
 	m := mg.NewMessage(fromUser, exampleSubject, exampleText, toUser)
 	m.SetTracking(true)
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -225,7 +226,7 @@ func TestSendMGPlainAt(t *testing.T) {
 	mg := mailgun.NewMailgun(domain, apiKey, publicApiKey)
 	m := mg.NewMessage(fromUser, exampleSubject, exampleText, toUser)
 	m.SetDeliveryTime(time.Now().Add(5 * time.Minute))
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -240,7 +241,7 @@ func TestSendMGHtml(t *testing.T) {
 	mg := mailgun.NewMailgun(domain, apiKey, publicApiKey)
 	m := mg.NewMessage(fromUser, exampleSubject, exampleText, toUser)
 	m.SetHtml(exampleHtml)
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -255,7 +256,7 @@ func TestSendMGTracking(t *testing.T) {
 	mg := mailgun.NewMailgun(domain, apiKey, publicApiKey)
 	m := mg.NewMessage(fromUser, exampleSubject, exampleText+"Tracking!\n", toUser)
 	m.SetTracking(false)
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -272,7 +273,7 @@ func TestSendMGTag(t *testing.T) {
 	m.AddTag("FooTag")
 	m.AddTag("BarTag")
 	m.AddTag("BlortTag")
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -285,7 +286,7 @@ func TestSendMGMIME(t *testing.T) {
 	apiKey := reqEnv(t, "MG_API_KEY")
 	mg := mailgun.NewMailgun(domain, apiKey, "")
 	m := mg.NewMIMEMessage(ioutil.NopCloser(strings.NewReader(exampleMime)), toUser)
-	msg, id, err := mg.Send(m)
+	msg, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -322,8 +323,114 @@ func TestSendMGBatchRecipientVariables(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, _, err = mg.Send(m)
+	_, _, err = mg.Send(context.Background(), m)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
+
+func TestValidateAndSend(t *testing.T) {
+	toUser := reqEnv(t, "MG_EMAIL_TO")
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, toUser, "not-a-mailbox@example.invalid")
+	msg, id, err := mg.ValidateAndSend(context.Background(), m, mailgun.ValidationOptions{
+		MinRiskLevel: mailgun.RiskHigh,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("TestValidateAndSend:MSG(" + msg + "),ID(" + id + ")")
+}
+
+func TestCreateTransactionalMessage(t *testing.T) {
+	toUser := reqEnv(t, "MG_EMAIL_TO")
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	msg, id, err := mg.CreateTransactionalMessage(context.Background(), mailgun.TransactionalSpec{
+		Message:         mg.NewMessage(fromUser, exampleSubject, exampleText, toUser),
+		BypassOptOut:    true,
+		RequiredHeaders: map[string]string{"X-Message-Class": "transactional"},
+		ComplianceTag:   "compliance-test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("TestCreateTransactionalMessage:MSG(" + msg + "),ID(" + id + ")")
+}
+
+func TestSendVerificationMessage(t *testing.T) {
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	id, err := mg.SendVerificationMessage(context.Background(), domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("TestSendVerificationMessage:ID(" + id + ")")
+}
+
+func TestScheduleBatch(t *testing.T) {
+	toUser := reqEnv(t, "MG_EMAIL_TO")
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	messages := []*mailgun.Message{
+		mg.NewMessage(fromUser, exampleSubject, exampleText, toUser),
+		mg.NewMessage(fromUser, exampleSubject, exampleText+" (again)", toUser),
+	}
+	ids, err := mg.ScheduleBatch(context.Background(), messages, time.Now().Add(5*time.Minute), mailgun.BatchOptions{
+		OnError: func(i int, _ *mailgun.Message, err error) {
+			t.Errorf("Message %d failed: %v", i, err)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("TestScheduleBatch:IDs(%v)\n", ids)
+}
+
+func TestSendCalendarInvite(t *testing.T) {
+	toUser := reqEnv(t, "MG_EMAIL_TO")
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, toUser)
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	err := m.AttachCalendarInvite(mailgun.CalendarInvite{
+		UID:       randomString(16, "invite") + "@" + domain,
+		Summary:   exampleSubject,
+		Organizer: mailgun.CalendarPerson{Email: "joe@example.com"},
+		Attendees: []mailgun.CalendarPerson{{Email: toUser}},
+		Start:     start,
+		End:       start.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, id, err := mg.Send(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("TestSendCalendarInvite:MSG(" + msg + "),ID(" + id + ")")
+}
+
+func TestSendWithRecipientCC(t *testing.T) {
+	toUser := reqEnv(t, "MG_EMAIL_TO")
+	ccUser := reqEnv(t, "MG_EMAIL_CC")
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage(fromUser, exampleSubject, exampleText, toUser)
+	err := m.SetRecipientCC(toUser, ccUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, id, err := mg.Send(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("TestSendWithRecipientCC:MSG(" + msg + "),ID(" + id + ")")
+}
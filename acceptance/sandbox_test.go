@@ -0,0 +1,43 @@
+// +build acceptance
+
+package acceptance
+
+import (
+	"context"
+	"fmt"
+	"github.com/mailgun/mailgun-go"
+	"testing"
+)
+
+func TestSandboxAllowedRecipients(t *testing.T) {
+	domain := reqEnv(t, "MG_SANDBOX_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	ctx := context.Background()
+	email := randomString(16, "sandbox") + "@example.com"
+
+	err := mg.AddSandboxAllowedRecipient(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = mg.DeleteSandboxAllowedRecipient(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	recipients, err := mg.ListSandboxAllowedRecipients(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("TestSandboxAllowedRecipients: %v\n", recipients)
+
+	allowed, err := mg.IsSandboxRecipientAllowed(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed {
+		t.Fatal("Expected an unconfirmed recipient to be disallowed")
+	}
+}
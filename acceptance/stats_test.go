@@ -3,11 +3,13 @@
 package acceptance
 
 import (
+	"context"
 	"fmt"
 	mailgun "github.com/mailgun/mailgun-go"
 	"os"
 	"testing"
 	"text/tabwriter"
+	"time"
 )
 
 func TestGetStats(t *testing.T) {
@@ -38,3 +40,23 @@ func TestDeleteTag(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestGetAggregateStats(t *testing.T) {
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "", mailgun.WithConcurrencyLimit(2))
+
+	stats, err := mg.GetAggregateStats(context.Background(), []string{domain}, mailgun.StatsOptions{
+		Start: time.Now().Add(-7 * 24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Domain\tAccepted\tDelivered\tFailed\tOpened\tClicked\t\n")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t\n", s.Domain, s.Accepted, s.Delivered, s.TemporaryFailures+s.PermanentFailures, s.Opened, s.Clicked)
+	}
+	tw.Flush()
+}
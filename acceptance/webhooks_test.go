@@ -3,6 +3,8 @@
 package acceptance
 
 import (
+	"context"
+	"fmt"
 	"github.com/mailgun/mailgun-go"
 	"testing"
 )
@@ -64,3 +66,15 @@ func TestWebhookCRUD(t *testing.T) {
 		t.Fatalf("Expected http://api.example.com, got %#v", hooks["deliver"])
 	}
 }
+
+func TestGetDomainWebhooks(t *testing.T) {
+	domain := reqEnv(t, "MG_DOMAIN")
+	apiKey := reqEnv(t, "MG_API_KEY")
+	mg := mailgun.NewMailgun(domain, apiKey, "")
+	config, err := mg.GetDomainWebhooks(context.Background(), domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("TestGetDomainWebhooks: %#v\n", config)
+	fmt.Printf("TestGetDomainWebhooks: all URLs %v\n", config.AllURLs())
+}
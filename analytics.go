@@ -0,0 +1,222 @@
+package mailgun
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// A LinkHeatmapEntry summarizes the clicks recorded against a single link of a message.
+// URL holds the normalized form of the link, which GetClickmap uses to group clicks together;
+// OriginalURL holds the link exactly as Mailgun first reported it.
+// TotalClicks counts every click on the link, while UniqueClicks counts each recipient only once.
+type LinkHeatmapEntry struct {
+	URL          string
+	OriginalURL  string
+	TotalClicks  int
+	UniqueClicks int
+}
+
+// CTR computes the click-through rate of the link:
+// the fraction of the message's totalRecipients who clicked on it at least once.
+// If totalRecipients isn't positive, CTR returns 0.
+func (e LinkHeatmapEntry) CTR(totalRecipients int) float64 {
+	if totalRecipients <= 0 {
+		return 0
+	}
+	return float64(e.UniqueClicks) / float64(totalRecipients)
+}
+
+// GetClickmap retrieves every click recorded against the indicated message, and tallies them by link.
+// The messageID parameter takes the message ID returned by Send; angle brackets are optional.
+// Entries appear in descending order of total clicks.
+// Note that clicks are only recorded if click tracking was enabled for the message.
+func (mg *MailgunImpl) GetClickmap(ctx context.Context, domain, messageID string) ([]LinkHeatmapEntry, error) {
+	opts := GetEventsOptions{
+		Filter: map[string]string{
+			"event":      "clicked",
+			"message-id": strings.Trim(messageID, "<>"),
+		},
+	}
+
+	var entries []LinkHeatmapEntry
+	index := make(map[string]int)
+	clickers := make(map[string]map[string]bool)
+	err := mg.forEachEvent(ctx, domain, opts, func(e Event) bool {
+		link := eventString(e, "url")
+		if link == "" {
+			return true
+		}
+		key := normalizeURL(link)
+		i, ok := index[key]
+		if !ok {
+			i = len(entries)
+			index[key] = i
+			entries = append(entries, LinkHeatmapEntry{URL: key, OriginalURL: link})
+			clickers[key] = make(map[string]bool)
+		}
+		entries[i].TotalClicks++
+		recipient := strings.ToLower(eventString(e, "recipient"))
+		if !clickers[key][recipient] {
+			clickers[key][recipient] = true
+			entries[i].UniqueClicks++
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].TotalClicks > entries[j].TotalClicks
+	})
+	return entries, nil
+}
+
+// URLClickStats summarizes the clicks a single link received across all messages sent through a domain.
+// TotalClicks counts every click on the link, while UniqueClicks counts each recipient only once.
+// ByMessage breaks the clicks down by message, in descending order of total clicks.
+type URLClickStats struct {
+	URL          string
+	TotalClicks  int
+	UniqueClicks int
+	ByMessage    []MessageClickCount
+}
+
+// A MessageClickCount tallies the clicks on a link within a single message.
+type MessageClickCount struct {
+	MessageID    string
+	TotalClicks  int
+	UniqueClicks int
+}
+
+// GetClicksForURL retrieves every click recorded against the given link within the period given by opts,
+// and tallies them by message.
+// Links are compared after normalization, so differences in the case of the scheme or host,
+// or in the fragment, do not matter.
+// As Mailgun cannot filter events by link, this function reads every click event in the period;
+// keep the period short for busy domains.
+func (mg *MailgunImpl) GetClicksForURL(ctx context.Context, domain, link string, opts StatsOptions) (*URLClickStats, error) {
+	eventOpts := GetEventsOptions{
+		Begin:  opts.Start,
+		End:    opts.End,
+		Filter: map[string]string{"event": "clicked"},
+	}
+
+	key := normalizeURL(link)
+	stats := &URLClickStats{URL: key}
+	index := make(map[string]int)
+	clickers := make(map[string]bool)
+	messageClickers := make(map[string]map[string]bool)
+	err := mg.forEachEvent(ctx, domain, eventOpts, func(e Event) bool {
+		if normalizeURL(eventString(e, "url")) != key {
+			return true
+		}
+		recipient := strings.ToLower(eventString(e, "recipient"))
+		stats.TotalClicks++
+		if !clickers[recipient] {
+			clickers[recipient] = true
+			stats.UniqueClicks++
+		}
+
+		id := eventString(e, "message", "headers", "message-id")
+		i, ok := index[id]
+		if !ok {
+			i = len(stats.ByMessage)
+			index[id] = i
+			stats.ByMessage = append(stats.ByMessage, MessageClickCount{MessageID: id})
+			messageClickers[id] = make(map[string]bool)
+		}
+		stats.ByMessage[i].TotalClicks++
+		if !messageClickers[id][recipient] {
+			messageClickers[id][recipient] = true
+			stats.ByMessage[i].UniqueClicks++
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(stats.ByMessage, func(i, j int) bool {
+		return stats.ByMessage[i].TotalClicks > stats.ByMessage[j].TotalClicks
+	})
+	return stats, nil
+}
+
+// An OpensBreakdown summarizes the opens recorded against a single message.
+// Total counts every open, while Unique counts each recipient only once.
+// ByDevice, ByClient, and ByCountry count every open by the device type, e-mail client,
+// and country Mailgun reported for it; opens lacking this information count as "unknown".
+type OpensBreakdown struct {
+	Total     int
+	Unique    int
+	ByDevice  map[string]int
+	ByClient  map[string]int
+	ByCountry map[string]int
+}
+
+// MobileVsDesktop splits the opens of a message between mobile devices, which include both phones and tablets,
+// and desktops.  Opens on other or unknown devices count towards neither.
+func (b *OpensBreakdown) MobileVsDesktop() (mobile, desktop int) {
+	return b.ByDevice["mobile"] + b.ByDevice["tablet"], b.ByDevice["desktop"]
+}
+
+// GetOpensBreakdown retrieves every open recorded against the indicated message,
+// and tallies them by device, client, and country.
+// The messageID parameter takes the message ID returned by Send; angle brackets are optional.
+// Note that opens are only recorded if open tracking was enabled for the message.
+func (mg *MailgunImpl) GetOpensBreakdown(ctx context.Context, domain, messageID string) (*OpensBreakdown, error) {
+	opts := GetEventsOptions{
+		Filter: map[string]string{
+			"event":      "opened",
+			"message-id": strings.Trim(messageID, "<>"),
+		},
+	}
+
+	b := &OpensBreakdown{
+		ByDevice:  make(map[string]int),
+		ByClient:  make(map[string]int),
+		ByCountry: make(map[string]int),
+	}
+	openers := make(map[string]bool)
+	err := mg.forEachEvent(ctx, domain, opts, func(e Event) bool {
+		b.Total++
+		recipient := strings.ToLower(eventString(e, "recipient"))
+		if !openers[recipient] {
+			openers[recipient] = true
+			b.Unique++
+		}
+		b.ByDevice[orUnknown(eventString(e, "client-info", "device-type"))]++
+		b.ByClient[orUnknown(eventString(e, "client-info", "client-name"))]++
+		b.ByCountry[orUnknown(eventString(e, "geolocation", "country"))]++
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// orUnknown substitutes "unknown" for a missing event attribute.
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// normalizeURL lower-cases the scheme and host of a link, and drops its fragment,
+// so that trivially different spellings of the same link compare equal.
+// Links which cannot be parsed are returned unchanged.
+func normalizeURL(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,162 @@
+package mailgun
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// BatchOptions controls how SendBatch and ScheduleBatch handle a batch of messages.
+// OnError, if set, is called for each message which fails to send, along with its index in the batch;
+// the rest of the batch goes out regardless.
+// If OnError is nil, failures are instead combined into the error returned for the batch.
+type BatchOptions struct {
+	OnError func(index int, m *Message, err error)
+}
+
+// SendBatch sends each of the messages in turn.
+// It returns the message IDs in the same order as the messages; failed messages get an empty ID.
+// A failure to send one message does not prevent the others from going out.
+// If the context is cancelled, SendBatch stops early, and returns the IDs gathered so far along with the context's error.
+func (mg *MailgunImpl) SendBatch(ctx context.Context, messages []*Message, opts BatchOptions) ([]string, error) {
+	ids := make([]string, len(messages))
+	var errs []error
+	for i, m := range messages {
+		if err := ctx.Err(); err != nil {
+			return ids, err
+		}
+		_, id, err := mg.Send(ctx, m)
+		if err != nil {
+			if opts.OnError != nil {
+				opts.OnError(i, m, err)
+			} else {
+				errs = append(errs, fmt.Errorf("message %d: %v", i, err))
+			}
+			continue
+		}
+		ids[i] = id
+	}
+	return ids, combineErrors(errs)
+}
+
+// ScheduleBatch arranges for all the messages to be delivered at the same time, then sends them as SendBatch does.
+// Mailgun limits how far into the future a message may be scheduled; consult its documentation for details.
+func (mg *MailgunImpl) ScheduleBatch(ctx context.Context, messages []*Message, scheduledFor time.Time, opts BatchOptions) ([]string, error) {
+	if scheduledFor.IsZero() {
+		return nil, fmt.Errorf("no delivery time given for the batch")
+	}
+	for _, m := range messages {
+		m.SetDeliveryTime(scheduledFor)
+	}
+	return mg.SendBatch(ctx, messages, opts)
+}
+
+// BatchResult reports how one chunk of a BatchSend went.
+// Chunk gives the index of the chunk, counting from zero, in the order the message's recipients were added.
+// MessageID holds the ID Mailgun assigned the chunk's message if it was sent, and Error the reason if it was not.
+type BatchResult struct {
+	Chunk     int
+	MessageID string
+	Error     error
+}
+
+// BatchSend works as BatchSendContext, without a context to cancel the batch by.
+func (mg *MailgunImpl) BatchSend(m *Message, chunkSize int) ([]BatchResult, error) {
+	return mg.BatchSendContext(context.Background(), m, chunkSize)
+}
+
+// BatchSendContext sends a message to more recipients than Mailgun accepts in one API call,
+// splitting its To: recipients into chunks of at most chunkSize, and sending a copy of the message to each chunk.
+// A chunkSize of zero or less, or above MaxNumberOfRecipients, is taken as MaxNumberOfRecipients.
+// Each copy keeps the recipient variables and per-recipient CCs of its own recipients;
+// Cc: and Bcc: recipients are copied on every chunk, and count toward its size,
+// so chunks take fewer To: recipients where the message has them.
+// A message with so many Cc: and Bcc: recipients that no To: recipient fits alongside them
+// is refused with a *TooManyRecipientsError.
+//
+// It returns a result for every chunk, in order.
+// A chunk which fails to send does not prevent the others from going out; the failures are also
+// combined into the error returned.
+// If the context is cancelled, BatchSendContext stops early,
+// and returns the results gathered so far along with the context's error.
+func (mg *MailgunImpl) BatchSendContext(ctx context.Context, m *Message, chunkSize int) ([]BatchResult, error) {
+	if m == nil {
+		return nil, ErrNilMessage
+	}
+	if len(m.to) == 0 {
+		return nil, ErrNoRecipients
+	}
+	if chunkSize <= 0 || chunkSize > MaxNumberOfRecipients {
+		chunkSize = MaxNumberOfRecipients
+	}
+	copies := m.specific.recipientCount()
+	if copies >= MaxNumberOfRecipients {
+		return nil, &TooManyRecipientsError{Count: copies + 1}
+	}
+	if chunkSize > MaxNumberOfRecipients-copies {
+		chunkSize = MaxNumberOfRecipients - copies
+	}
+
+	// Every chunk reads the attachments, and a MIME message's body, anew; read them just once.
+	attachments, err := bufferReaderAttachments(m.readerAttachments)
+	if err != nil {
+		return nil, err
+	}
+	inlines, err := bufferReaderAttachments(m.readerInlines)
+	if err != nil {
+		return nil, err
+	}
+	var mimeBody []byte
+	if mm, ok := m.specific.(*mimeMessage); ok && mm.body != nil {
+		mimeBody, err = ioutil.ReadAll(mm.body)
+		mm.body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var results []BatchResult
+	var errs []error
+	for chunk, start := 0, 0; start < len(m.to); chunk, start = chunk+1, start+chunkSize {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		end := start + chunkSize
+		if end > len(m.to) {
+			end = len(m.to)
+		}
+		c := chunkMessage(m, m.to[start:end])
+		c.readerAttachments = attachments.readers()
+		c.readerInlines = inlines.readers()
+		if mm, ok := c.specific.(*mimeMessage); ok && mimeBody != nil {
+			mm.body = ioutil.NopCloser(bytes.NewReader(mimeBody))
+		}
+
+		_, id, err := mg.Send(ctx, c)
+		results = append(results, BatchResult{Chunk: chunk, MessageID: id, Error: err})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("chunk %d: %v", chunk, err))
+		}
+	}
+	return results, combineErrors(errs)
+}
+
+// chunkMessage copies the message for sending to some of its To: recipients alone,
+// keeping only the recipient variables and per-recipient CCs which apply to them.
+func chunkMessage(m *Message, to []string) *Message {
+	c := m.Clone()
+	c.to = copyStrings(to)
+	for recipient := range c.recipientVariables {
+		if !containsString(c.to, recipient) && !c.specific.isCopied(recipient) {
+			delete(c.recipientVariables, recipient)
+		}
+	}
+	for recipient := range c.recipientCCs {
+		if !containsString(c.to, recipient) {
+			delete(c.recipientCCs, recipient)
+		}
+	}
+	return c
+}
@@ -1,6 +1,10 @@
 package mailgun
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
 	"strconv"
 	"time"
 )
@@ -10,11 +14,12 @@ import (
 // Code provides the SMTP error code causing the bounce,
 // while Error provides a human readable reason why.
 // CreatedAt provides the time at which Mailgun detected the bounce.
+// Mailgun reports Code as either a number or a string; use GetCode to read it either way.
 type Bounce struct {
-	CreatedAt string      `json:"created_at"`
-	code      interface{} `json:"code"`
+	CreatedAt string      `json:"created_at,omitempty"`
+	Code      interface{} `json:"code,omitempty"`
 	Address   string      `json:"address"`
-	Error     string      `json:"error"`
+	Error     string      `json:"error,omitempty"`
 }
 
 type bounceEnvelope struct {
@@ -36,9 +41,11 @@ func (i Bounce) GetCreatedAt() (t time.Time, err error) {
 // returned as a string or as an integer.  This method overcomes a protocol
 // bug in the Mailgun API.
 func (b Bounce) GetCode() (int, error) {
-	switch c := b.code.(type) {
+	switch c := b.Code.(type) {
 	case int:
 		return c, nil
+	case float64:
+		return int(c), nil
 	case string:
 		return strconv.Atoi(c)
 	default:
@@ -59,7 +66,7 @@ func (m *MailgunImpl) GetBounces(limit, skip int) (int, []Bounce, error) {
 		r.addParameter("skip", strconv.Itoa(skip))
 	}
 
-	r.setClient(m.Client())
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 
 	var response bounceEnvelope
@@ -74,7 +81,7 @@ func (m *MailgunImpl) GetBounces(limit, skip int) (int, []Bounce, error) {
 // GetSingleBounce retrieves a single bounce record, if any exist, for the given recipient address.
 func (m *MailgunImpl) GetSingleBounce(address string) (Bounce, error) {
 	r := newHTTPRequest(generateApiUrl(m, bouncesEndpoint) + "/" + address)
-	r.setClient(m.Client())
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 
 	var response singleBounceEnvelope
@@ -100,7 +107,7 @@ func (m *MailgunImpl) GetSingleBounce(address string) (Bounce, error) {
 // code will report as a number.
 func (m *MailgunImpl) AddBounce(address, code, error string) error {
 	r := newHTTPRequest(generateApiUrl(m, bouncesEndpoint))
-	r.setClient(m.Client())
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 
 	payload := newUrlEncodedPayload()
@@ -115,10 +122,82 @@ func (m *MailgunImpl) AddBounce(address, code, error string) error {
 	return err
 }
 
+// ImportBounces files many bounce reports at once, posting them to Mailgun as JSON
+// in batches of up to 1000, the most Mailgun accepts per call.
+// Each bounce needs at least its Address set; CreatedAt, if set, must be in RFC-2822 form.
+// If a batch fails, the bounces of earlier batches remain filed.
+func (m *MailgunImpl) ImportBounces(ctx context.Context, bounces []Bounce) error {
+	for start := 0; start < len(bounces); start += maxImportBatchSize {
+		end := start + maxImportBatchSize
+		if end > len(bounces) {
+			end = len(bounces)
+		}
+		if err := importSuppressions(ctx, m, bouncesEndpoint, bounces[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importSuppressions posts a batch of entries to one of the client domain's suppression lists.
+func importSuppressions(ctx context.Context, m *MailgunImpl, endpoint string, batch interface{}) error {
+	r := newHTTPRequest(generateApiUrl(m, endpoint))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	payload, err := newJSONPayload(batch)
+	if err != nil {
+		return err
+	}
+	_, err = makePostRequest(r, payload)
+	return err
+}
+
+// ExportBounces downloads the named domain's bounces as CSV, asking Mailgun for text/csv.
+// The CSV streams from the connection as the reader is read; the caller must close the reader returned.
+func (m *MailgunImpl) ExportBounces(ctx context.Context, domain string) (io.ReadCloser, error) {
+	return exportSuppressions(ctx, m, domain, bouncesEndpoint)
+}
+
+// WriteBouncesCSV works as ExportBounces, but copies the CSV into w, such as a file or an HTTP response.
+func (m *MailgunImpl) WriteBouncesCSV(ctx context.Context, domain string, w io.Writer) error {
+	return writeSuppressionsCSV(ctx, m, domain, bouncesEndpoint, w)
+}
+
+// exportSuppressions downloads one of the named domain's suppression lists as CSV.
+// An error results if Mailgun answers with JSON instead, as it does where it doesn't offer the list as CSV.
+func exportSuppressions(ctx context.Context, m *MailgunImpl, domain, endpoint string) (io.ReadCloser, error) {
+	r := newHTTPRequest(generateApiUrlWithDomain(m, domain, endpoint))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	r.addHeader("Accept", "text/csv")
+	response, err := getStreamResponse(r)
+	if err != nil {
+		return nil, err
+	}
+	if mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type")); mediaType == "application/json" {
+		response.Body.Close()
+		return nil, fmt.Errorf("%s: expected CSV; got JSON", endpoint)
+	}
+	return response.Body, nil
+}
+
+// writeSuppressionsCSV copies one of the named domain's suppression lists, as CSV, into w.
+func writeSuppressionsCSV(ctx context.Context, m *MailgunImpl, domain, endpoint string, w io.Writer) error {
+	body, err := exportSuppressions(ctx, m, domain, endpoint)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	_, err = io.Copy(w, body)
+	return err
+}
+
 // DeleteBounce removes all bounces associted with the provided e-mail address.
 func (m *MailgunImpl) DeleteBounce(address string) error {
 	r := newHTTPRequest(generateApiUrl(m, bouncesEndpoint) + "/" + address)
-	r.setClient(m.Client())
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 	_, err := makeDeleteRequest(r)
 	return err
@@ -0,0 +1,152 @@
+package mailgun
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A MessageBuilder assembles a message step by step, as its fluent methods direct,
+// checking each step as it goes, and reporting every problem found at once when Build is called:
+//
+//	m, err := mailgun.NewMessageBuilder("me@example.com", "Hello").
+//		To("you@example.com").
+//		Text("Hello world!").
+//		Tag("welcome").
+//		Build()
+//	if err != nil {
+//		// err lists every problem with the message.
+//	}
+//
+// This suits messages built from forms or generated code, whose errors are best reported together.
+type MessageBuilder struct {
+	m    *Message
+	errs []error
+}
+
+// NewMessageBuilder starts a message from the given sender, with the given subject.
+func NewMessageBuilder(from, subject string) *MessageBuilder {
+	b := &MessageBuilder{m: &Message{specific: &plainMessage{from: from, subject: subject}}}
+	if strings.TrimSpace(from) == "" {
+		b.errs = append(b.errs, ErrNoSender)
+	}
+	return b
+}
+
+// fail records a problem with the message, if there is one.
+func (b *MessageBuilder) fail(err error) {
+	if err != nil {
+		b.errs = append(b.errs, err)
+	}
+}
+
+// To adds To: recipients to the message.
+func (b *MessageBuilder) To(recipients ...string) *MessageBuilder {
+	for _, r := range recipients {
+		if strings.TrimSpace(r) == "" {
+			b.fail(ErrEmptyRecipient)
+			continue
+		}
+		b.m.to = append(b.m.to, r)
+	}
+	return b
+}
+
+// CC adds Cc: recipients to the message.
+func (b *MessageBuilder) CC(recipients ...string) *MessageBuilder {
+	for _, r := range recipients {
+		if strings.TrimSpace(r) == "" {
+			b.fail(fmt.Errorf("message has an empty Cc: address"))
+			continue
+		}
+		b.m.AddCC(r)
+	}
+	return b
+}
+
+// BCC adds Bcc: recipients to the message.
+func (b *MessageBuilder) BCC(recipients ...string) *MessageBuilder {
+	for _, r := range recipients {
+		if strings.TrimSpace(r) == "" {
+			b.fail(fmt.Errorf("message has an empty Bcc: address"))
+			continue
+		}
+		b.m.AddBCC(r)
+	}
+	return b
+}
+
+// Text sets the plain-text body of the message.
+func (b *MessageBuilder) Text(text string) *MessageBuilder {
+	b.m.specific.(*plainMessage).text = text
+	return b
+}
+
+// HTML sets the HTML body of the message.
+func (b *MessageBuilder) HTML(html string) *MessageBuilder {
+	b.m.SetHtml(html)
+	return b
+}
+
+// Tag attaches tags to the message, as AddTag does.
+func (b *MessageBuilder) Tag(tags ...string) *MessageBuilder {
+	for _, t := range tags {
+		if strings.TrimSpace(t) == "" {
+			b.fail(ErrEmptyTag)
+			continue
+		}
+		b.fail(b.m.AddTag(t))
+	}
+	return b
+}
+
+// Header adds a custom MIME header to the message, as AddHeader does.
+func (b *MessageBuilder) Header(name, value string) *MessageBuilder {
+	if strings.TrimSpace(name) == "" {
+		b.fail(fmt.Errorf("message has a header without a name"))
+		return b
+	}
+	b.m.AddHeader(name, value)
+	return b
+}
+
+// Variable associates a variable with the message, as AddVariable does.
+func (b *MessageBuilder) Variable(name string, value interface{}) *MessageBuilder {
+	if err := b.m.AddVariable(name, value); err != nil {
+		b.fail(fmt.Errorf("message variable %s: %v", name, err))
+	}
+	return b
+}
+
+// Attachment attaches a file to the message from memory, as AddAttachmentFromBytes does.
+func (b *MessageBuilder) Attachment(filename, contentType string, data []byte) *MessageBuilder {
+	if strings.TrimSpace(filename) == "" {
+		b.fail(fmt.Errorf("message has an attachment without a filename"))
+		return b
+	}
+	b.m.AddAttachmentFromBytes(filename, contentType, data)
+	return b
+}
+
+// Build returns the message, checked as Send would check it.
+// If any step had problems, or Send would refuse the finished message, Build returns the problems together instead,
+// in the order they were found; Send's check reports only the first problem it finds.
+func (b *MessageBuilder) Build() (*Message, error) {
+	errs := b.errs
+	if err := validateMessage(b.m); err != nil && !containsError(errs, err) {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, combineErrors(errs)
+	}
+	return b.m, nil
+}
+
+// containsError reports whether the list already holds the given error.
+func containsError(errs []error, err error) bool {
+	for _, e := range errs {
+		if e == err {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package mailgun
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// Calendar invites may use one of these methods, as defined by RFC 5546.
+// CalendarRequest invites the attendees to an event, or updates an event they've already been invited to.
+// CalendarCancel cancels an event.
+// CalendarReply answers an invitation on behalf of an attendee.
+const (
+	CalendarRequest = "REQUEST"
+	CalendarCancel  = "CANCEL"
+	CalendarReply   = "REPLY"
+)
+
+// A CalendarPerson identifies the organizer or an attendee of an event.
+// Name is optional.
+type CalendarPerson struct {
+	Name  string
+	Email string
+}
+
+// A CalendarInvite describes an event to send as an iCalendar (RFC 5545) attachment.
+//
+// UID identifies the event; reuse it when sending updates or cancellations of the same event.
+// Start and End bound the event.
+// Timezone, if set, names the IANA time zone (e.g., "America/New_York") in which to express Start and End;
+// otherwise, they're expressed in UTC.
+// Method must be one of CalendarRequest, CalendarCancel, or CalendarReply; if empty, CalendarRequest is assumed.
+type CalendarInvite struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Organizer   CalendarPerson
+	Attendees   []CalendarPerson
+	Start, End  time.Time
+	Timezone    string
+	Method      string
+}
+
+// AttachCalendarInvite renders the invite as an invite.ics file, and attaches it to the message
+// with a text/calendar content type, so that mail clients offer to add the event to the recipient's calendar.
+// An error results if the invite lacks a UID, organizer, or valid time span, or names an unknown method or time zone.
+func (m *Message) AttachCalendarInvite(invite CalendarInvite) error {
+	ics, err := invite.render(time.Now())
+	if err != nil {
+		return err
+	}
+	m.readerAttachments = append(m.readerAttachments, ReaderAttachment{
+		Filename:    "invite.ics",
+		ReadCloser:  ioutil.NopCloser(bytes.NewReader(ics)),
+		ContentType: fmt.Sprintf("text/calendar; charset=utf-8; method=%s", invite.method()),
+	})
+	return nil
+}
+
+func (invite CalendarInvite) method() string {
+	if invite.Method == "" {
+		return CalendarRequest
+	}
+	return invite.Method
+}
+
+// render serializes the invite as an iCalendar object, stamped with the given time.
+func (invite CalendarInvite) render(stamp time.Time) ([]byte, error) {
+	method := invite.method()
+	switch method {
+	case CalendarRequest, CalendarCancel, CalendarReply:
+	default:
+		return nil, fmt.Errorf("unknown calendar method %q", invite.Method)
+	}
+	if invite.UID == "" {
+		return nil, fmt.Errorf("calendar invite has no UID")
+	}
+	if invite.Organizer.Email == "" {
+		return nil, fmt.Errorf("calendar invite has no organizer")
+	}
+	if invite.Start.IsZero() || !invite.End.After(invite.Start) {
+		return nil, fmt.Errorf("calendar invite must end after it starts")
+	}
+	formatTime := func(name string, t time.Time) string {
+		return fmt.Sprintf("%s:%s", name, t.UTC().Format("20060102T150405Z"))
+	}
+	if invite.Timezone != "" {
+		loc, err := time.LoadLocation(invite.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		formatTime = func(name string, t time.Time) string {
+			return fmt.Sprintf("%s;TZID=%s:%s", name, invite.Timezone, t.In(loc).Format("20060102T150405"))
+		}
+	}
+
+	var b bytes.Buffer
+	line := func(s string) {
+		b.WriteString(foldCalendarLine(s))
+		b.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("PRODID:-//Mailgun//mailgun-go//EN")
+	line("VERSION:2.0")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:" + method)
+	line("BEGIN:VEVENT")
+	line("UID:" + escapeCalendarText(invite.UID))
+	line("DTSTAMP:" + stamp.UTC().Format("20060102T150405Z"))
+	line(formatTime("DTSTART", invite.Start))
+	line(formatTime("DTEND", invite.End))
+	if invite.Summary != "" {
+		line("SUMMARY:" + escapeCalendarText(invite.Summary))
+	}
+	if invite.Description != "" {
+		line("DESCRIPTION:" + escapeCalendarText(invite.Description))
+	}
+	if invite.Location != "" {
+		line("LOCATION:" + escapeCalendarText(invite.Location))
+	}
+	line("ORGANIZER" + calendarPersonParams(invite.Organizer) + ":mailto:" + invite.Organizer.Email)
+	for _, a := range invite.Attendees {
+		line("ATTENDEE" + calendarPersonParams(a) + ";ROLE=REQ-PARTICIPANT;RSVP=TRUE:mailto:" + a.Email)
+	}
+	if method == CalendarCancel {
+		line("STATUS:CANCELLED")
+	} else {
+		line("STATUS:CONFIRMED")
+	}
+	line("END:VEVENT")
+	line("END:VCALENDAR")
+	return b.Bytes(), nil
+}
+
+// calendarPersonParams renders the CN parameter for a person, if they have a name.
+func calendarPersonParams(p CalendarPerson) string {
+	if p.Name == "" {
+		return ""
+	}
+	return `;CN="` + strings.Replace(p.Name, `"`, "'", -1) + `"`
+}
+
+// escapeCalendarText escapes a TEXT value as RFC 5545 section 3.3.11 requires.
+func escapeCalendarText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// foldCalendarLine breaks a content line into lines of at most 75 octets, as RFC 5545 section 3.1 requires.
+// Continuation lines begin with a space.  Lines are only broken between UTF-8 sequences.
+func foldCalendarLine(s string) string {
+	const limit = 75
+	var b bytes.Buffer
+	width := 0
+	for _, r := range s {
+		n := len(string(r))
+		if width+n > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	return b.String()
+}
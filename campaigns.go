@@ -1,7 +1,14 @@
 package mailgun
 
+import "context"
+
+import "strconv"
+
 // Campaigns have been deprecated since development work on this SDK commenced.
 // Please refer to http://documentation.mailgun.com/api_reference .
+//
+// SubmittedCount, OpenedCount, and ClickedCount give the number of messages sent as part of the campaign,
+// and the number of opens and clicks they drew; the other counts break down what became of the messages.
 type Campaign struct {
 	Id                string `json:"id"`
 	Name              string `json:"name"`
@@ -21,12 +28,35 @@ type campaignsEnvelope struct {
 	Items      []Campaign `json:"items"`
 }
 
+type campaignEnvelope struct {
+	Campaign Campaign `json:"campaign"`
+}
+
 // Campaigns have been deprecated since development work on this SDK commenced.
 // Please refer to http://documentation.mailgun.com/api_reference .
 func (m *MailgunImpl) GetCampaigns() (int, []Campaign, error) {
-	r := newHTTPRequest(generateApiUrl(m, campaignsEndpoint))
-	r.setClient(m.Client())
+	return m.getCampaigns(context.Background(), m.Domain(), DefaultLimit, DefaultSkip)
+}
+
+// GetDomainCampaigns works as GetCampaigns, but for the named domain,
+// and pages through the campaigns as limit and skip direct.
+// Pass DefaultLimit and DefaultSkip to rely on Mailgun's paging defaults.
+func (m *MailgunImpl) GetDomainCampaigns(ctx context.Context, domain string, limit, skip int) ([]Campaign, error) {
+	_, campaigns, err := m.getCampaigns(ctx, domain, limit, skip)
+	return campaigns, err
+}
+
+func (m *MailgunImpl) getCampaigns(ctx context.Context, domain string, limit, skip int) (int, []Campaign, error) {
+	r := newHTTPRequest(generateApiUrlWithDomain(m, domain, campaignsEndpoint))
+	r.setContext(ctx)
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	if limit != DefaultLimit {
+		r.addParameter("limit", strconv.Itoa(limit))
+	}
+	if skip != DefaultSkip {
+		r.addParameter("skip", strconv.Itoa(skip))
+	}
 
 	var envelope campaignsEnvelope
 	err := getResponseFromJSON(r, &envelope)
@@ -36,11 +66,43 @@ func (m *MailgunImpl) GetCampaigns() (int, []Campaign, error) {
 	return envelope.TotalCount, envelope.Items, nil
 }
 
+// GetDomainCampaign retrieves a single campaign of the named domain.
+func (m *MailgunImpl) GetDomainCampaign(ctx context.Context, domain, id string) (*Campaign, error) {
+	if id == "" {
+		return nil, ErrEmptyParam
+	}
+	r := newHTTPRequest(generateApiUrlWithDomain(m, domain, campaignsEndpoint) + "/" + id)
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+
+	var c Campaign
+	if err := getResponseFromJSON(r, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
 // Campaigns have been deprecated since development work on this SDK commenced.
 // Please refer to http://documentation.mailgun.com/api_reference .
 func (m *MailgunImpl) CreateCampaign(name, id string) error {
-	r := newHTTPRequest(generateApiUrl(m, campaignsEndpoint))
-	r.setClient(m.Client())
+	_, err := m.createCampaign(context.Background(), m.Domain(), name, id)
+	return err
+}
+
+// CreateDomainCampaign creates a campaign for the named domain, leaving Mailgun to choose its ID.
+// It returns the campaign as created.
+func (m *MailgunImpl) CreateDomainCampaign(ctx context.Context, domain, name string) (*Campaign, error) {
+	return m.createCampaign(ctx, domain, name, "")
+}
+
+func (m *MailgunImpl) createCampaign(ctx context.Context, domain, name, id string) (*Campaign, error) {
+	if name == "" {
+		return nil, ErrEmptyParam
+	}
+	r := newHTTPRequest(generateApiUrlWithDomain(m, domain, campaignsEndpoint))
+	r.setContext(ctx)
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 
 	payload := newUrlEncodedPayload()
@@ -48,15 +110,33 @@ func (m *MailgunImpl) CreateCampaign(name, id string) error {
 	if id != "" {
 		payload.addValue("id", id)
 	}
-	_, err := makePostRequest(r, payload)
-	return err
+	var envelope campaignEnvelope
+	if err := postResponseFromJSON(r, payload, &envelope); err != nil {
+		return nil, err
+	}
+	return &envelope.Campaign, nil
 }
 
 // Campaigns have been deprecated since development work on this SDK commenced.
 // Please refer to http://documentation.mailgun.com/api_reference .
 func (m *MailgunImpl) UpdateCampaign(oldId, name, newId string) error {
-	r := newHTTPRequest(generateApiUrl(m, campaignsEndpoint) + "/" + oldId)
-	r.setClient(m.Client())
+	_, err := m.updateCampaign(context.Background(), m.Domain(), oldId, name, newId)
+	return err
+}
+
+// UpdateDomainCampaign renames a campaign of the named domain, keeping its ID.
+// It returns the campaign as updated.
+func (m *MailgunImpl) UpdateDomainCampaign(ctx context.Context, domain, id, name string) (*Campaign, error) {
+	return m.updateCampaign(ctx, domain, id, name, "")
+}
+
+func (m *MailgunImpl) updateCampaign(ctx context.Context, domain, oldId, name, newId string) (*Campaign, error) {
+	if oldId == "" {
+		return nil, ErrEmptyParam
+	}
+	r := newHTTPRequest(generateApiUrlWithDomain(m, domain, campaignsEndpoint) + "/" + oldId)
+	r.setContext(ctx)
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 
 	payload := newUrlEncodedPayload()
@@ -64,15 +144,27 @@ func (m *MailgunImpl) UpdateCampaign(oldId, name, newId string) error {
 	if newId != "" {
 		payload.addValue("id", newId)
 	}
-	_, err := makePostRequest(r, payload)
-	return err
+	var envelope campaignEnvelope
+	if err := postResponseFromJSON(r, payload, &envelope); err != nil {
+		return nil, err
+	}
+	return &envelope.Campaign, nil
 }
 
 // Campaigns have been deprecated since development work on this SDK commenced.
 // Please refer to http://documentation.mailgun.com/api_reference .
 func (m *MailgunImpl) DeleteCampaign(id string) error {
-	r := newHTTPRequest(generateApiUrl(m, campaignsEndpoint) + "/" + id)
-	r.setClient(m.Client())
+	return m.DeleteDomainCampaign(context.Background(), m.Domain(), id)
+}
+
+// DeleteDomainCampaign removes a campaign of the named domain.
+func (m *MailgunImpl) DeleteDomainCampaign(ctx context.Context, domain, id string) error {
+	if id == "" {
+		return ErrEmptyParam
+	}
+	r := newHTTPRequest(generateApiUrlWithDomain(m, domain, campaignsEndpoint) + "/" + id)
+	r.setContext(ctx)
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 	_, err := makeDeleteRequest(r)
 	return err
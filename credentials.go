@@ -1,24 +1,30 @@
 package mailgun
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 )
 
 // A Credential structure describes a principle allowed to send or receive mail at the domain.
+// Mailbox gives the full address the principle sends as; Mailgun never reports the password.
 type Credential struct {
 	CreatedAt string `json:"created_at"`
 	Login     string `json:"login"`
+	Mailbox   string `json:"mailbox"`
 	Password  string `json:"password"`
 }
 
+// credentialsPageSize is the number of credentials GetDomainCredentials fetches per API call.
+const credentialsPageSize = 100
+
 // ErrEmptyParam results occur when a required parameter is missing.
 var ErrEmptyParam = fmt.Errorf("empty or illegal parameter")
 
 // GetCredentials returns the (possibly zero-length) list of credentials associated with your domain.
 func (mg *MailgunImpl) GetCredentials(limit, skip int) (int, []Credential, error) {
 	r := newHTTPRequest(generateCredentialsUrl(mg, ""))
-	r.setClient(mg.Client())
+	r.setClient(mg)
 	if limit != DefaultLimit {
 		r.addParameter("limit", strconv.Itoa(limit))
 	}
@@ -37,13 +43,44 @@ func (mg *MailgunImpl) GetCredentials(limit, skip int) (int, []Credential, error
 	return envelope.TotalCount, envelope.Items, nil
 }
 
+// GetDomainCredentials returns every credential associated with the named domain,
+// fetching as many pages as it takes.
+func (mg *MailgunImpl) GetDomainCredentials(ctx context.Context, domain string) ([]Credential, error) {
+	var credentials []Credential
+	for {
+		r := newHTTPRequest(generateCredentialsUrlWithDomain(mg, domain, ""))
+		r.setContext(ctx)
+		r.setClient(mg)
+		r.addParameter("limit", strconv.Itoa(credentialsPageSize))
+		r.addParameter("skip", strconv.Itoa(len(credentials)))
+		r.setBasicAuth(basicAuthUser, mg.ApiKey())
+		var envelope struct {
+			TotalCount int          `json:"total_count"`
+			Items      []Credential `json:"items"`
+		}
+		if err := getResponseFromJSON(r, &envelope); err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, envelope.Items...)
+		if len(envelope.Items) < credentialsPageSize || len(credentials) >= envelope.TotalCount {
+			return credentials, nil
+		}
+	}
+}
+
 // CreateCredential attempts to create associate a new principle with your domain.
 func (mg *MailgunImpl) CreateCredential(login, password string) error {
+	return mg.CreateDomainCredential(context.Background(), mg.Domain(), login, password)
+}
+
+// CreateDomainCredential works as CreateCredential, but for the named domain.
+func (mg *MailgunImpl) CreateDomainCredential(ctx context.Context, domain, login, password string) error {
 	if (login == "") || (password == "") {
 		return ErrEmptyParam
 	}
-	r := newHTTPRequest(generateCredentialsUrl(mg, ""))
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generateCredentialsUrlWithDomain(mg, domain, ""))
+	r.setContext(ctx)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newUrlEncodedPayload()
 	p.addValue("login", login)
@@ -54,25 +91,37 @@ func (mg *MailgunImpl) CreateCredential(login, password string) error {
 
 // ChangeCredentialPassword attempts to alter the indicated credential's password.
 func (mg *MailgunImpl) ChangeCredentialPassword(id, password string) error {
-	if (id == "") || (password == "") {
+	return mg.ChangeDomainCredentialPassword(context.Background(), mg.Domain(), id, password)
+}
+
+// ChangeDomainCredentialPassword works as ChangeCredentialPassword, but for the named domain.
+func (mg *MailgunImpl) ChangeDomainCredentialPassword(ctx context.Context, domain, login, newPassword string) error {
+	if (login == "") || (newPassword == "") {
 		return ErrEmptyParam
 	}
-	r := newHTTPRequest(generateCredentialsUrl(mg, id))
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generateCredentialsUrlWithDomain(mg, domain, login))
+	r.setContext(ctx)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newUrlEncodedPayload()
-	p.addValue("password", password)
+	p.addValue("password", newPassword)
 	_, err := makePutRequest(r, p)
 	return err
 }
 
 // DeleteCredential attempts to remove the indicated principle from the domain.
 func (mg *MailgunImpl) DeleteCredential(id string) error {
-	if id == "" {
+	return mg.DeleteDomainCredential(context.Background(), mg.Domain(), id)
+}
+
+// DeleteDomainCredential works as DeleteCredential, but for the named domain.
+func (mg *MailgunImpl) DeleteDomainCredential(ctx context.Context, domain, login string) error {
+	if login == "" {
 		return ErrEmptyParam
 	}
-	r := newHTTPRequest(generateCredentialsUrl(mg, id))
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generateCredentialsUrlWithDomain(mg, domain, login))
+	r.setContext(ctx)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	_, err := makeDeleteRequest(r)
 	return err
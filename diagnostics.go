@@ -0,0 +1,122 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+)
+
+// A DiagnosticReport gathers the results of the checks DiagnoseDelivery makes.
+//
+// DNSValid reports whether Mailgun has verified all of the domain's sending DNS records.
+// DomainActive reports whether the domain is active, rather than unverified or disabled;
+// Mailgun disables domains whose sending reputation has suffered.
+// IsSuppressed reports whether the recipient appears on any of the domain's suppression lists,
+// in which case SuppressionReason explains which.
+// TestSendID and TestSendError report the outcome of sending a message in test mode.
+// Recommendations suggests what to fix, in plain English; it's empty if no problems were found.
+type DiagnosticReport struct {
+	DNSValid          bool
+	DomainActive      bool
+	IsSuppressed      bool
+	SuppressionReason string
+	TestSendID        string
+	TestSendError     error
+	Recommendations   []string
+}
+
+// DiagnoseDelivery checks, in a single call, the most common reasons why mail from the domain fails to reach a recipient:
+// unverified DNS records, an inactive domain, a suppressed recipient, and rejected sends.
+// The test send goes from the from address to the to address in test mode, so nothing is delivered.
+// A failed test send is recorded in the report; DiagnoseDelivery only returns an error if one of its checks couldn't be made.
+func (mg *MailgunImpl) DiagnoseDelivery(ctx context.Context, domain, from, to string) (*DiagnosticReport, error) {
+	envelope, err := mg.getSingleDomain(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	report := &DiagnosticReport{
+		DNSValid:     allRecordsValid(envelope.SendingDNSRecords),
+		DomainActive: envelope.Domain.State == "active",
+	}
+	if !report.DNSValid {
+		report.recommend("Add the sending DNS records shown for " + domain + " in the Mailgun control panel, then verify the domain.")
+	}
+	if !report.DomainActive {
+		report.recommend("The domain is " + envelope.Domain.State + "; contact Mailgun support to reactivate it.")
+	}
+
+	bounce, found, err := mg.getSuppression(ctx, domain, bouncesEndpoint, to)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		var b singleBounceEnvelope
+		if err := bounce.parseFromJSON(&b); err != nil {
+			return nil, err
+		}
+		report.suppress("bounced: "+b.Bounce.Error, "Remove "+to+" from the bounce list once the problem with their mailbox is resolved.")
+	}
+	if _, found, err = mg.getSuppression(ctx, domain, unsubscribesEndpoint, to); err != nil {
+		return nil, err
+	}
+	if found {
+		report.suppress("unsubscribed", to+" has unsubscribed; only they can opt back in.")
+	}
+	if _, found, err = mg.getSuppression(ctx, domain, complaintsEndpoint, to); err != nil {
+		return nil, err
+	}
+	if found {
+		report.suppress("complained", to+" has marked mail from "+domain+" as spam; do not remove the complaint without their consent.")
+	}
+
+	message := mg.NewMessage(from, "Mailgun delivery diagnostics", "This message tests delivery from "+domain+".", to)
+	message.EnableTestMode()
+	_, report.TestSendID, report.TestSendError = mg.sendFromDomain(ctx, domain, message, "")
+	if report.TestSendError != nil {
+		report.recommend("Mailgun rejected the test message: " + report.TestSendError.Error())
+	}
+	return report, nil
+}
+
+// recommend adds a recommendation to the report.
+func (r *DiagnosticReport) recommend(recommendation string) {
+	r.Recommendations = append(r.Recommendations, recommendation)
+}
+
+// suppress records that the recipient is suppressed for the given reason.
+// If the recipient appears on several suppression lists, the reasons accumulate.
+func (r *DiagnosticReport) suppress(reason, recommendation string) {
+	if r.IsSuppressed {
+		r.SuppressionReason += "; " + reason
+	} else {
+		r.SuppressionReason = reason
+	}
+	r.IsSuppressed = true
+	r.recommend(recommendation)
+}
+
+// getSuppression looks up the address in one of the domain's suppression lists.
+// The found result reports whether the address appears in the list; if so, the response holds its entry.
+func (mg *MailgunImpl) getSuppression(ctx context.Context, domain, endpoint, address string) (*httpResponse, bool, error) {
+	r := newHTTPRequest(generateApiUrlWithDomain(mg, domain, endpoint) + "/" + address)
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	response, err := makeGetRequest(r)
+	if apiErr, ok := IsAPIError(err); ok && apiErr.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return response, true, nil
+}
+
+// allRecordsValid returns true if, and only if, Mailgun has verified every one of the DNS records.
+func allRecordsValid(records []DNSRecord) bool {
+	for _, rec := range records {
+		if rec.Valid != "valid" {
+			return false
+		}
+	}
+	return len(records) > 0
+}
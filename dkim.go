@@ -0,0 +1,87 @@
+package mailgun
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrNoDKIMRecord results when Mailgun lists no DKIM record among a domain's sending DNS records.
+var ErrNoDKIMRecord = errors.New("domain has no DKIM record")
+
+// DKIMInfo describes the key a domain signs its mail with.
+// Selector names the key, and so the DNS record publishing it, selector._domainkey.domain;
+// PublicKey holds the key itself, as published in the record's p= tag.
+// Valid reports whether Mailgun found the record in DNS as it expects it.
+type DKIMInfo struct {
+	Selector  string
+	PublicKey string
+	Valid     bool
+}
+
+// GetDomainDKIM retrieves the DKIM key the named domain signs its mail with, from the domain's sending DNS records.
+// ErrNoDKIMRecord results if there is none.
+// This concerns the domain as a whole; a message's own SetDKIM setting only chooses whether it is signed.
+func (m *MailgunImpl) GetDomainDKIM(ctx context.Context, domain string) (*DKIMInfo, error) {
+	envelope, err := m.getSingleDomain(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range envelope.SendingDNSRecords {
+		i := strings.Index(rec.Name, "._domainkey.")
+		if rec.RecordType != "TXT" || i < 0 {
+			continue
+		}
+		return &DKIMInfo{
+			Selector:  rec.Name[:i],
+			PublicKey: dkimTag(rec.Value, "p"),
+			Valid:     rec.Valid == "valid",
+		}, nil
+	}
+	return nil, ErrNoDKIMRecord
+}
+
+// RotateDKIMKey has Mailgun replace the named domain's DKIM key with a fresh one, returning the domain's key afterward.
+// Mailgun keeps signing with the old key until the new one's DNS record validates,
+// so the key returned may still be the old one; publish the new record as GetDomainDKIM reports it.
+func (m *MailgunImpl) RotateDKIMKey(ctx context.Context, domain string) (*DKIMInfo, error) {
+	r := newHTTPRequest(generateV1ApiUrl(m, "dkim_management/domains") + "/" + domain + "/rotate")
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	if _, err := makePostRequest(r, newUrlEncodedPayload()); err != nil {
+		return nil, err
+	}
+	return m.GetDomainDKIM(ctx, domain)
+}
+
+// SetDKIMSelector changes the selector the named domain's DKIM key is published under,
+// as when rotating keys by hand: publish the new record first, then switch the selector to it.
+func (m *MailgunImpl) SetDKIMSelector(ctx context.Context, domain, selector string) error {
+	if selector == "" {
+		return ErrEmptyParam
+	}
+	p := newUrlEncodedPayload()
+	p.addValue("dkim_selector", selector)
+	return m.putDomainSetting(ctx, domain, "/dkim_selector", p)
+}
+
+// SetDKIMAuthority chooses whose DKIM key signs the named domain's mail.
+// If self is true, the domain signs with a key of its own; otherwise, it shares the key of its parent domain.
+func (m *MailgunImpl) SetDKIMAuthority(ctx context.Context, domain string, self bool) error {
+	p := newUrlEncodedPayload()
+	p.addValue("self", strconv.FormatBool(self))
+	return m.putDomainSetting(ctx, domain, "/dkim_authority", p)
+}
+
+// dkimTag extracts the value of the named tag from a DKIM record, such as "k=rsa; p=MIGfMA0...".
+func dkimTag(record, name string) string {
+	for _, tag := range strings.Split(record, ";") {
+		kv := strings.SplitN(strings.TrimSpace(tag), "=", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == name {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+	return ""
+}
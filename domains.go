@@ -1,7 +1,11 @@
 package mailgun
 
 import (
+	"context"
+	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,8 +25,18 @@ const (
 	Delete   = "delete"
 )
 
+// SpamActionDisabled, SpamActionBlock, and SpamActionTag name the spam actions
+// GetDomainSpamAction reports and SetDomainSpamAction accepts, in Mailgun's current terms.
+// SpamActionBlock has Mailgun refuse what it perceives to be spam, as Delete once did.
+const (
+	SpamActionDisabled = Disabled
+	SpamActionBlock    = "block"
+	SpamActionTag      = Tag
+)
+
 // A Domain structure holds information about a domain used when sending mail.
 // The SpamAction field must be one of Tag, Disabled, or Delete.
+// The State field reports whether Mailgun considers the domain "active", "unverified", or "disabled".
 type Domain struct {
 	CreatedAt    string `json:"created_at"`
 	SMTPLogin    string `json:"smtp_login"`
@@ -30,6 +44,7 @@ type Domain struct {
 	SMTPPassword string `json:"smtp_password"`
 	Wildcard     bool   `json:"wildcard"`
 	SpamAction   string `json:"spam_action"`
+	State        string `json:"state"`
 }
 
 // DNSRecord structures describe intended records to properly configure your domain for use with Mailgun.
@@ -42,6 +57,84 @@ type DNSRecord struct {
 	Value      string `json:"value"`
 }
 
+// DomainConnection describes how Mailgun connects to recipients' mail servers when delivering mail for a domain.
+// RequireTLS instructs Mailgun to deliver only over TLS-protected connections.
+// SkipVerification instructs Mailgun to accept such connections even if the server's certificate can't be verified.
+type DomainConnection struct {
+	RequireTLS       bool `json:"require_tls"`
+	SkipVerification bool `json:"skip_verification"`
+}
+
+// A DomainPolicy collects the compliance-relevant settings of a domain into a single structure.
+// SpamAction reports how Mailgun treats inbound spam (see Tag, Disabled, and Delete).
+// DKIMEnabled reports whether Mailgun has verified the domain's DKIM record.
+// TrackingEnabled reports whether any of click, open, or unsubscribe tracking is active.
+// ClickTracking will be one of "yes", "no", or "htmlonly".
+// ConnectionSettings reports the TLS requirements for outbound deliveries.
+type DomainPolicy struct {
+	SpamAction          string
+	DKIMEnabled         bool
+	TrackingEnabled     bool
+	ClickTracking       string
+	OpenTracking        bool
+	UnsubscribeTracking bool
+	ConnectionSettings  DomainConnection
+}
+
+// A DomainPolicyUpdate lists changes to make to a domain's policy; see UpdateDomainPolicy.
+// Leave a field at its zero value to keep the corresponding setting as it is.
+// SpamAction, if set, must be one SetDomainSpamAction accepts, and ClickTracking one of "yes", "no", or "htmlonly".
+// As ConnectionSettings replaces both TLS settings at once, it's only consulted if not nil.
+type DomainPolicyUpdate struct {
+	SpamAction          string
+	ClickTracking       string
+	OpenTracking        *bool
+	UnsubscribeTracking *bool
+	ConnectionSettings  *DomainConnection
+}
+
+// DomainTracking holds the tracking settings of a domain, by kind of tracking,
+// as GetDomainTracking reports them; messages sent without tracking options of their own follow these.
+// Click tracking is special, as it may be active for HTML parts only;
+// Mailgun then reports "htmlonly" instead of a boolean, so use ClickTracking to read it.
+// When unsubscribe tracking is active, Mailgun appends HTMLFooter and TextFooter to the HTML and text parts of each message.
+type DomainTracking struct {
+	Click struct {
+		Active interface{} `json:"active"`
+	} `json:"click"`
+	Open struct {
+		Active bool `json:"active"`
+	} `json:"open"`
+	Unsubscribe struct {
+		Active     bool   `json:"active"`
+		HTMLFooter string `json:"html_footer"`
+		TextFooter string `json:"text_footer"`
+	} `json:"unsubscribe"`
+}
+
+// ClickTracking renders the click tracking setting as "yes", "no", or "htmlonly".
+func (dt DomainTracking) ClickTracking() string {
+	switch v := dt.Click.Active.(type) {
+	case bool:
+		return yesNo(v)
+	case string:
+		return v
+	default:
+		return yesNo(false)
+	}
+}
+
+// DomainOptions sets up a domain as AddDomain creates it.
+// SMTPPassword provides an access credential for the domain; if left blank, Mailgun generates one.
+// SpamAction must be one of Delete, Tag, or Disabled; if left blank, Mailgun assumes Disabled.
+// Wildcard instructs Mailgun to treat all subdomains of the domain uniformly,
+// rather than as different domains.
+type DomainOptions struct {
+	SMTPPassword string
+	SpamAction   string
+	Wildcard     bool
+}
+
 type domainsEnvelope struct {
 	TotalCount int      `json:"total_count"`
 	Items      []Domain `json:"items"`
@@ -66,8 +159,8 @@ func (d Domain) GetCreatedAt() (t time.Time, err error) {
 // Note that zero items and a zero-length slice do not necessarily imply an error occurred.
 // Except for the error itself, all results are undefined in the event of an error.
 func (m *MailgunImpl) GetDomains(limit, skip int) (int, []Domain, error) {
-	r := newHTTPRequest(generatePublicApiUrl(domainsEndpoint))
-	r.setClient(m.Client())
+	r := newHTTPRequest(generatePublicApiUrl(m, domainsEndpoint))
+	r.setClient(m)
 	if limit != DefaultLimit {
 		r.addParameter("limit", strconv.Itoa(limit))
 	}
@@ -86,12 +179,18 @@ func (m *MailgunImpl) GetDomains(limit, skip int) (int, []Domain, error) {
 
 // Retrieve detailed information about the named domain.
 func (m *MailgunImpl) GetSingleDomain(domain string) (Domain, []DNSRecord, []DNSRecord, error) {
-	r := newHTTPRequest(generatePublicApiUrl(domainsEndpoint) + "/" + domain)
-	r.setClient(m.Client())
+	envelope, err := m.getSingleDomain(context.Background(), domain)
+	return envelope.Domain, envelope.ReceivingDNSRecords, envelope.SendingDNSRecords, err
+}
+
+func (m *MailgunImpl) getSingleDomain(ctx context.Context, domain string) (singleDomainEnvelope, error) {
+	r := newHTTPRequest(generatePublicApiUrl(m, domainsEndpoint) + "/" + domain)
+	r.setContext(ctx)
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 	var envelope singleDomainEnvelope
 	err := getResponseFromJSON(r, &envelope)
-	return envelope.Domain, envelope.ReceivingDNSRecords, envelope.SendingDNSRecords, err
+	return envelope, err
 }
 
 // CreateDomain instructs Mailgun to create a new domain for your account.
@@ -101,8 +200,8 @@ func (m *MailgunImpl) GetSingleDomain(domain string) (Domain, []DNSRecord, []DNS
 // The wildcard parameter instructs Mailgun to treat all subdomains of this domain uniformly if true,
 // and as different domains if false.
 func (m *MailgunImpl) CreateDomain(name string, smtpPassword string, spamAction string, wildcard bool) error {
-	r := newHTTPRequest(generatePublicApiUrl(domainsEndpoint))
-	r.setClient(m.Client())
+	r := newHTTPRequest(generatePublicApiUrl(m, domainsEndpoint))
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 
 	payload := newUrlEncodedPayload()
@@ -114,11 +213,292 @@ func (m *MailgunImpl) CreateDomain(name string, smtpPassword string, spamAction
 	return err
 }
 
+// AddDomain instructs Mailgun to create a new domain for your account, and returns it as created.
+// Use GetDomain to find the DNS records to publish before Mailgun will consider the domain verified.
+func (m *MailgunImpl) AddDomain(ctx context.Context, name string, opts DomainOptions) (*Domain, error) {
+	r := newHTTPRequest(generatePublicApiUrl(m, domainsEndpoint))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+
+	payload := newUrlEncodedPayload()
+	payload.addValue("name", name)
+	if opts.SMTPPassword != "" {
+		payload.addValue("smtp_password", opts.SMTPPassword)
+	}
+	if opts.SpamAction != "" {
+		payload.addValue("spam_action", opts.SpamAction)
+	}
+	payload.addValue("wildcard", strconv.FormatBool(opts.Wildcard))
+	var envelope singleDomainEnvelope
+	if err := postResponseFromJSON(r, payload, &envelope); err != nil {
+		return nil, err
+	}
+	return &envelope.Domain, nil
+}
+
+// GetDomain retrieves detailed information about the named domain, along with its DNS records:
+// first those for sending, then those for receiving.
+// The Valid field of each record reports whether Mailgun found it published as expected.
+func (m *MailgunImpl) GetDomain(ctx context.Context, name string) (*Domain, []DNSRecord, error) {
+	envelope, err := m.getSingleDomain(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	records := make([]DNSRecord, 0, len(envelope.SendingDNSRecords)+len(envelope.ReceivingDNSRecords))
+	records = append(records, envelope.SendingDNSRecords...)
+	records = append(records, envelope.ReceivingDNSRecords...)
+	return &envelope.Domain, records, nil
+}
+
 // DeleteDomain instructs Mailgun to dispose of the named domain name.
 func (m *MailgunImpl) DeleteDomain(name string) error {
-	r := newHTTPRequest(generatePublicApiUrl(domainsEndpoint) + "/" + name)
-	r.setClient(m.Client())
+	r := newHTTPRequest(generatePublicApiUrl(m, domainsEndpoint) + "/" + name)
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 	_, err := makeDeleteRequest(r)
 	return err
 }
+
+// GetDomainPolicy gathers the spam, DKIM, tracking, and connection settings of the named domain.
+// This takes several API calls; if any of them fails, GetDomainPolicy returns its error.
+func (m *MailgunImpl) GetDomainPolicy(ctx context.Context, domain string) (*DomainPolicy, error) {
+	envelope, err := m.getSingleDomain(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	tracking, err := m.getDomainTracking(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	connection, err := m.getDomainConnection(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &DomainPolicy{
+		SpamAction:          envelope.Domain.SpamAction,
+		DKIMEnabled:         hasValidDKIMRecord(envelope.SendingDNSRecords),
+		ClickTracking:       tracking.ClickTracking(),
+		OpenTracking:        tracking.Open.Active,
+		UnsubscribeTracking: tracking.Unsubscribe.Active,
+		ConnectionSettings:  connection,
+	}
+	policy.TrackingEnabled = policy.ClickTracking != yesNo(false) || policy.OpenTracking || policy.UnsubscribeTracking
+	return policy, nil
+}
+
+// GetDomainTracking retrieves the tracking settings of the named domain.
+func (m *MailgunImpl) GetDomainTracking(ctx context.Context, domain string) (*DomainTracking, error) {
+	tracking, err := m.getDomainTracking(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	return &tracking, nil
+}
+
+// UpdateClickTracking turns click tracking on or off for the named domain.
+// Use UpdateDomainPolicy to track clicks in HTML parts only.
+func (m *MailgunImpl) UpdateClickTracking(ctx context.Context, domain string, active bool) error {
+	p := newUrlEncodedPayload()
+	p.addValue("active", yesNo(active))
+	return m.putDomainSetting(ctx, domain, "/tracking/click", p)
+}
+
+// UpdateOpenTracking turns open tracking on or off for the named domain.
+func (m *MailgunImpl) UpdateOpenTracking(ctx context.Context, domain string, active bool) error {
+	p := newUrlEncodedPayload()
+	p.addValue("active", yesNo(active))
+	return m.putDomainSetting(ctx, domain, "/tracking/open", p)
+}
+
+// UpdateUnsubscribeTracking changes the unsubscribe tracking settings of the named domain.
+// The active parameter must be "yes" or "no".
+// The footers hold the unsubscribe links Mailgun appends to the HTML and text parts of messages;
+// in each, Mailgun replaces %unsubscribe_url% with the recipient's link.
+// Leave a footer blank to keep the one the domain has.
+func (m *MailgunImpl) UpdateUnsubscribeTracking(ctx context.Context, domain, active, htmlFooter, textFooter string) error {
+	switch active {
+	case "yes", "no":
+	default:
+		return fmt.Errorf("unsubscribe tracking must be yes or no; got %q", active)
+	}
+	p := newUrlEncodedPayload()
+	p.addValue("active", active)
+	if htmlFooter != "" {
+		p.addValue("html_footer", htmlFooter)
+	}
+	if textFooter != "" {
+		p.addValue("text_footer", textFooter)
+	}
+	return m.putDomainSetting(ctx, domain, "/tracking/unsubscribe", p)
+}
+
+// GetDomainSpamAction reports how the named domain treats inbound spam: SpamActionDisabled, SpamActionBlock,
+// or SpamActionTag.
+func (m *MailgunImpl) GetDomainSpamAction(ctx context.Context, domain string) (string, error) {
+	envelope, err := m.getSingleDomain(ctx, domain)
+	if err != nil {
+		return "", err
+	}
+	return envelope.Domain.SpamAction, nil
+}
+
+// SetDomainSpamAction changes how the named domain treats inbound spam.
+// The action must be one of SpamActionDisabled, SpamActionBlock, or SpamActionTag;
+// Delete, the older name for SpamActionBlock, is accepted too.
+// Other actions are refused without calling the API.
+func (m *MailgunImpl) SetDomainSpamAction(ctx context.Context, domain, action string) error {
+	if err := validSpamAction(action); err != nil {
+		return err
+	}
+	p := newUrlEncodedPayload()
+	p.addValue("spam_action", action)
+	return m.putDomainSetting(ctx, domain, "", p)
+}
+
+// validSpamAction refuses any action SetDomainSpamAction does not list.
+func validSpamAction(action string) error {
+	switch action {
+	case SpamActionDisabled, SpamActionBlock, SpamActionTag, Delete:
+		return nil
+	}
+	return fmt.Errorf("unknown spam action %q", action)
+}
+
+// GetDomainConnection retrieves the TLS settings the named domain delivers all its mail with.
+// These apply to every message sent through the domain; a message's own SetRequireTLS and SetSkipVerification
+// settings override them for that message alone.
+func (m *MailgunImpl) GetDomainConnection(ctx context.Context, domain string) (*DomainConnection, error) {
+	connection, err := m.getDomainConnection(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	return &connection, nil
+}
+
+// UpdateDomainConnection replaces the TLS settings of the named domain, for all the mail it delivers from now on.
+// Both settings are replaced at once; see GetDomainConnection for how they relate to a message's own.
+func (m *MailgunImpl) UpdateDomainConnection(ctx context.Context, domain string, conn DomainConnection) error {
+	return m.putDomainSetting(ctx, domain, "/connection", newConnectionPayload(conn))
+}
+
+func newConnectionPayload(conn DomainConnection) *urlEncodedPayload {
+	p := newUrlEncodedPayload()
+	p.addValue("require_tls", strconv.FormatBool(conn.RequireTLS))
+	p.addValue("skip_verification", strconv.FormatBool(conn.SkipVerification))
+	return p
+}
+
+func (m *MailgunImpl) getDomainTracking(ctx context.Context, domain string) (DomainTracking, error) {
+	r := newHTTPRequest(generatePublicApiUrl(m, domainsEndpoint) + "/" + domain + "/tracking")
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	var envelope struct {
+		Tracking DomainTracking `json:"tracking"`
+	}
+	err := getResponseFromJSON(r, &envelope)
+	return envelope.Tracking, err
+}
+
+func (m *MailgunImpl) getDomainConnection(ctx context.Context, domain string) (DomainConnection, error) {
+	r := newHTTPRequest(generatePublicApiUrl(m, domainsEndpoint) + "/" + domain + "/connection")
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	var envelope struct {
+		Connection DomainConnection `json:"connection"`
+	}
+	err := getResponseFromJSON(r, &envelope)
+	return envelope.Connection, err
+}
+
+// hasValidDKIMRecord returns true if, and only if,
+// the sending DNS records include a DKIM key which Mailgun has verified.
+func hasValidDKIMRecord(records []DNSRecord) bool {
+	for _, rec := range records {
+		if rec.RecordType == "TXT" && strings.Contains(rec.Name, "._domainkey.") && rec.Valid == "valid" {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateDomainPolicy changes those settings of the named domain which the update specifies.
+// The changes are applied concurrently, as each takes a separate API call.
+// Since the calls are independent of each other, a failure of one does not prevent the others from taking effect.
+// If some of them fail, all failures are reported together in the returned error.
+func (m *MailgunImpl) UpdateDomainPolicy(ctx context.Context, domain string, update DomainPolicyUpdate) error {
+	var calls []func() error
+	if update.SpamAction != "" {
+		if err := validSpamAction(update.SpamAction); err != nil {
+			return err
+		}
+		p := newUrlEncodedPayload()
+		p.addValue("spam_action", update.SpamAction)
+		calls = append(calls, func() error {
+			return m.putDomainSetting(ctx, domain, "", p)
+		})
+	}
+	if update.ClickTracking != "" {
+		switch update.ClickTracking {
+		case "yes", "no", "htmlonly":
+		default:
+			return fmt.Errorf("click tracking must be yes, no, or htmlonly; got %q", update.ClickTracking)
+		}
+		p := newUrlEncodedPayload()
+		p.addValue("active", update.ClickTracking)
+		calls = append(calls, func() error {
+			return m.putDomainSetting(ctx, domain, "/tracking/click", p)
+		})
+	}
+	if update.OpenTracking != nil {
+		p := newUrlEncodedPayload()
+		p.addValue("active", yesNo(*update.OpenTracking))
+		calls = append(calls, func() error {
+			return m.putDomainSetting(ctx, domain, "/tracking/open", p)
+		})
+	}
+	if update.UnsubscribeTracking != nil {
+		p := newUrlEncodedPayload()
+		p.addValue("active", yesNo(*update.UnsubscribeTracking))
+		calls = append(calls, func() error {
+			return m.putDomainSetting(ctx, domain, "/tracking/unsubscribe", p)
+		})
+	}
+	if update.ConnectionSettings != nil {
+		p := newConnectionPayload(*update.ConnectionSettings)
+		calls = append(calls, func() error {
+			return m.putDomainSetting(ctx, domain, "/connection", p)
+		})
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for _, call := range calls {
+		wg.Add(1)
+		go func(call func() error) {
+			defer wg.Done()
+			if err := call(); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(call)
+	}
+	wg.Wait()
+	return combineErrors(errs)
+}
+
+// putDomainSetting issues a PUT against the named domain, or against one of its sub-resources.
+// The setting parameter holds the path of the sub-resource, or "" for the domain itself.
+func (m *MailgunImpl) putDomainSetting(ctx context.Context, domain, setting string, p payload) error {
+	r := newHTTPRequest(generatePublicApiUrl(m, domainsEndpoint) + "/" + domain + setting)
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	_, err := makePutRequest(r, p)
+	return err
+}
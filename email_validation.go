@@ -1,9 +1,25 @@
 package mailgun
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/mail"
 	"strings"
+	"time"
 )
 
+// ErrNoValidRecipients indicates that ValidateAndSend filtered out every recipient of a message,
+// leaving nobody to send it to.
+var ErrNoValidRecipients = errors.New("no valid recipients")
+
+// ErrPublicAPIKeyRequired indicates that a function needs the public API key, but the client wasn't given one.
+var ErrPublicAPIKeyRequired = errors.New("public API key required")
+
 // The EmailVerificationParts structure breaks out the basic elements of an email address.
 // LocalPart includes everything up to the '@' in an e-mail address.
 // Domain includes everything after the '@'.
@@ -40,8 +56,8 @@ type addressParseResult struct {
 // It may also be used to break an email address into its sub-components.  (See example.)
 // NOTE: Use of this function requires a proper public API key.  The private API key will not work.
 func (m *MailgunImpl) ValidateEmail(email string) (EmailVerification, error) {
-	r := newHTTPRequest(generatePublicApiUrl(addressValidateEndpoint))
-	r.setClient(m.Client())
+	r := newHTTPRequest(generatePublicApiUrl(m, addressValidateEndpoint))
+	r.setClient(m)
 	r.addParameter("address", email)
 	r.setBasicAuth(basicAuthUser, m.PublicApiKey())
 
@@ -54,11 +70,22 @@ func (m *MailgunImpl) ValidateEmail(email string) (EmailVerification, error) {
 	return response, nil
 }
 
+// ParseAddress splits an address, such as "Alice <alice@example.com>" or plain "alice@example.com",
+// into its display name, blank if it has none, and its e-mail address.
+// It parses the address locally, as RFC 5322 describes; use ParseAddresses or ValidateAddress to check it with Mailgun.
+func ParseAddress(addr string) (displayName, email string, err error) {
+	a, err := mail.ParseAddress(addr)
+	if err != nil {
+		return "", "", err
+	}
+	return a.Name, a.Address, nil
+}
+
 // ParseAddresses takes a list of addresses and sorts them into valid and invalid address categories.
 // NOTE: Use of this function requires a proper public API key.  The private API key will not work.
 func (m *MailgunImpl) ParseAddresses(addresses ...string) ([]string, []string, error) {
-	r := newHTTPRequest(generatePublicApiUrl(addressParseEndpoint))
-	r.setClient(m.Client())
+	r := newHTTPRequest(generatePublicApiUrl(m, addressParseEndpoint))
+	r.setClient(m)
 	r.addParameter("addresses", strings.Join(addresses, ","))
 	r.setBasicAuth(basicAuthUser, m.PublicApiKey())
 
@@ -70,3 +97,263 @@ func (m *MailgunImpl) ParseAddresses(addresses ...string) ([]string, []string, e
 
 	return response.Parsed, response.Unparseable, nil
 }
+
+const (
+	phoneValidateEndpoint = "address/phone"
+	bulkValidateEndpoint  = "address/validate/bulk"
+)
+
+// Mailgun rates the risk of sending to an address as one of these levels, in increasing order.
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+var riskRanks = map[string]int{
+	RiskLow:    1,
+	RiskMedium: 2,
+	RiskHigh:   3,
+}
+
+// ValidationOptions controls which recipients ValidateAndSend drops.
+//
+// Addresses that Mailgun deems undeliverable are always dropped.
+// MinRiskLevel, if set to one of RiskLow, RiskMedium, or RiskHigh, additionally drops
+// addresses rated at that level of risk or above; leave it empty to ignore risk.
+// Addresses whose risk Mailgun cannot determine are kept.
+// Dropped addresses are written to Logger, if set.
+type ValidationOptions struct {
+	MinRiskLevel string
+	Logger       *log.Logger
+}
+
+// EmailValidation records the verdict of Mailgun's version 4 validation service on a single address,
+// which checks its syntax, its domain's DNS records, and where possible, whether its mailbox exists.
+// See ValidateAddress.
+//
+// Result is one of "deliverable", "undeliverable", "do_not_send", "catch_all", or "unknown";
+// IsValid is true only for deliverable addresses.
+// RiskLevel is one of RiskLow, RiskMedium, or RiskHigh, or "unknown".
+// IsDisposableAddress and IsRoleAddress flag throwaway mailboxes, and those of roles like postmaster@.
+// DidYouMean suggests a correction if Mailgun suspects a typo, and is empty otherwise.
+// Reason lists Mailgun's explanations of the verdict, if any.
+type EmailValidation struct {
+	Address             string   `json:"address"`
+	IsValid             bool     `json:"-"`
+	IsDisposableAddress bool     `json:"is_disposable_address"`
+	IsRoleAddress       bool     `json:"is_role_address"`
+	DidYouMean          string   `json:"did_you_mean"`
+	RiskLevel           string   `json:"risk"`
+	Result              string   `json:"result"`
+	Reason              []string `json:"reason"`
+}
+
+// ValidateAddress checks the address with Mailgun's version 4 validation service.
+// NOTE: unlike ValidateEmail, this function uses the private API key.
+func (m *MailgunImpl) ValidateAddress(ctx context.Context, address string) (*EmailValidation, error) {
+	r := newHTTPRequest(generateV4ApiUrl(m, addressValidateEndpoint))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.addParameter("address", address)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+
+	var v EmailValidation
+	if err := getResponseFromJSON(r, &v); err != nil {
+		return nil, err
+	}
+	v.IsValid = v.Result == "deliverable"
+	return &v, nil
+}
+
+// BulkValidation reports the progress of a bulk validation job; see ValidateEmailBulk.
+// Status is "created" or "processing" until the job's done, then "uploaded";
+// only then do DownloadURL and Summary carry the results.
+// DownloadURL links to the full results, in CSV and JSON form.
+// Summary counts the addresses by result and by risk level.
+type BulkValidation struct {
+	ID               string `json:"id"`
+	Status           string `json:"status"`
+	Quantity         int    `json:"quantity"`
+	RecordsProcessed int    `json:"records_processed"`
+	DownloadURL      struct {
+		CSV  string `json:"csv"`
+		JSON string `json:"json"`
+	} `json:"download_url"`
+	Summary struct {
+		Result map[string]int `json:"result"`
+		Risk   map[string]int `json:"risk"`
+	} `json:"summary"`
+}
+
+// ValidateEmailBulk starts a job validating every one of the addresses, as ValidateAddress does for one.
+// Jobs run in the background; poll GetBulkValidation with the returned job's ID to follow it.
+// NOTE: this function uses the private API key.
+func (m *MailgunImpl) ValidateEmailBulk(ctx context.Context, addresses []string) (*BulkValidation, error) {
+	var list bytes.Buffer
+	w := csv.NewWriter(&list)
+	w.Write([]string{"email"})
+	for _, a := range addresses {
+		w.Write([]string{a})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+
+	id := fmt.Sprintf("mailgun-go-%d", time.Now().UnixNano())
+	r := newHTTPRequest(generateV4ApiUrl(m, bulkValidateEndpoint) + "/" + id)
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	p := newFormDataPayload()
+	p.addReadCloserWithType("file", id+".csv", "text/csv", ioutil.NopCloser(&list))
+	if _, err := makePostRequest(r, p); err != nil {
+		return nil, err
+	}
+	return &BulkValidation{ID: id, Status: "created", Quantity: len(addresses)}, nil
+}
+
+// GetBulkValidation reports the progress of a bulk validation job started by ValidateEmailBulk.
+func (m *MailgunImpl) GetBulkValidation(ctx context.Context, jobID string) (*BulkValidation, error) {
+	r := newHTTPRequest(generateV4ApiUrl(m, bulkValidateEndpoint) + "/" + jobID)
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+
+	var job BulkValidation
+	if err := getResponseFromJSON(r, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ValidateAndSend validates every recipient of the message, removes those which fail the
+// criteria given in opts, and then sends the message to the remaining recipients.
+// Recipient variables of dropped recipients are removed along with them.
+// For MIME messages, only recipients added through the Message API are checked.
+// If no recipients remain, ValidateAndSend returns ErrNoValidRecipients without sending anything.
+// NOTE: unlike ValidateEmail, this function uses the private API key.
+func (m *MailgunImpl) ValidateAndSend(ctx context.Context, message *Message, opts ValidationOptions) (string, string, error) {
+	minRank := 0
+	if opts.MinRiskLevel != "" {
+		var ok bool
+		minRank, ok = riskRanks[opts.MinRiskLevel]
+		if !ok {
+			return "", "", fmt.Errorf("unknown risk level %q", opts.MinRiskLevel)
+		}
+	}
+
+	verdicts := make(map[string]string)
+	keep := func(recipient string) (bool, error) {
+		if reason, ok := verdicts[recipient]; ok {
+			return reason == "", nil
+		}
+		reason, err := m.rejectionReason(ctx, recipient, minRank)
+		if err != nil {
+			return false, err
+		}
+		verdicts[recipient] = reason
+		if reason != "" && opts.Logger != nil {
+			opts.Logger.Printf("mailgun: dropping recipient %s: %s", recipient, reason)
+		}
+		return reason == "", nil
+	}
+
+	to, err := filterRecipients(message.to, keep)
+	if err != nil {
+		return "", "", err
+	}
+	var cc, bcc []string
+	pm, isPlain := message.specific.(*plainMessage)
+	if isPlain {
+		if cc, err = filterRecipients(pm.cc, keep); err != nil {
+			return "", "", err
+		}
+		if bcc, err = filterRecipients(pm.bcc, keep); err != nil {
+			return "", "", err
+		}
+	}
+	if len(to)+len(cc)+len(bcc) == 0 {
+		return "", "", ErrNoValidRecipients
+	}
+
+	message.to = to
+	if isPlain {
+		pm.cc, pm.bcc = cc, bcc
+	}
+	for recipient, reason := range verdicts {
+		if reason != "" {
+			delete(message.recipientVariables, recipient)
+		}
+	}
+	return m.Send(ctx, message)
+}
+
+// rejectionReason explains why the recipient shouldn't receive mail,
+// or returns "" if the recipient passes validation.
+func (m *MailgunImpl) rejectionReason(ctx context.Context, recipient string, minRank int) (string, error) {
+	_, email, err := ParseAddress(recipient)
+	if err != nil {
+		return "unparseable address", nil
+	}
+
+	v, err := m.ValidateAddress(ctx, email)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case v.Result == "undeliverable" || v.Result == "do_not_send":
+		return "result " + v.Result, nil
+	case minRank > 0 && riskRanks[v.RiskLevel] >= minRank:
+		return v.RiskLevel + " risk", nil
+	}
+	return "", nil
+}
+
+// filterRecipients returns those recipients which keep accepts, preserving their order.
+func filterRecipients(recipients []string, keep func(string) (bool, error)) ([]string, error) {
+	var kept []string
+	for _, recipient := range recipients {
+		ok, err := keep(recipient)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			kept = append(kept, recipient)
+		}
+	}
+	return kept, nil
+}
+
+// PhoneValidation records what Mailgun's validation service knows of a phone number.
+// CountryCode gives the ISO 3166 code of the country the number belongs to.
+// NationalFormat and E164Format render the number for dialing within that country, and internationally.
+// LineType is one of "mobile", "landline", or "voip", if known.
+type PhoneValidation struct {
+	IsValid        bool   `json:"is_valid"`
+	CountryCode    string `json:"country_code"`
+	NationalFormat string `json:"national_format"`
+	E164Format     string `json:"e164_format"`
+	LineType       string `json:"line_type"`
+}
+
+// ValidatePhone checks the phone number with Mailgun's validation service.
+// NOTE: Use of this function requires a proper public API key.  If the client has none,
+// ValidatePhone returns ErrPublicAPIKeyRequired without contacting Mailgun.
+func (m *MailgunImpl) ValidatePhone(ctx context.Context, phone string) (*PhoneValidation, error) {
+	if m.PublicApiKey() == "" {
+		return nil, ErrPublicAPIKeyRequired
+	}
+	r := newHTTPRequest(generateV4ApiUrl(m, phoneValidateEndpoint))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.addParameter("phone", phone)
+	r.setBasicAuth(basicAuthUser, m.PublicApiKey())
+
+	var response PhoneValidation
+	if err := getResponseFromJSON(r, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
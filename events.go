@@ -1,14 +1,64 @@
 package mailgun
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
 // Events are open-ended, loosely-defined JSON documents.
 // They will always have an event and a timestamp field, however.
+// The accessor methods below read the fields common to most events.
 type Event map[string]interface{}
 
+// Name returns the kind of event, such as "delivered" or "opened".
+func (e Event) Name() string {
+	return eventString(e, "event")
+}
+
+// Timestamp returns the time at which the event occurred.
+func (e Event) Timestamp() time.Time {
+	t := eventTimestamp(e)
+	sec := int64(t)
+	return time.Unix(sec, int64((t-float64(sec))*1e9))
+}
+
+// Recipient returns the address of the recipient the event concerns, if any.
+func (e Event) Recipient() string {
+	return eventString(e, "recipient")
+}
+
+// MessageID returns the Message-ID of the message the event concerns, if any.
+func (e Event) MessageID() string {
+	return eventString(e, "message", "headers", "message-id")
+}
+
+// Tags returns the tags attached to the message the event concerns.
+func (e Event) Tags() []string {
+	items, _ := e["tags"].([]interface{})
+	tags := make([]string, 0, len(items))
+	for _, item := range items {
+		if tag, ok := item.(string); ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Severity returns "permanent" or "temporary" for failed events, and "" for all others.
+func (e Event) Severity() string {
+	return eventString(e, "severity")
+}
+
+// StorageURL returns the URL Mailgun stores the event's message under, if it stores it;
+// see GetStoredMessageByURL.
+func (e Event) StorageURL() string {
+	return eventString(e, "storage", "url")
+}
+
 // noTime always equals an uninitialized Time structure.
 // It's used to detect when a time parameter is provided.
 var noTime time.Time
@@ -30,10 +80,100 @@ type GetEventsOptions struct {
 	Filter                                   map[string]string
 }
 
+// EventOptions narrows the events returned by functions that gather events about a single subject,
+// such as GetRecipientHistory.
+// Begin and End time-box the events; leave either at its zero value to leave the period open at that end.
+// Limit caps the total number of events returned; zero means no cap.
+type EventOptions struct {
+	Begin, End time.Time
+	Limit      int
+}
+
+// EventsOptions narrows the events returned by GetEvents.
+// Begin and End time-box the events; leave either at its zero value to leave the period open at that end.
+// Event restricts the results to one kind of event, such as "delivered" or "failed".
+// Recipient restricts the results to those concerning a single recipient.
+// Limit sets the number of events per page; if left unspecified, Mailgun assumes 100, and allows at most 300.
+// Concurrency sets the number of goroutines GetAllEvents fetches events with;
+// if left unspecified, it uses the client's concurrency limit (see WithConcurrencyLimit).  Other functions ignore it.
+type EventsOptions struct {
+	Begin, End  time.Time
+	Event       string
+	Recipient   string
+	Limit       int
+	Concurrency int
+}
+
+// GetEvents retrieves the first page of the named domain's events matching the given criteria, oldest first,
+// along with a token for the page after it.
+// Pass the token to GetNextPage to continue.
+func (mg *MailgunImpl) GetEvents(ctx context.Context, domain string, opts EventsOptions) ([]Event, string, error) {
+	url, err := eventsOptionsURL(mg, domain, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	return mg.GetNextPage(ctx, url)
+}
+
+// eventsOptionsURL renders the URL of the first page of the named domain's events matching an EventsOptions structure.
+func eventsOptionsURL(mg Mailgun, domain string, opts EventsOptions) (string, error) {
+	return eventsURL(mg, domain, eventsGetOptions(opts))
+}
+
+// eventsGetOptions expresses an EventsOptions structure as the equivalent GetEventsOptions, oldest events first.
+func eventsGetOptions(opts EventsOptions) GetEventsOptions {
+	eventOpts := GetEventsOptions{
+		Begin:          opts.Begin,
+		End:            opts.End,
+		ForceAscending: true,
+		Limit:          opts.Limit,
+		Filter:         map[string]string{},
+	}
+	if opts.Event != "" {
+		eventOpts.Filter["event"] = opts.Event
+	}
+	if opts.Recipient != "" {
+		eventOpts.Filter["recipient"] = opts.Recipient
+	}
+	return eventOpts
+}
+
+// GetNextPage retrieves the page of events named by a token from GetEvents or an earlier GetNextPage,
+// along with a token for the page after it.
+// An empty token results once the events run out; at that point, the page is empty too.
+func (mg *MailgunImpl) GetNextPage(ctx context.Context, token string) ([]Event, string, error) {
+	events, next, _, err := fetchEvents(ctx, mg, token)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(events) == 0 {
+		return events, "", nil
+	}
+	return events, next, nil
+}
+
+// maxEventsPageSize is the largest page of events Mailgun returns at once.
+const maxEventsPageSize = 300
+
 // EventIterator maintains the state necessary for paging though small parcels of a larger set of events.
+// Iterators made by Events are walked with Next, in the manner of database/sql's Rows:
+//
+//	it := mg.Events(domain, opts)
+//	for {
+//		events, ok := it.Next(ctx)
+//		if !ok {
+//			break
+//		}
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
 type EventIterator struct {
 	events           []Event
+	firstURL         string
 	nextURL, prevURL string
+	err              error
 	mg               Mailgun
 }
 
@@ -44,6 +184,53 @@ func (mg *MailgunImpl) NewEventIterator() *EventIterator {
 	return &EventIterator{mg: mg}
 }
 
+// Events creates an iterator over the named domain's events matching the given criteria, oldest first.
+// Nothing is fetched until the first call to Next.
+func (mg *MailgunImpl) Events(domain string, opts EventsOptions) *EventIterator {
+	ei := &EventIterator{mg: mg}
+	ei.firstURL, ei.err = eventsOptionsURL(mg, domain, opts)
+	ei.nextURL = ei.firstURL
+	return ei
+}
+
+// Next retrieves the next batch of events, following Mailgun's paging links.
+// It returns false once the events run out, the context ends, or an API call fails;
+// use Err to tell these cases apart.
+func (ei *EventIterator) Next(ctx context.Context) ([]Event, bool) {
+	if ei.err != nil || ei.nextURL == "" {
+		return nil, false
+	}
+	events, next, prev, err := fetchEvents(ctx, ei.mg, ei.nextURL)
+	if err != nil {
+		ei.err = err
+		return nil, false
+	}
+	ei.events = events
+	ei.nextURL = next
+	ei.prevURL = prev
+	if len(events) == 0 {
+		ei.nextURL = ""
+		return nil, false
+	}
+	return events, true
+}
+
+// Err returns the error that stopped Next, if any; it's nil if Next stopped for want of events.
+func (ei *EventIterator) Err() error {
+	return ei.err
+}
+
+// Rewind returns the iterator to its start, clearing any error, so that Next begins again with the first batch.
+func (ei *EventIterator) Rewind() {
+	if ei.firstURL == "" {
+		return
+	}
+	ei.events = nil
+	ei.nextURL = ei.firstURL
+	ei.prevURL = ""
+	ei.err = nil
+}
+
 // Events returns the most recently retrieved batch of events.
 // The length is guaranteed to fall between 0 and the limit set in the GetEventsOptions structure passed to GetFirstPage.
 func (ei *EventIterator) Events() []Event {
@@ -53,8 +240,22 @@ func (ei *EventIterator) Events() []Event {
 // GetFirstPage retrieves the first batch of events, according to your criteria.
 // See the GetEventsOptions structure for more details on how the fields affect the data returned.
 func (ei *EventIterator) GetFirstPage(opts GetEventsOptions) error {
+	payload, err := newEventsPayload(opts)
+	if err != nil {
+		return err
+	}
+	url, err := generateParameterizedUrl(ei.mg, eventsEndpoint, payload)
+	if err != nil {
+		return err
+	}
+	ei.firstURL = url
+	return ei.fetch(url)
+}
+
+// newEventsPayload renders the query parameters corresponding to a GetEventsOptions structure.
+func newEventsPayload(opts GetEventsOptions) (*urlEncodedPayload, error) {
 	if opts.ForceAscending && opts.ForceDescending {
-		return fmt.Errorf("collation cannot at once be both ascending and descending")
+		return nil, fmt.Errorf("collation cannot at once be both ascending and descending")
 	}
 
 	payload := newUrlEncodedPayload()
@@ -81,12 +282,7 @@ func (ei *EventIterator) GetFirstPage(opts GetEventsOptions) error {
 			payload.addValue(k, v)
 		}
 	}
-
-	url, err := generateParameterizedUrl(ei.mg, eventsEndpoint, payload)
-	if err != nil {
-		return err
-	}
-	return ei.fetch(url)
+	return payload, nil
 }
 
 // Retrieves the chronologically previous batch of events, if any exist.
@@ -104,27 +300,314 @@ func (ei *EventIterator) GetNext() error {
 // GetFirstPage, GetPrevious, and GetNext all have a common body of code.
 // fetch completes the API fetch common to all three of these functions.
 func (ei *EventIterator) fetch(url string) error {
+	events, next, prev, err := fetchEvents(context.Background(), ei.mg, url)
+	if err != nil {
+		return err
+	}
+	ei.events = events
+	ei.nextURL = next
+	ei.prevURL = prev
+	return nil
+}
+
+// fetchEvents retrieves a single page of events,
+// along with the URLs of the pages chronologically after and before it.
+func fetchEvents(ctx context.Context, mg Mailgun, url string) ([]Event, string, string, error) {
 	r := newHTTPRequest(url)
-	r.setClient(ei.mg.Client())
-	r.setBasicAuth(basicAuthUser, ei.mg.ApiKey())
-	var response map[string]interface{}
-	err := getResponseFromJSON(r, &response)
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	// Mailgun may leave out the paging links, on the last page, say; they're then taken as blank.
+	var response struct {
+		Items  []Event `json:"items"`
+		Paging struct {
+			Next     string `json:"next"`
+			Previous string `json:"previous"`
+		} `json:"paging"`
+	}
+	if err := getResponseFromJSON(r, &response); err != nil {
+		return nil, "", "", err
+	}
+	return response.Items, response.Paging.Next, response.Paging.Previous, nil
+}
+
+// eventsURL renders the URL of the first page of the named domain's events matching the given criteria.
+func eventsURL(mg Mailgun, domain string, opts GetEventsOptions) (string, error) {
+	payload, err := newEventsPayload(opts)
+	if err != nil {
+		return "", err
+	}
+	params, err := payload.getPayloadBuffer()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s?%s", generateApiUrlWithDomain(mg, domain, eventsEndpoint), params.String()), nil
+}
+
+// forEachEvent walks through every event of the named domain matching the given criteria,
+// fetching further pages as required.
+// The visit function is called once per event, in the order Mailgun returns them;
+// returning false from it stops the walk early.
+func (mg *MailgunImpl) forEachEvent(ctx context.Context, domain string, opts GetEventsOptions, visit func(Event) bool) error {
+	url, err := eventsURL(mg, domain, opts)
 	if err != nil {
 		return err
 	}
+	for url != "" {
+		events, next, _, err := fetchEvents(ctx, mg, url)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		for _, e := range events {
+			if !visit(e) {
+				return nil
+			}
+		}
+		url = next
+	}
+	return nil
+}
+
+// eventsPrefetch caps the number of events each of GetAllEvents' goroutines fetches ahead of the caller.
+const eventsPrefetch = 10 * maxEventsPageSize
+
+// GetAllEvents retrieves every one of the named domain's events matching the given criteria,
+// splitting the period from opts.Begin to opts.End into as many parts as opts.Concurrency allows
+// and fetching the pages of each part concurrently, which is much faster than paging through a long period in turn.
+// An open End stands for the present; with an open Begin, the period can't be split, and is fetched as one part.
+//
+// Events arrive on the first channel roughly oldest first: in order within each part, and part after part.
+// The first error to stop the fetch, if any, arrives on the second channel, which is closed after the first;
+// drain the events, then check for an error:
+//
+//	events, errs := mg.GetAllEvents(ctx, domain, mailgun.EventsOptions{Begin: begin, End: end})
+//	for e := range events {
+//		...
+//	}
+//	if err := <-errs; err != nil {
+//		...
+//	}
+//
+// Cancel the context to stop early; the fetch then ends with the context's error.
+func (mg *MailgunImpl) GetAllEvents(ctx context.Context, domain string, opts EventsOptions) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errs := make(chan error, 1)
+	n := opts.Concurrency
+	if n < 1 {
+		n = mg.concurrencyLimit
+	}
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	var once sync.Once
+	fail := func(err error) {
+		once.Do(func() {
+			errs <- err
+			cancel()
+		})
+	}
+
+	periods := splitEventsPeriod(opts.Begin, opts.End, n)
+	parts := make([]chan Event, len(periods))
+	for i, period := range periods {
+		parts[i] = make(chan Event, eventsPrefetch)
+		go func(part chan<- Event, period [2]time.Time, last bool) {
+			defer close(part)
+			partOpts := opts
+			partOpts.Begin, partOpts.End = period[0], period[1]
+			end := float64(period[1].Unix())
+			err := mg.forEachEvent(ctx, domain, eventsGetOptions(partOpts), func(e Event) bool {
+				// Mailgun includes events at the end of the period, which the next part also fetches.
+				if !last && eventTimestamp(e) >= end {
+					return true
+				}
+				select {
+				case part <- e:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+			if err != nil {
+				fail(err)
+			}
+		}(parts[i], period, i == len(periods)-1)
+	}
+
+	go func() {
+		defer cancel()
+		for _, part := range parts {
+			for e := range part {
+				select {
+				case events <- e:
+				case <-ctx.Done():
+				}
+			}
+		}
+		if err := parent.Err(); err != nil {
+			fail(err)
+		}
+		close(events)
+		close(errs)
+	}()
+	return events, errs
+}
+
+// splitEventsPeriod divides the period from begin to end into at most n parts of equal length,
+// on whole seconds, as Mailgun takes times to the second.
+// A period with an open beginning is left whole; an open end stands for the present.
+func splitEventsPeriod(begin, end time.Time, n int) [][2]time.Time {
+	if begin.IsZero() {
+		return [][2]time.Time{{begin, end}}
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+	seconds := int64(end.Sub(begin) / time.Second)
+	if int64(n) > seconds {
+		n = int(seconds)
+	}
+	if n < 2 {
+		return [][2]time.Time{{begin, end}}
+	}
+	step := time.Duration(seconds/int64(n)) * time.Second
+	periods := make([][2]time.Time, n)
+	start := begin
+	for i := range periods {
+		stop := start.Add(step)
+		if i == n-1 {
+			stop = end
+		}
+		periods[i] = [2]time.Time{start, stop}
+		start = stop
+	}
+	return periods
+}
 
-	items := response["items"].([]interface{})
-	ei.events = make([]Event, len(items))
-	for i, item := range items {
-		ei.events[i] = item.(map[string]interface{})
+// GetRecipientHistory retrieves every event the named domain recorded for the recipient, oldest first:
+// each message accepted for them, delivered, opened, clicked, bounced, and so on.
+// This helps support staff understand what happened to someone's mail.
+// Use opts.Limit to bound the number of events gathered for recipients with long histories.
+func (mg *MailgunImpl) GetRecipientHistory(ctx context.Context, domain, recipient string, opts EventOptions) ([]Event, error) {
+	eventOpts := GetEventsOptions{
+		Begin:          opts.Begin,
+		End:            opts.End,
+		ForceAscending: true,
+		Limit:          maxEventsPageSize,
+		Filter:         map[string]string{"recipient": recipient},
+	}
+	if opts.Limit > 0 && opts.Limit < maxEventsPageSize {
+		eventOpts.Limit = opts.Limit
+	}
+
+	var events []Event
+	err := mg.forEachEvent(ctx, domain, eventOpts, func(e Event) bool {
+		events = append(events, e)
+		return opts.Limit <= 0 || len(events) < opts.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTimestamp(events[i]) < eventTimestamp(events[j])
+	})
+	return events, nil
+}
+
+// MessageEventOptions narrows the events returned by GetMessageEvents.
+// EventTypes restricts the results to the named kinds of event, such as "delivered" or "failed";
+// leave it empty to gather every event recorded for the message.
+type MessageEventOptions struct {
+	EventTypes []string
+}
+
+// defaultPollInterval is how long PollMessageEvent waits between calls when given no interval.
+const defaultPollInterval = 15 * time.Second
+
+// GetMessageEvents retrieves every event the named domain recorded for a single message, oldest first,
+// as named by the Message-Id Send returned.
+// This lets callers follow a message from acceptance through delivery, opens, clicks, and so on.
+func (mg *MailgunImpl) GetMessageEvents(ctx context.Context, domain, messageID string, opts MessageEventOptions) ([]Event, error) {
+	messageID = strings.Trim(messageID, "<>")
+	if messageID == "" {
+		return nil, ErrEmptyParam
+	}
+	eventOpts := GetEventsOptions{
+		ForceAscending: true,
+		Limit:          maxEventsPageSize,
+		Filter:         map[string]string{"message-id": messageID},
+	}
+	if len(opts.EventTypes) > 0 {
+		eventOpts.Filter["event"] = strings.Join(opts.EventTypes, " OR ")
 	}
 
-	pagings := response["paging"].(map[string]interface{})
-	links := make(map[string]string, len(pagings))
-	for key, page := range pagings {
-		links[key] = page.(string)
+	var events []Event
+	err := mg.forEachEvent(ctx, domain, eventOpts, func(e Event) bool {
+		events = append(events, e)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTimestamp(events[i]) < eventTimestamp(events[j])
+	})
+	return events, nil
+}
+
+// PollMessageEvent calls GetMessageEvents every interval until Mailgun records an event of the desired kind
+// for the message, then returns the first such event.
+// Mailgun takes some time to record events, so this suits tests and tools waiting on, say, a "delivered" event.
+// It gives up with the context's error once the context ends; an interval of zero waits 15 seconds between calls.
+func (mg *MailgunImpl) PollMessageEvent(ctx context.Context, domain, messageID, desiredEvent string, interval time.Duration) (Event, error) {
+	if desiredEvent == "" {
+		return nil, ErrEmptyParam
+	}
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	opts := MessageEventOptions{EventTypes: []string{desiredEvent}}
+	for {
+		events, err := mg.GetMessageEvents(ctx, domain, messageID, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			if e.Name() == desiredEvent {
+				return e, nil
+			}
+		}
+
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// eventTimestamp returns the time of an event, in seconds since the Unix epoch.
+func eventTimestamp(e Event) float64 {
+	t, _ := e["timestamp"].(float64)
+	return t
+}
+
+// eventString returns the named field of an event, if it exists and holds a string.
+// Otherwise, it returns "".
+// Fields of nested objects, such as geolocation.country, are named by giving each key along the path.
+func eventString(e Event, path ...string) string {
+	var v interface{} = map[string]interface{}(e)
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		v = m[key]
 	}
-	ei.nextURL = links["next"]
-	ei.prevURL = links["previous"]
-	return err
+	s, _ := v.(string)
+	return s
 }
@@ -1,12 +1,26 @@
 package mailgun
 
 import (
+	"context"
 	"io/ioutil"
 	"log"
 	"strings"
 	"time"
 )
 
+func ExampleNewMailgunFromEnv() {
+	// MAILGUN_DOMAIN and MAILGUN_API_KEY come from the container's environment,
+	// along with MAILGUN_REGION, if the domain lives in the EU region.
+	mg, err := NewMailgunFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	m := mg.NewMessage("Excited User <me@example.com>", "Hello", "Testing some Mailgun awesomeness!", "bar@example.com")
+	if _, _, err := mg.Send(context.Background(), m); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func ExampleMailgunImpl_ValidateEmail() {
 	mg := NewMailgun("example.com", "", "my_public_api_key")
 	ev, err := mg.ValidateEmail("joe@example.com")
@@ -66,7 +80,7 @@ func ExampleMailgunImpl_Send_constructed() {
 	m.SetTracking(true)
 	m.SetDeliveryTime(time.Now().Add(24 * time.Hour))
 	m.SetHtml("<html><body><h1>Testing some Mailgun Awesomeness!!</h1></body></html>")
-	_, id, err := mg.Send(m)
+	_, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -85,7 +99,7 @@ Testing some Mailgun MIME awesomeness!
 `
 	mg := NewMailgun("example.com", "my_api_key", "")
 	m := NewMIMEMessage(ioutil.NopCloser(strings.NewReader(exampleMime)), "bargle.garf@example.com")
-	_, id, err := mg.Send(m)
+	_, id, err := mg.Send(context.Background(), m)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,111 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// forecastPeriod is how far back GetDeliveryForecast looks for sending statistics.
+const forecastPeriod = 30 * 24 * time.Hour
+
+// Thresholds GetDeliveryForecast applies to a domain's recent sending statistics.
+const (
+	forecastMaxBounceRate    = 0.05
+	forecastMaxComplaintRate = 0.001
+	forecastWarmupVolume     = 1000
+	forecastSteadyVolume     = 10000
+)
+
+// A DeliveryForecast estimates how mail sent from a domain will fare.
+// ExpectedInboxRate and ExpectedSpamRate estimate the fractions of messages which will reach recipients' inboxes
+// and their spam folders.  ConfidenceLevel is "low", "medium", or "high", according to how much recent
+// sending history the estimate rests on.  RiskFactors lists what lowers the estimate,
+// and Recommendations what to do about it.
+type DeliveryForecast struct {
+	ExpectedInboxRate float64
+	ExpectedSpamRate  float64
+	ConfidenceLevel   string
+	RiskFactors       []string
+	Recommendations   []string
+}
+
+// IsReadyToSend reports whether the forecast expects at least minInboxRate of messages to reach the inbox.
+func (f *DeliveryForecast) IsReadyToSend(minInboxRate float64) bool {
+	return f.ExpectedInboxRate >= minInboxRate
+}
+
+// GetDeliveryForecast estimates how mail sent from the named domain will fare, ahead of a large send.
+// Mailgun publishes no such forecast, nor the reputation data behind one, so the estimate is a heuristic built from
+// the domain's state, its DNS and DKIM settings, and its delivery, bounce, and complaint rates over the past 30 days.
+// Treat it as a guide, rather than a promise.
+func (mg *MailgunImpl) GetDeliveryForecast(ctx context.Context, domain string) (*DeliveryForecast, error) {
+	envelope, err := mg.getSingleDomain(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := mg.getDomainStats(ctx, domain, StatsOptions{Start: time.Now().Add(-forecastPeriod)})
+	if err != nil {
+		return nil, err
+	}
+	return forecastDelivery(envelope.Domain, envelope.SendingDNSRecords, stats), nil
+}
+
+// forecastDelivery computes the forecast for a domain from its settings and recent statistics.
+func forecastDelivery(d Domain, records []DNSRecord, stats *DomainStats) *DeliveryForecast {
+	f := &DeliveryForecast{}
+	risk := func(factor, recommendation string) {
+		f.RiskFactors = append(f.RiskFactors, factor)
+		f.Recommendations = append(f.Recommendations, recommendation)
+	}
+
+	// Even well-run domains see some mail filtered as spam.
+	spamRate := 0.02
+	if !hasValidDKIMRecord(records) {
+		spamRate += 0.10
+		risk("no verified DKIM record", "Publish the DKIM record shown in the Mailgun control panel, then verify the domain.")
+	}
+	if !allRecordsValid(records) {
+		spamRate += 0.05
+		risk("unverified sending DNS records", "Publish all sending DNS records (SPF and DKIM) for the domain.")
+	}
+
+	deliveryRate := 1.0
+	if stats.Accepted > 0 {
+		deliveryRate = float64(stats.Delivered) / float64(stats.Accepted)
+		bounceRate := float64(stats.PermanentFailures) / float64(stats.Accepted)
+		if bounceRate > forecastMaxBounceRate {
+			risk(fmt.Sprintf("bounce rate of %.1f%%", 100*bounceRate), "Clean your lists of invalid addresses before sending; consider validating them first.")
+		}
+	}
+	if stats.Delivered > 0 {
+		complaintRate := float64(stats.Complained) / float64(stats.Delivered)
+		spamRate += 20 * complaintRate
+		if complaintRate > forecastMaxComplaintRate {
+			risk(fmt.Sprintf("complaint rate of %.2f%%", 100*complaintRate), "Send only to recipients who opted in, and make unsubscribing easy.")
+		}
+	}
+
+	switch {
+	case stats.Accepted >= forecastSteadyVolume:
+		f.ConfidenceLevel = "high"
+	case stats.Accepted >= forecastWarmupVolume:
+		f.ConfidenceLevel = "medium"
+	default:
+		f.ConfidenceLevel = "low"
+		spamRate += 0.05
+		risk("little recent sending history", "Warm the domain up by increasing volume gradually over several weeks.")
+	}
+
+	if d.State != "" && d.State != "active" {
+		deliveryRate = 0
+		risk("domain is "+d.State, "Contact Mailgun support to reactivate the domain.")
+	}
+
+	if spamRate > 1 {
+		spamRate = 1
+	}
+	f.ExpectedSpamRate = deliveryRate * spamRate
+	f.ExpectedInboxRate = deliveryRate * (1 - spamRate)
+	return f
+}
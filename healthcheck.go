@@ -0,0 +1,81 @@
+package mailgun
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// A HealthCheckResult describes the outcome of a HealthCheck, for reporting to dashboards and metrics systems.
+// Latency gives the round-trip time of the API call, and Err the error it failed with, if any.
+// APIVersion and Region describe the API the client talks to, such as "v3" in RegionUS;
+// Region is blank for servers other than Mailgun's own, as chosen with WithAPIBase.
+// RateLimitRemaining gives the number of API calls the response reported left in the current period,
+// or -1 if it reported no rate limit.
+type HealthCheckResult struct {
+	Latency            time.Duration
+	APIVersion         string
+	Region             Region
+	RateLimitRemaining int
+	Err                error
+}
+
+// WithHealthCheckHook installs a function to call with the outcome of every HealthCheck,
+// successful or not, as for exporting it to Prometheus.
+// The hook runs on the goroutine which called HealthCheck, so it should return promptly.
+func WithHealthCheckHook(hook func(HealthCheckResult)) Option {
+	return func(m *MailgunImpl) {
+		m.healthCheckHook = hook
+	}
+}
+
+// HealthCheck verifies that the Mailgun API is reachable and accepts the client's credentials,
+// as for readiness probes, by making a single inexpensive API call.  It returns the call's round-trip latency.
+// The call is never retried, whatever the client's RetryPolicy, so that the latency is that of one call.
+func (m *MailgunImpl) HealthCheck(ctx context.Context) (time.Duration, error) {
+	r := newHTTPRequest(generatePublicApiUrl(m, domainsEndpoint))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	r.addParameter("limit", "1")
+	r.addHeader("User-Agent", MailgunGoUserAgent)
+
+	start := time.Now()
+	rsp, err := checkedRequest(r, "GET", nil)
+	result := HealthCheckResult{
+		Latency:            time.Since(start),
+		RateLimitRemaining: -1,
+		Err:                err,
+	}
+	result.APIVersion, result.Region = describeApiBase(m.ApiBase())
+	if rsp != nil {
+		if status, ok := parseRateLimit(rsp.Header); ok {
+			result.RateLimitRemaining = status.Remaining
+		}
+	}
+	if m.healthCheckHook != nil {
+		m.healthCheckHook(result)
+	}
+	return result.Latency, err
+}
+
+// describeApiBase works out the API version and region of a base URL, such as https://api.eu.mailgun.net/v3.
+func describeApiBase(base string) (string, Region) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", ""
+	}
+	version := path.Base(u.Path)
+	if !strings.HasPrefix(version, "v") {
+		version = ""
+	}
+	switch base {
+	case apiBase:
+		return version, RegionUS
+	case apiBaseEU:
+		return version, RegionEU
+	}
+	return version, ""
+}
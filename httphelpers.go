@@ -2,14 +2,18 @@ package mailgun
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"os"
 	"path"
+	"time"
 )
 
 type httpRequest struct {
@@ -19,11 +23,14 @@ type httpRequest struct {
 	BasicAuthUser     string
 	BasicAuthPassword string
 	Client            *http.Client
+	Context           context.Context
+	owner             *MailgunImpl
 }
 
 type httpResponse struct {
-	Code int
-	Data []byte
+	Code   int
+	Header http.Header
+	Data   []byte
 }
 
 type payload interface {
@@ -37,9 +44,10 @@ type keyValuePair struct {
 }
 
 type keyNameRC struct {
-	key   string
-	name  string
-	value io.ReadCloser
+	key         string
+	name        string
+	contentType string
+	value       io.ReadCloser
 }
 
 type formDataPayload struct {
@@ -53,6 +61,10 @@ type urlEncodedPayload struct {
 	Values []keyValuePair
 }
 
+type jsonPayload struct {
+	data []byte
+}
+
 func newHTTPRequest(url string) *httpRequest {
 	return &httpRequest{URL: url, Client: http.DefaultClient}
 }
@@ -64,8 +76,15 @@ func (r *httpRequest) addParameter(name, value string) {
 	r.Parameters[name] = append(r.Parameters[name], value)
 }
 
-func (r *httpRequest) setClient(c *http.Client) {
-	r.Client = c
+// setClient arranges for the request to go through the HTTP client configured for m.
+// If m is a MailgunImpl, it also gets to observe the response; see observeResponse.
+func (r *httpRequest) setClient(m Mailgun) {
+	r.Client = m.Client()
+	r.owner, _ = m.(*MailgunImpl)
+}
+
+func (r *httpRequest) setContext(ctx context.Context) {
+	r.Context = ctx
 }
 
 func (r *httpRequest) setBasicAuth(user, password string) {
@@ -93,6 +112,22 @@ func (f *urlEncodedPayload) getContentType() string {
 	return "application/x-www-form-urlencoded"
 }
 
+func newJSONPayload(v interface{}) (*jsonPayload, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonPayload{data: data}, nil
+}
+
+func (j *jsonPayload) getPayloadBuffer() (*bytes.Buffer, error) {
+	return bytes.NewBuffer(j.data), nil
+}
+
+func (j *jsonPayload) getContentType() string {
+	return "application/json"
+}
+
 func (r *httpResponse) parseFromJSON(v interface{}) error {
 	return json.Unmarshal(r.Data, v)
 }
@@ -110,7 +145,13 @@ func (f *formDataPayload) addFile(key, file string) {
 }
 
 func (f *formDataPayload) addReadCloser(key, name string, rc io.ReadCloser) {
-	f.ReadClosers = append(f.ReadClosers, keyNameRC{key: key, name: name, value: rc})
+	f.addReadCloserWithType(key, name, "", rc)
+}
+
+// addReadCloserWithType works as addReadCloser, but labels the file with the given MIME type.
+// An empty contentType leaves the file labeled application/octet-stream.
+func (f *formDataPayload) addReadCloserWithType(key, name, contentType string, rc io.ReadCloser) {
+	f.ReadClosers = append(f.ReadClosers, keyNameRC{key: key, name: name, contentType: contentType, value: rc})
 }
 
 func (f *formDataPayload) getPayloadBuffer() (*bytes.Buffer, error) {
@@ -140,7 +181,7 @@ func (f *formDataPayload) getPayloadBuffer() (*bytes.Buffer, error) {
 	}
 
 	for _, file := range f.ReadClosers {
-		if tmp, err := writer.CreateFormFile(file.key, file.name); err == nil {
+		if tmp, err := createFormFile(writer, file.key, file.name, file.contentType); err == nil {
 			defer file.value.Close()
 			io.Copy(tmp, file.value)
 		} else {
@@ -153,6 +194,17 @@ func (f *formDataPayload) getPayloadBuffer() (*bytes.Buffer, error) {
 	return data, nil
 }
 
+// createFormFile works as multipart.Writer.CreateFormFile, but allows the part's content type to be given.
+func createFormFile(w *multipart.Writer, key, name, contentType string) (io.Writer, error) {
+	if contentType == "" {
+		return w.CreateFormFile(key, name)
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": key, "filename": name}))
+	h.Set("Content-Type", contentType)
+	return w.CreatePart(h)
+}
+
 func (f *formDataPayload) getContentType() string {
 	if f.contentType == "" {
 		f.getPayloadBuffer()
@@ -184,6 +236,76 @@ func (r *httpRequest) makeDeleteRequest() (*httpResponse, error) {
 }
 
 func (r *httpRequest) makeRequest(method string, payload payload) (*httpResponse, error) {
+	req, err := r.newRequest(method, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	response := httpResponse{}
+
+	start := time.Now()
+	resp, err := r.Client.Do(req)
+	if resp != nil {
+		response.Code = resp.StatusCode
+		response.Header = resp.Header
+	}
+	if err != nil {
+		return nil, r.transportError(req, start, err)
+	}
+	if r.owner != nil {
+		r.owner.observeResponse(resp)
+	}
+
+	defer resp.Body.Close()
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if r.owner != nil {
+		r.owner.logCall(req, resp, responseBody, start, err)
+	}
+	if err != nil {
+		if r.Context != nil && r.Context.Err() != nil {
+			return nil, r.Context.Err()
+		}
+		return nil, newTransportError(err)
+	}
+
+	response.Data = responseBody
+	return &response, nil
+}
+
+// makeStreamRequest works as makeRequest, but hands back the response without reading its body,
+// for the caller to stream from the connection and close.
+// As the body goes unread, the client's logger sees the response without it.
+func (r *httpRequest) makeStreamRequest(method string) (*http.Response, error) {
+	req, err := r.newRequest(method, nil)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, r.transportError(req, start, err)
+	}
+	if r.owner != nil {
+		r.owner.observeResponse(resp)
+		r.owner.logCall(req, resp, nil, start, nil)
+	}
+	return resp, nil
+}
+
+// transportError logs and reports a request which got no response at all.
+func (r *httpRequest) transportError(req *http.Request, start time.Time, err error) error {
+	if r.owner != nil {
+		r.owner.logCall(req, nil, nil, start, err)
+	}
+	// Report cancellation plainly, rather than wrapped up in a *url.Error.
+	if r.Context != nil && r.Context.Err() != nil {
+		return r.Context.Err()
+	}
+	return newTransportError(err)
+}
+
+// newRequest builds the HTTP request to send, with the request's parameters, headers, and credentials.
+func (r *httpRequest) newRequest(method string, payload payload) (*http.Request, error) {
 	url, err := r.generateUrlWithParameters()
 	if err != nil {
 		return nil, err
@@ -202,6 +324,9 @@ func (r *httpRequest) makeRequest(method string, payload payload) (*httpResponse
 	if err != nil {
 		return nil, err
 	}
+	if r.Context != nil {
+		req = req.WithContext(r.Context)
+	}
 
 	if payload != nil && payload.getContentType() != "" {
 		req.Header.Add("Content-Type", payload.getContentType())
@@ -214,25 +339,10 @@ func (r *httpRequest) makeRequest(method string, payload payload) (*httpResponse
 	for header, value := range r.Headers {
 		req.Header.Add(header, value)
 	}
-
-	response := httpResponse{}
-
-	resp, err := r.Client.Do(req)
-	if resp != nil {
-		response.Code = resp.StatusCode
-	}
-	if err != nil {
-		return nil, err
+	if r.owner != nil && r.owner.subaccount != "" {
+		req.Header.Set(onBehalfOfHeader, r.owner.subaccount)
 	}
-
-	defer resp.Body.Close()
-	responseBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	response.Data = responseBody
-	return &response, nil
+	return req, nil
 }
 
 func (r *httpRequest) generateUrlWithParameters() (string, error) {
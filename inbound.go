@@ -0,0 +1,119 @@
+package mailgun
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// inboundMaxMemory bounds the memory ParseInboundMessage holds attachments in; larger ones go to temporary files.
+const inboundMaxMemory = 32 << 20
+
+// An InboundMessage holds a message Mailgun received for the domain, as a route's forward() action posts it.
+// Sender and Recipient give the SMTP envelope addresses; From gives the From: header.
+// BodyPlain and BodyHtml hold the message's bodies, and StrippedText the plain-text body
+// without quoted replies or signature.
+// MessageHeaders lists the message's MIME headers as name-value pairs, in their original order.
+// Timestamp and Token are those Mailgun signed the post with.
+type InboundMessage struct {
+	Sender         string
+	Recipient      string
+	From           string
+	Subject        string
+	BodyPlain      string
+	StrippedText   string
+	BodyHtml       string
+	Attachments    []InboundAttachment
+	MessageHeaders [][]string
+	Timestamp      time.Time
+	Token          string
+}
+
+// An InboundAttachment describes a file attached to an inbound message.
+// Size gives the length of its content in bytes.
+type InboundAttachment struct {
+	Name        string
+	ContentType string
+	Size        int64
+
+	file *multipart.FileHeader
+}
+
+// Open streams the content of the attachment.
+// The content lasts only as long as the request it came with: once the handler returns,
+// the server removes the temporary files holding larger attachments.
+// A failure to open the content is reported by the first Read.
+func (a InboundAttachment) Open() io.ReadCloser {
+	if a.file == nil {
+		return ioutil.NopCloser(errorReader{fmt.Errorf("attachment %q has no content", a.Name)})
+	}
+	f, err := a.file.Open()
+	if err != nil {
+		return ioutil.NopCloser(errorReader{err})
+	}
+	return f
+}
+
+// errorReader fails every Read with its error.
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read(_ []byte) (int, error) {
+	return 0, r.err
+}
+
+// ParseInboundMessage decodes the multipart/form-data request a route's forward() action posts for an inbound message,
+// checking its signature against the client's API key.
+// ErrInvalidWebhookSignature results if the signature isn't genuine;
+// as for ParseWebhookPayload, a genuine request may still be a replay.
+func (mg *MailgunImpl) ParseInboundMessage(r *http.Request) (*InboundMessage, error) {
+	if err := r.ParseMultipartForm(inboundMaxMemory); err != nil && err != http.ErrNotMultipart {
+		return nil, err
+	}
+	timestamp, token := r.FormValue("timestamp"), r.FormValue("token")
+	if !VerifyWebhookSignature(mg.ApiKey(), token, timestamp, r.FormValue("signature")) {
+		return nil, ErrInvalidWebhookSignature
+	}
+
+	m := &InboundMessage{
+		Sender:       r.FormValue("sender"),
+		Recipient:    r.FormValue("recipient"),
+		From:         r.FormValue("from"),
+		Subject:      r.FormValue("subject"),
+		BodyPlain:    r.FormValue("body-plain"),
+		StrippedText: r.FormValue("stripped-text"),
+		BodyHtml:     r.FormValue("body-html"),
+		Token:        token,
+	}
+	if secs, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+		m.Timestamp = time.Unix(secs, 0)
+	}
+	if headers := r.FormValue("message-headers"); headers != "" {
+		if err := json.Unmarshal([]byte(headers), &m.MessageHeaders); err != nil {
+			return nil, fmt.Errorf("malformed message-headers: %v", err)
+		}
+	}
+	if r.MultipartForm != nil {
+		// Mailgun numbers the attachments from one: attachment-1, attachment-2, and so on.
+		for i := 1; ; i++ {
+			files := r.MultipartForm.File["attachment-"+strconv.Itoa(i)]
+			if len(files) == 0 {
+				break
+			}
+			f := files[0]
+			m.Attachments = append(m.Attachments, InboundAttachment{
+				Name:        f.Filename,
+				ContentType: f.Header.Get("Content-Type"),
+				Size:        f.Size,
+				file:        f,
+			})
+		}
+	}
+	return m, nil
+}
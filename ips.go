@@ -0,0 +1,134 @@
+package mailgun
+
+import "context"
+
+const (
+	ipsEndpoint     = "ips"
+	ipPoolsEndpoint = "ip_pools"
+)
+
+// An IP describes one of the IP addresses Mailgun sends the account's mail from.
+// RDNS gives the host name the address resolves to in reverse DNS,
+// and Dedicated reports whether the address sends for this account alone, rather than being shared.
+type IP struct {
+	IP        string `json:"ip"`
+	RDNS      string `json:"rdns"`
+	Dedicated bool   `json:"dedicated"`
+}
+
+// An IPPool groups dedicated IP addresses, so that domains may send from the pool as a whole.
+type IPPool struct {
+	ID          string   `json:"pool_id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	IPs         []string `json:"ips"`
+}
+
+type ipAddressListEnvelope struct {
+	TotalCount int      `json:"total_count"`
+	Items      []string `json:"items"`
+}
+
+// GetIPs lists the IP addresses the account sends from; pass true to list only its dedicated addresses.
+// Mailgun lists the addresses alone, so only the IP field of each is set; use GetIP for the rest.
+func (m *MailgunImpl) GetIPs(ctx context.Context, dedicated bool) ([]IP, error) {
+	r := newHTTPRequest(generatePublicApiUrl(m, ipsEndpoint))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	if dedicated {
+		r.addParameter("dedicated", "true")
+	}
+	return getIPList(r)
+}
+
+// GetIP retrieves the details of one of the IP addresses the account sends from.
+func (m *MailgunImpl) GetIP(ctx context.Context, ip string) (*IP, error) {
+	if ip == "" {
+		return nil, ErrEmptyParam
+	}
+	r := newHTTPRequest(generatePublicApiUrl(m, ipsEndpoint) + "/" + ip)
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+
+	var response IP
+	if err := getResponseFromJSON(r, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetDomainIPs lists the IP addresses the named domain sends from.
+// As with GetIPs, only the IP field of each is set.
+func (m *MailgunImpl) GetDomainIPs(ctx context.Context, domain string) ([]IP, error) {
+	r := newHTTPRequest(generateDomainIPsUrl(m, domain))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	return getIPList(r)
+}
+
+// AssignIPToDomain has the named domain send from the given address, one of the account's dedicated IPs.
+func (m *MailgunImpl) AssignIPToDomain(ctx context.Context, domain, ip string) error {
+	if ip == "" {
+		return ErrEmptyParam
+	}
+	r := newHTTPRequest(generateDomainIPsUrl(m, domain))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+
+	payload := newUrlEncodedPayload()
+	payload.addValue("ip", ip)
+	_, err := makePostRequest(r, payload)
+	return err
+}
+
+// DeleteDomainIP stops the named domain sending from the given address.
+// The address itself remains with the account.
+func (m *MailgunImpl) DeleteDomainIP(ctx context.Context, domain, ip string) error {
+	if ip == "" {
+		return ErrEmptyParam
+	}
+	r := newHTTPRequest(generateDomainIPsUrl(m, domain) + "/" + ip)
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	_, err := makeDeleteRequest(r)
+	return err
+}
+
+// GetIPPools lists the account's pools of dedicated IP addresses.
+func (m *MailgunImpl) GetIPPools(ctx context.Context) ([]IPPool, error) {
+	r := newHTTPRequest(generateV1ApiUrl(m, ipPoolsEndpoint))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+
+	var envelope struct {
+		IPPools []IPPool `json:"ip_pools"`
+	}
+	if err := getResponseFromJSON(r, &envelope); err != nil {
+		return nil, err
+	}
+	return envelope.IPPools, nil
+}
+
+// getIPList fetches a list of bare IP addresses.
+func getIPList(r *httpRequest) ([]IP, error) {
+	var envelope ipAddressListEnvelope
+	if err := getResponseFromJSON(r, &envelope); err != nil {
+		return nil, err
+	}
+	ips := make([]IP, len(envelope.Items))
+	for i, ip := range envelope.Items {
+		ips[i] = IP{IP: ip}
+	}
+	return ips, nil
+}
+
+// generateDomainIPsUrl generates the URL of the list of IP addresses the named domain sends from.
+func generateDomainIPsUrl(m Mailgun, domain string) string {
+	return generatePublicApiUrl(m, domainsEndpoint) + "/" + domain + "/" + ipsEndpoint
+}
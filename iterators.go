@@ -0,0 +1,276 @@
+package mailgun
+
+import (
+	"context"
+	"strconv"
+)
+
+// ListOptions adjusts how the List methods, such as ListUnsubscribes, page through their results.
+// Limit sets the number of items fetched per API call; if left unspecified, Mailgun assumes 100, and allows at most 1000.
+// Subscribed narrows ListMembers to the members with that subscription status, as GetMembers's filter does:
+// All, the default, lists every member, while Subscribed and Unsubscribed list only those eponymous subsets.
+// The other List methods ignore it.
+type ListOptions struct {
+	Limit      int
+	Subscribed *bool
+}
+
+// pageIterator holds the state shared by the iterators over Mailgun's paged lists:
+// the URL of the next page, and the error which stopped the iteration, if any.
+// Mailgun reports the end of a list with an empty page.
+type pageIterator struct {
+	mg   Mailgun
+	next string
+	err  error
+}
+
+func newPageIterator(mg Mailgun, url string, opts ListOptions) pageIterator {
+	if opts.Limit > 0 {
+		url += "?limit=" + strconv.Itoa(opts.Limit)
+	}
+	return pageIterator{mg: mg, next: url}
+}
+
+// fetchPage decodes the next page of the list into items, which must point to a slice.
+// It returns false if the list has run out, or the fetch failed.
+func (pi *pageIterator) fetchPage(ctx context.Context, items interface{}, count func() int) bool {
+	if pi.err != nil || pi.next == "" {
+		return false
+	}
+	r := newHTTPRequest(pi.next)
+	r.setContext(ctx)
+	r.setClient(pi.mg)
+	r.setBasicAuth(basicAuthUser, pi.mg.ApiKey())
+	var envelope struct {
+		Items  interface{} `json:"items"`
+		Paging struct {
+			Next string `json:"next"`
+		} `json:"paging"`
+	}
+	envelope.Items = items
+	if err := getResponseFromJSON(r, &envelope); err != nil {
+		pi.err = err
+		return false
+	}
+	if count() == 0 || envelope.Paging.Next == pi.next {
+		pi.next = ""
+	} else {
+		pi.next = envelope.Paging.Next
+	}
+	return count() > 0
+}
+
+// Err returns the error that stopped Next, if any; it's nil if Next stopped for want of items.
+func (pi *pageIterator) Err() error {
+	return pi.err
+}
+
+// UnsubscribeIterator walks through a domain's unsubscriptions, fetching pages of them as needed:
+//
+//	it := mg.ListUnsubscribes(domain, mailgun.ListOptions{})
+//	for it.Next(ctx) {
+//		u := it.Item()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type UnsubscribeIterator struct {
+	pageIterator
+	page  []Unsubscription
+	index int
+}
+
+// ListUnsubscribes creates an iterator over the named domain's unsubscriptions.
+// Nothing is fetched until the first call to Next.
+func (mg *MailgunImpl) ListUnsubscribes(domain string, opts ListOptions) *UnsubscribeIterator {
+	return &UnsubscribeIterator{pageIterator: newPageIterator(mg, generateApiUrlWithDomain(mg, domain, unsubscribesEndpoint), opts)}
+}
+
+// Next advances to the next unsubscription, fetching the next page of them if need be.
+// It returns false once they run out, the context ends, or an API call fails; use Err to tell these cases apart.
+func (it *UnsubscribeIterator) Next(ctx context.Context) bool {
+	if it.index+1 < len(it.page) {
+		it.index++
+		return true
+	}
+	var page []Unsubscription
+	if !it.fetchPage(ctx, &page, func() int { return len(page) }) {
+		it.page, it.index = nil, 0
+		return false
+	}
+	it.page, it.index = page, 0
+	return true
+}
+
+// Item returns the unsubscription Next advanced to.
+func (it *UnsubscribeIterator) Item() Unsubscription {
+	return it.page[it.index]
+}
+
+// BounceIterator walks through a domain's bounces, in the manner of UnsubscribeIterator.
+type BounceIterator struct {
+	pageIterator
+	page  []Bounce
+	index int
+}
+
+// ListBounces creates an iterator over the named domain's bounces.
+// Nothing is fetched until the first call to Next.
+func (mg *MailgunImpl) ListBounces(domain string, opts ListOptions) *BounceIterator {
+	return &BounceIterator{pageIterator: newPageIterator(mg, generateApiUrlWithDomain(mg, domain, bouncesEndpoint), opts)}
+}
+
+// Next advances to the next bounce, fetching the next page of them if need be.
+// It returns false once they run out, the context ends, or an API call fails; use Err to tell these cases apart.
+func (it *BounceIterator) Next(ctx context.Context) bool {
+	if it.index+1 < len(it.page) {
+		it.index++
+		return true
+	}
+	var page []Bounce
+	if !it.fetchPage(ctx, &page, func() int { return len(page) }) {
+		it.page, it.index = nil, 0
+		return false
+	}
+	it.page, it.index = page, 0
+	return true
+}
+
+// Item returns the bounce Next advanced to.
+func (it *BounceIterator) Item() Bounce {
+	return it.page[it.index]
+}
+
+// ComplaintIterator walks through a domain's spam complaints, in the manner of UnsubscribeIterator.
+type ComplaintIterator struct {
+	pageIterator
+	page  []Complaint
+	index int
+}
+
+// ListComplaints creates an iterator over the named domain's spam complaints.
+// Nothing is fetched until the first call to Next.
+func (mg *MailgunImpl) ListComplaints(domain string, opts ListOptions) *ComplaintIterator {
+	return &ComplaintIterator{pageIterator: newPageIterator(mg, generateApiUrlWithDomain(mg, domain, complaintsEndpoint), opts)}
+}
+
+// Next advances to the next complaint, fetching the next page of them if need be.
+// It returns false once they run out, the context ends, or an API call fails; use Err to tell these cases apart.
+func (it *ComplaintIterator) Next(ctx context.Context) bool {
+	if it.index+1 < len(it.page) {
+		it.index++
+		return true
+	}
+	var page []Complaint
+	if !it.fetchPage(ctx, &page, func() int { return len(page) }) {
+		it.page, it.index = nil, 0
+		return false
+	}
+	it.page, it.index = page, 0
+	return true
+}
+
+// Item returns the complaint Next advanced to.
+func (it *ComplaintIterator) Item() Complaint {
+	return it.page[it.index]
+}
+
+// MemberIterator walks through the members of a mailing list, in the manner of UnsubscribeIterator.
+type MemberIterator struct {
+	pageIterator
+	page  []Member
+	index int
+}
+
+// ListMembers creates an iterator over the members of the mailing list with the given address,
+// or over those with the subscription status opts.Subscribed names, if any.
+// Nothing is fetched until the first call to Next.
+func (mg *MailgunImpl) ListMembers(listAddress string, opts ListOptions) *MemberIterator {
+	it := &MemberIterator{pageIterator: newPageIterator(mg, generateMemberApiUrl(mg, listsEndpoint, listAddress)+"/pages", opts)}
+	if opts.Subscribed != nil {
+		sep := "?"
+		if opts.Limit > 0 {
+			sep = "&"
+		}
+		it.next += sep + "subscribed=" + yesNo(*opts.Subscribed)
+	}
+	return it
+}
+
+// Next advances to the next member, fetching the next page of them if need be.
+// It returns false once they run out, the context ends, or an API call fails; use Err to tell these cases apart.
+func (it *MemberIterator) Next(ctx context.Context) bool {
+	if it.index+1 < len(it.page) {
+		it.index++
+		return true
+	}
+	var page []Member
+	if !it.fetchPage(ctx, &page, func() int { return len(page) }) {
+		it.page, it.index = nil, 0
+		return false
+	}
+	it.page, it.index = page, 0
+	return true
+}
+
+// Item returns the member Next advanced to.
+func (it *MemberIterator) Item() Member {
+	return it.page[it.index]
+}
+
+// RouteIterator walks through the account's routes, in the manner of UnsubscribeIterator.
+// Mailgun pages through routes by count rather than by link, so it doesn't share pageIterator.
+type RouteIterator struct {
+	mg    *MailgunImpl
+	limit int
+	skip  int
+	done  bool
+	err   error
+	page  []Route
+	index int
+}
+
+// ListRoutes creates an iterator over the account's routes, fetching pages of them as needed.
+// Nothing is fetched until the first call to Next.
+func (mg *MailgunImpl) ListRoutes(opts ListOptions) *RouteIterator {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	return &RouteIterator{mg: mg, limit: limit}
+}
+
+// Next advances to the next route, fetching the next page of them if need be.
+// It returns false once they run out, the context ends, or an API call fails; use Err to tell these cases apart.
+func (it *RouteIterator) Next(ctx context.Context) bool {
+	if it.index+1 < len(it.page) {
+		it.index++
+		return true
+	}
+	it.page, it.index = nil, 0
+	if it.done || it.err != nil {
+		return false
+	}
+	total, page, err := it.mg.getRoutes(ctx, it.limit, it.skip)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.skip += len(page)
+	if len(page) == 0 || it.skip >= total {
+		it.done = true
+	}
+	it.page = page
+	return len(page) > 0
+}
+
+// Item returns the route Next advanced to.
+func (it *RouteIterator) Item() Route {
+	return it.page[it.index]
+}
+
+// Err returns the error that stopped Next, if any; it's nil if Next stopped for want of routes.
+func (it *RouteIterator) Err() error {
+	return it.err
+}
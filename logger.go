@@ -0,0 +1,184 @@
+package mailgun
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Logger observes every API call a client makes, as WithLogger arranges.
+// Log receives the request as sent, the response as received, and the time the call took;
+// resp is nil, and err set, if no response arrived.
+// Either body may be read: req.GetBody gives the request's body, and resp.Body the response's, which need not be closed.
+// The Authorization header, which carries the API key, is redacted before Log sees the request,
+// as are passwords sent as form values.
+// Log runs on the goroutine which made the call, so it should return promptly.
+type Logger interface {
+	Log(req *http.Request, resp *http.Response, elapsed time.Duration, err error)
+}
+
+// WithLogger arranges for the client to report every API call it makes to the given logger.
+// See NewDefaultLogger for a logger which writes calls out as text.
+func WithLogger(l Logger) Option {
+	return func(m *MailgunImpl) {
+		m.logger = l
+	}
+}
+
+// A ProgressLogger is a Logger which also hears how long-running operations, such as ExportMailingList, progress.
+// A client whose logger (see WithLogger) is a ProgressLogger reports progress to it;
+// the loggers NewDefaultLogger creates are ProgressLoggers.
+// LogProgress, like Log, runs on the goroutine doing the work.
+type ProgressLogger interface {
+	Logger
+	LogProgress(msg string)
+}
+
+// logProgress reports the progress of a long-running operation, if the client's logger is a ProgressLogger.
+func (m *MailgunImpl) logProgress(format string, args ...interface{}) {
+	if pl, ok := m.logger.(ProgressLogger); ok {
+		pl.LogProgress(fmt.Sprintf(format, args...))
+	}
+}
+
+// A LogLevel sets how much of each API call NewDefaultLogger writes out.
+type LogLevel int
+
+const (
+	// LogLevelInfo writes out the method, URL, response status, and elapsed time of each call.
+	LogLevelInfo LogLevel = iota
+	// LogLevelDebug writes out the request and response headers and bodies of each call as well.
+	LogLevelDebug
+)
+
+// redacted stands in for sensitive values the client logs.
+const redacted = "[REDACTED]"
+
+type defaultLogger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level LogLevel
+}
+
+// NewDefaultLogger creates a Logger which writes a line for every API call to w, as level directs:
+//
+//	mailgun: POST https://api.mailgun.net/v3/example.com/messages 200 OK 153ms
+//
+// Failed calls end with the error instead.
+// At LogLevelDebug, the headers and bodies of the request and response follow, indented.
+// It's safe for concurrent use, provided nothing else writes to w.
+func NewDefaultLogger(w io.Writer, level LogLevel) Logger {
+	return &defaultLogger{w: w, level: level}
+}
+
+func (l *defaultLogger) Log(req *http.Request, resp *http.Response, elapsed time.Duration, err error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "mailgun: %s %s", req.Method, req.URL)
+	if resp != nil {
+		fmt.Fprintf(&b, " %s", resp.Status)
+	}
+	fmt.Fprintf(&b, " %s", elapsed.Round(time.Millisecond))
+	if err != nil {
+		fmt.Fprintf(&b, " error: %v", err)
+	}
+	b.WriteByte('\n')
+
+	if l.level >= LogLevelDebug {
+		writeLogHeaders(&b, "> ", req.Header)
+		if req.GetBody != nil {
+			if body, err := req.GetBody(); err == nil {
+				writeLogBody(&b, "> ", body)
+			}
+		}
+		if resp != nil {
+			writeLogHeaders(&b, "< ", resp.Header)
+			writeLogBody(&b, "< ", resp.Body)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(b.Bytes())
+}
+
+// LogProgress writes the message out on a line of its own, at every level:
+//
+//	mailgun: exported 200 members of list@example.com
+func (l *defaultLogger) LogProgress(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.w, "mailgun: %s\n", msg)
+}
+
+func writeLogHeaders(b *bytes.Buffer, prefix string, h http.Header) {
+	for name, values := range h {
+		for _, v := range values {
+			fmt.Fprintf(b, "\t%s%s: %s\n", prefix, name, v)
+		}
+	}
+}
+
+func writeLogBody(b *bytes.Buffer, prefix string, body io.Reader) {
+	data, err := ioutil.ReadAll(body)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\r\n"), "\n") {
+		fmt.Fprintf(b, "\t%s%s\n", prefix, strings.TrimRight(line, "\r"))
+	}
+}
+
+// logCall reports an API call to the client's logger, if it has one.
+// The response body, if any, must already have been read into data.
+func (m *MailgunImpl) logCall(req *http.Request, resp *http.Response, data []byte, start time.Time, err error) {
+	if m.logger == nil {
+		return
+	}
+	elapsed := time.Since(start)
+	logged := redactRequest(req)
+	if resp != nil {
+		r := *resp
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+		resp = &r
+	}
+	m.logger.Log(logged, resp, elapsed, err)
+}
+
+// redactRequest copies a request for logging, hiding its credentials.
+func redactRequest(req *http.Request) *http.Request {
+	logged := req.Clone(req.Context())
+	if logged.Header.Get("Authorization") != "" {
+		logged.Header.Set("Authorization", redacted)
+	}
+	if req.GetBody == nil || !strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return logged
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return logged
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return logged
+	}
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return logged
+	}
+	for key := range values {
+		if strings.Contains(strings.ToLower(key), "password") {
+			values.Set(key, redacted)
+		}
+	}
+	encoded := values.Encode()
+	logged.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(encoded)), nil
+	}
+	return logged
+}
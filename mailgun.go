@@ -93,14 +93,19 @@
 package mailgun
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
 const (
-	apiBase                 = "https://api.mailgun.net/v2"
+	apiBase                 = "https://api.mailgun.net/v3"
+	apiBaseEU               = "https://api.eu.mailgun.net/v3"
 	messagesEndpoint        = "messages"
 	mimeMessagesEndpoint    = "messages.mime"
 	addressValidateEndpoint = "address/validate"
@@ -108,7 +113,7 @@ const (
 	bouncesEndpoint         = "bounces"
 	statsEndpoint           = "stats"
 	domainsEndpoint         = "domains"
-	deleteTagEndpoint       = "tags"
+	tagsEndpoint            = "tags"
 	campaignsEndpoint       = "campaigns"
 	eventsEndpoint          = "events"
 	credentialsEndpoint     = "credentials"
@@ -131,50 +136,131 @@ type Mailgun interface {
 	Domain() string
 	ApiKey() string
 	PublicApiKey() string
+	ApiBase() string
 	Client() *http.Client
 	SetClient(client *http.Client)
-	Send(m *Message) (string, string, error)
+	GetRateLimitStatus(ctx context.Context) (*RateLimitStatus, error)
+	SetRateLimitUpdateHook(hook func(RateLimitStatus))
+	HealthCheck(ctx context.Context) (time.Duration, error)
+	Send(ctx context.Context, m *Message) (string, string, error)
+	SendWithTemplate(ctx context.Context, m *Message, templateName, version string, vars map[string]interface{}) (string, string, error)
+	SendWithIdempotencyKey(ctx context.Context, m *Message, key string) (string, string, error)
 	ValidateEmail(email string) (EmailVerification, error)
+	SendBatch(ctx context.Context, messages []*Message, opts BatchOptions) ([]string, error)
+	ScheduleBatch(ctx context.Context, messages []*Message, scheduledFor time.Time, opts BatchOptions) ([]string, error)
+	BatchSend(m *Message, chunkSize int) ([]BatchResult, error)
+	BatchSendContext(ctx context.Context, m *Message, chunkSize int) ([]BatchResult, error)
+	GetDeliveryForecast(ctx context.Context, domain string) (*DeliveryForecast, error)
+	DiagnoseDelivery(ctx context.Context, domain, from, to string) (*DiagnosticReport, error)
+	SendVerificationMessage(ctx context.Context, domain string) (string, error)
+	CreateTransactionalMessage(ctx context.Context, spec TransactionalSpec) (string, string, error)
+	ValidateAddress(ctx context.Context, address string) (*EmailValidation, error)
+	ValidateEmailBulk(ctx context.Context, addresses []string) (*BulkValidation, error)
+	GetBulkValidation(ctx context.Context, jobID string) (*BulkValidation, error)
+	ValidatePhone(ctx context.Context, phone string) (*PhoneValidation, error)
+	ValidateAndSend(ctx context.Context, m *Message, opts ValidationOptions) (string, string, error)
 	ParseAddresses(addresses ...string) ([]string, []string, error)
 	GetBounces(limit, skip int) (int, []Bounce, error)
 	GetSingleBounce(address string) (Bounce, error)
 	AddBounce(address, code, error string) error
 	DeleteBounce(address string) error
+	ImportBounces(ctx context.Context, bounces []Bounce) error
+	ExportBounces(ctx context.Context, domain string) (io.ReadCloser, error)
+	WriteBouncesCSV(ctx context.Context, domain string, w io.Writer) error
+	GetAggregateStats(ctx context.Context, domains []string, opts StatsOptions) (map[string]*DomainStats, error)
 	GetStats(limit int, skip int, startDate *time.Time, event ...string) (int, []Stat, error)
+	GetStatsTotal(ctx context.Context, domain string, opts StatsOptions) ([]StatPoint, error)
+	GetDomainStats(ctx context.Context, domain string, opts StatsOptions) (*TotalStats, error)
 	DeleteTag(tag string) error
+	GetTags(ctx context.Context, domain string, limit int) ([]TagItem, error)
+	GetTag(ctx context.Context, domain, tag string) (*TagItem, error)
+	DeleteDomainTag(ctx context.Context, domain, tag string) error
 	GetDomains(limit, skip int) (int, []Domain, error)
 	GetSingleDomain(domain string) (Domain, []DNSRecord, []DNSRecord, error)
 	CreateDomain(name string, smtpPassword string, spamAction string, wildcard bool) error
+	AddDomain(ctx context.Context, name string, opts DomainOptions) (*Domain, error)
+	GetDomain(ctx context.Context, name string) (*Domain, []DNSRecord, error)
 	DeleteDomain(name string) error
+	GetIPs(ctx context.Context, dedicated bool) ([]IP, error)
+	GetIP(ctx context.Context, ip string) (*IP, error)
+	GetDomainIPs(ctx context.Context, domain string) ([]IP, error)
+	AssignIPToDomain(ctx context.Context, domain, ip string) error
+	DeleteDomainIP(ctx context.Context, domain, ip string) error
+	GetIPPools(ctx context.Context) ([]IPPool, error)
 	GetCampaigns() (int, []Campaign, error)
 	CreateCampaign(name, id string) error
 	UpdateCampaign(oldId, name, newId string) error
 	DeleteCampaign(id string) error
+	GetDomainCampaigns(ctx context.Context, domain string, limit, skip int) ([]Campaign, error)
+	GetDomainCampaign(ctx context.Context, domain, id string) (*Campaign, error)
+	CreateDomainCampaign(ctx context.Context, domain, name string) (*Campaign, error)
+	UpdateDomainCampaign(ctx context.Context, domain, id, name string) (*Campaign, error)
+	DeleteDomainCampaign(ctx context.Context, domain, id string) error
 	GetComplaints(limit, skip int) (int, []Complaint, error)
 	GetSingleComplaint(address string) (Complaint, error)
 	GetStoredMessage(id string) (StoredMessage, error)
 	GetStoredMessageRaw(id string) (StoredMessageRaw, error)
 	DeleteStoredMessage(id string) error
+	GetStoredMessageByURL(ctx context.Context, storageURL string) (*StoredMessage, error)
+	GetStoredMessageRawByURL(ctx context.Context, storageURL string) (io.ReadCloser, error)
+	GetStoredMessageMimeBytes(ctx context.Context, storageURL string) ([]byte, error)
+	GetStoredMessageMimeReader(ctx context.Context, storageURL string) (io.ReadCloser, error)
+	DeleteStoredMessageByURL(ctx context.Context, storageURL string) error
+	CreateTemplate(ctx context.Context, t *Template) error
+	GetTemplate(ctx context.Context, name string) (*Template, error)
+	UpdateTemplate(ctx context.Context, t *Template) error
+	DeleteTemplate(ctx context.Context, name string) error
+	ListTemplates(ctx context.Context) ([]Template, error)
+	CreateTemplateVersion(ctx context.Context, templateName string, v *TemplateVersion) error
+	GetTemplateVersion(ctx context.Context, templateName, tag string) (*TemplateVersion, error)
+	UpdateTemplateVersion(ctx context.Context, templateName string, v *TemplateVersion) error
+	DeleteTemplateVersion(ctx context.Context, templateName, tag string) error
+	ListTemplateVersions(ctx context.Context, templateName string) ([]TemplateVersion, error)
 	GetCredentials(limit, skip int) (int, []Credential, error)
 	CreateCredential(login, password string) error
 	ChangeCredentialPassword(id, password string) error
 	DeleteCredential(id string) error
+	GetDomainCredentials(ctx context.Context, domain string) ([]Credential, error)
+	CreateDomainCredential(ctx context.Context, domain, login, password string) error
+	ChangeDomainCredentialPassword(ctx context.Context, domain, login, newPassword string) error
+	DeleteDomainCredential(ctx context.Context, domain, login string) error
 	GetUnsubscribes(limit, skip int) (int, []Unsubscription, error)
 	GetUnsubscribesByAddress(string) (int, []Unsubscription, error)
 	Unsubscribe(address, tag string) error
 	RemoveUnsubscribe(string) error
+	ImportUnsubscribes(ctx context.Context, unsubscribes []Unsubscription) error
+	ExportUnsubscribes(ctx context.Context, domain string) (io.ReadCloser, error)
+	WriteUnsubscribesCSV(ctx context.Context, domain string, w io.Writer) error
+	GetUnsubscribeURL(domain, address, tag string) string
+	VerifyUnsubscribeURL(rawurl string) (domain, address, tag string, err error)
 	CreateComplaint(string) error
 	DeleteComplaint(string) error
+	ImportComplaints(ctx context.Context, complaints []Complaint) error
+	ExportComplaints(ctx context.Context, domain string) (io.ReadCloser, error)
+	WriteComplaintsCSV(ctx context.Context, domain string, w io.Writer) error
 	GetRoutes(limit, skip int) (int, []Route, error)
 	GetRouteByID(string) (Route, error)
 	CreateRoute(Route) (Route, error)
 	DeleteRoute(string) error
 	UpdateRoute(string, Route) (Route, error)
+	ListRoutes(opts ListOptions) *RouteIterator
+	TestRoute(ctx context.Context, expression, recipient, from, subject, bodyPlain string) (*RouteTestResult, error)
 	GetWebhooks() (map[string]string, error)
+	ListSandboxAllowedRecipients(ctx context.Context) ([]string, error)
+	AddSandboxAllowedRecipient(ctx context.Context, email string) error
+	DeleteSandboxAllowedRecipient(ctx context.Context, email string) error
+	IsSandboxRecipientAllowed(ctx context.Context, email string) (bool, error)
+	GetDomainWebhooks(ctx context.Context, domain string) (*WebhookConfig, error)
 	CreateWebhook(kind, url string) error
 	DeleteWebhook(kind string) error
 	GetWebhookByType(kind string) (string, error)
 	UpdateWebhook(kind, url string) error
+	CreateDomainWebhook(ctx context.Context, domain string, event WebhookEvent, url string) error
+	GetDomainWebhook(ctx context.Context, domain string, event WebhookEvent) (string, error)
+	UpdateDomainWebhook(ctx context.Context, domain string, event WebhookEvent, url string) error
+	DeleteDomainWebhook(ctx context.Context, domain string, event WebhookEvent) error
+	ParseWebhookPayload(r *http.Request) (*WebhookPayload, error)
+	ParseInboundMessage(r *http.Request) (*InboundMessage, error)
 	GetLists(limit, skip int, filter string) (int, []List, error)
 	CreateList(List) (List, error)
 	DeleteList(string) error
@@ -184,33 +270,188 @@ type Mailgun interface {
 	GetMemberByAddress(MemberAddr, listAddr string) (Member, error)
 	CreateMember(merge bool, addr string, prototype Member) error
 	CreateMemberList(subscribed *bool, addr string, newMembers []interface{}) error
+	BulkAddMembers(ctx context.Context, addr string, members []Member) error
+	ImportMailingList(ctx context.Context, listAddress string, r io.Reader, opts ImportOptions) (*ImportResult, error)
+	ExportMailingList(ctx context.Context, listAddress string, format string, w io.Writer) error
 	UpdateMember(Member, list string, prototype Member) (Member, error)
 	DeleteMember(Member, list string) error
 	NewMessage(from, subject, text string, to ...string) *Message
+	NewHTMLMessage(from, subject, html, text string, to ...string) *Message
+	NewMessageWithOptions(from, subject, text string, to []string, opts ...MessageOption) *Message
 	NewMIMEMessage(body io.ReadCloser, to ...string) *Message
+	SendMime(ctx context.Context, m *MimeMessage) (string, string, error)
 	NewEventIterator() *EventIterator
+	GetEvents(ctx context.Context, domain string, opts EventsOptions) ([]Event, string, error)
+	GetNextPage(ctx context.Context, token string) ([]Event, string, error)
+	Events(domain string, opts EventsOptions) *EventIterator
+	GetAllEvents(ctx context.Context, domain string, opts EventsOptions) (<-chan Event, <-chan error)
+	ListUnsubscribes(domain string, opts ListOptions) *UnsubscribeIterator
+	ListBounces(domain string, opts ListOptions) *BounceIterator
+	ListComplaints(domain string, opts ListOptions) *ComplaintIterator
+	ListMembers(listAddress string, opts ListOptions) *MemberIterator
+	GetRecipientHistory(ctx context.Context, domain, recipient string, opts EventOptions) ([]Event, error)
+	GetMessageEvents(ctx context.Context, domain, messageID string, opts MessageEventOptions) ([]Event, error)
+	PollMessageEvent(ctx context.Context, domain, messageID, desiredEvent string, interval time.Duration) (Event, error)
+	GetClicksForURL(ctx context.Context, domain, link string, opts StatsOptions) (*URLClickStats, error)
+	GetOpensBreakdown(ctx context.Context, domain, messageID string) (*OpensBreakdown, error)
+	GetClickmap(ctx context.Context, domain, messageID string) ([]LinkHeatmapEntry, error)
+	GetDomainPolicy(ctx context.Context, domain string) (*DomainPolicy, error)
+	UpdateDomainPolicy(ctx context.Context, domain string, update DomainPolicyUpdate) error
+	GetDomainTracking(ctx context.Context, domain string) (*DomainTracking, error)
+	UpdateClickTracking(ctx context.Context, domain string, active bool) error
+	UpdateOpenTracking(ctx context.Context, domain string, active bool) error
+	UpdateUnsubscribeTracking(ctx context.Context, domain, active, htmlFooter, textFooter string) error
+	GetDomainSpamAction(ctx context.Context, domain string) (string, error)
+	SetDomainSpamAction(ctx context.Context, domain, action string) error
+	GetDomainConnection(ctx context.Context, domain string) (*DomainConnection, error)
+	UpdateDomainConnection(ctx context.Context, domain string, conn DomainConnection) error
+	GetDomainDKIM(ctx context.Context, domain string) (*DKIMInfo, error)
+	RotateDKIMKey(ctx context.Context, domain string) (*DKIMInfo, error)
+	SetDKIMSelector(ctx context.Context, domain, selector string) error
+	SetDKIMAuthority(ctx context.Context, domain string, self bool) error
+	GetSubaccounts(ctx context.Context, limit, skip int) ([]Subaccount, error)
+	GetSubaccount(ctx context.Context, id string) (*Subaccount, error)
+	CreateSubaccount(ctx context.Context, name string) (*Subaccount, error)
+	EnableSubaccount(ctx context.Context, id string) (*Subaccount, error)
+	DisableSubaccount(ctx context.Context, id string) (*Subaccount, error)
 }
 
 // MailgunImpl bundles data needed by a large number of methods in order to interact with the Mailgun API.
 // Colloquially, we refer to instances of this structure as "clients."
+// Code using a client should hold it as a Mailgun, as NewMailgun returns it,
+// so that tests can substitute another implementation, such as mailguntest.MockMailgun.
 type MailgunImpl struct {
 	domain       string
+	apiBase      string
+	apiBaseSet   bool
 	apiKey       string
 	publicApiKey string
 	client       *http.Client
+
+	concurrencyLimit int
+	retryPolicy      RetryPolicy
+	logger           Logger
+	subaccount       string
+	healthCheckHook  func(HealthCheckResult)
+
+	rateLimitLock sync.Mutex
+	rateLimit     *RateLimitStatus
+	rateLimitHook func(RateLimitStatus)
+}
+
+// DefaultConcurrencyLimit caps the number of API calls a client makes at once
+// on behalf of a single fan-out operation, such as GetAggregateStats,
+// unless overridden with WithConcurrencyLimit.
+const DefaultConcurrencyLimit = 8
+
+// An Option adjusts the configuration of a client as NewMailgun creates it.
+type Option func(*MailgunImpl)
+
+// WithConcurrencyLimit caps the number of API calls the client makes at once
+// on behalf of a single fan-out operation, such as GetAggregateStats.
+// Limits below 1 are treated as 1.
+func WithConcurrencyLimit(n int) Option {
+	return func(m *MailgunImpl) {
+		if n < 1 {
+			n = 1
+		}
+		m.concurrencyLimit = n
+	}
+}
+
+// A Region names one of the data regions Mailgun hosts domains in.
+// A domain's data, and so the API calls about it, live in the region the domain was created in.
+type Region string
+
+const (
+	// RegionUS selects Mailgun's US region, at api.mailgun.net.  This is the default.
+	RegionUS Region = "us"
+	// RegionEU selects Mailgun's EU region, at api.eu.mailgun.net.
+	RegionEU Region = "eu"
+)
+
+// WithRegion selects the region the client sends its API calls to.
+// The client must use the region its domain was created in.
+// Unknown regions leave the client talking to the US region.
+func WithRegion(region Region) Option {
+	return func(m *MailgunImpl) {
+		if m.apiBaseSet {
+			return
+		}
+		switch region {
+		case RegionEU:
+			m.apiBase = apiBaseEU
+		default:
+			m.apiBase = apiBase
+		}
+	}
+}
+
+// WithAPIBase points the client at another server implementing the Mailgun API, such as a mock server in tests
+// (see the mailguntest package).  The base URL includes the API version, as in "http://127.0.0.1:8080/v3".
+// It takes precedence over WithRegion, whatever order the two are given in.
+func WithAPIBase(base string) Option {
+	return func(m *MailgunImpl) {
+		m.apiBase = strings.TrimSuffix(base, "/")
+		m.apiBaseSet = true
+	}
 }
 
+// MailgunImpl must implement every method of the Mailgun interface, for mocks of the interface to stand in for it.
+var _ Mailgun = (*MailgunImpl)(nil)
+
 // NewMailGun creates a new client instance.
-func NewMailgun(domain, apiKey, publicApiKey string) Mailgun {
+// Options, if any, are applied in order.
+func NewMailgun(domain, apiKey, publicApiKey string, opts ...Option) Mailgun {
 	m := MailgunImpl{
-		domain:       domain,
-		apiKey:       apiKey,
-		publicApiKey: publicApiKey,
-		client:       http.DefaultClient,
+		domain:           domain,
+		apiKey:           apiKey,
+		publicApiKey:     publicApiKey,
+		apiBase:          apiBase,
+		client:           http.DefaultClient,
+		concurrencyLimit: DefaultConcurrencyLimit,
+	}
+	for _, opt := range opts {
+		opt(&m)
 	}
 	return &m
 }
 
+// NewMailgunFromEnv creates a new client instance configured by environment variables,
+// which keeps API keys out of source code.  This is the recommended setup for containerised applications.
+// MAILGUN_DOMAIN and MAILGUN_API_KEY are required; if either is missing, the error names every one that is.
+// MAILGUN_PUBLIC_API_KEY, MAILGUN_REGION ("us" or "eu"), and MAILGUN_BASE_URL are optional,
+// and work as the public API key, WithRegion, and WithAPIBase do.
+// Options, if any, are applied after those the environment implies.
+func NewMailgunFromEnv(opts ...Option) (Mailgun, error) {
+	var missing []string
+	env := func(name string, required bool) string {
+		v := strings.TrimSpace(os.Getenv(name))
+		if v == "" && required {
+			missing = append(missing, name)
+		}
+		return v
+	}
+	domain := env("MAILGUN_DOMAIN", true)
+	apiKey := env("MAILGUN_API_KEY", true)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing environment variables: %s", strings.Join(missing, ", "))
+	}
+
+	var envOpts []Option
+	switch region := Region(strings.ToLower(env("MAILGUN_REGION", false))); region {
+	case "":
+	case RegionUS, RegionEU:
+		envOpts = append(envOpts, WithRegion(region))
+	default:
+		return nil, fmt.Errorf("MAILGUN_REGION names unknown region %q; use %q or %q", region, RegionUS, RegionEU)
+	}
+	if base := env("MAILGUN_BASE_URL", false); base != "" {
+		envOpts = append(envOpts, WithAPIBase(base))
+	}
+	return NewMailgun(domain, apiKey, env("MAILGUN_PUBLIC_API_KEY", false), append(envOpts, opts...)...), nil
+}
+
 // Domain returns the domain configured for this client.
 func (m *MailgunImpl) Domain() string {
 	return m.domain
@@ -226,6 +467,11 @@ func (m *MailgunImpl) PublicApiKey() string {
 	return m.publicApiKey
 }
 
+// ApiBase returns the base URL of the API the client talks to, as chosen by WithRegion.
+func (m *MailgunImpl) ApiBase() string {
+	return m.apiBase
+}
+
 // Client returns the HTTP client configured for this client.
 func (m *MailgunImpl) Client() *http.Client {
 	return m.client
@@ -238,13 +484,19 @@ func (m *MailgunImpl) SetClient(c *http.Client) {
 
 // generateApiUrl renders a URL for an API endpoint using the domain and endpoint name.
 func generateApiUrl(m Mailgun, endpoint string) string {
-	return fmt.Sprintf("%s/%s/%s", apiBase, m.Domain(), endpoint)
+	return generateApiUrlWithDomain(m, m.Domain(), endpoint)
+}
+
+// generateApiUrlWithDomain works as generateApiUrl,
+// but addresses the named domain instead of the one configured for the client.
+func generateApiUrlWithDomain(m Mailgun, domain, endpoint string) string {
+	return fmt.Sprintf("%s/%s/%s", m.ApiBase(), domain, endpoint)
 }
 
 // generateMemberApiUrl renders a URL relevant for specifying mailing list members.
 // The address parameter refers to the mailing list in question.
-func generateMemberApiUrl(endpoint, address string) string {
-	return fmt.Sprintf("%s/%s/%s/members", apiBase, endpoint, address)
+func generateMemberApiUrl(m Mailgun, endpoint, address string) string {
+	return fmt.Sprintf("%s/%s/%s/members", m.ApiBase(), endpoint, address)
 }
 
 // generateApiUrlWithTarget works as generateApiUrl,
@@ -262,18 +514,30 @@ func generateApiUrlWithTarget(m Mailgun, endpoint, target string) string {
 // Most URLs consume a domain in the 2nd position, but some endpoints
 // require the word "domains" to be there instead.
 func generateDomainApiUrl(m Mailgun, endpoint string) string {
-	return fmt.Sprintf("%s/domains/%s/%s", apiBase, m.Domain(), endpoint)
+	return generateDomainApiUrlWithDomain(m, m.Domain(), endpoint)
+}
+
+// generateDomainApiUrlWithDomain works as generateDomainApiUrl,
+// but addresses the named domain instead of the one configured for the client.
+func generateDomainApiUrlWithDomain(m Mailgun, domain, endpoint string) string {
+	return fmt.Sprintf("%s/domains/%s/%s", m.ApiBase(), domain, endpoint)
 }
 
 // generateCredentialsUrl renders a URL as generateDomainApiUrl,
 // but focuses on the SMTP credentials family of API functions.
 func generateCredentialsUrl(m Mailgun, id string) string {
+	return generateCredentialsUrlWithDomain(m, m.Domain(), id)
+}
+
+// generateCredentialsUrlWithDomain works as generateCredentialsUrl,
+// but addresses the named domain instead of the one configured for the client.
+func generateCredentialsUrlWithDomain(m Mailgun, domain, id string) string {
 	tail := ""
 	if id != "" {
 		tail = fmt.Sprintf("/%s", id)
 	}
-	return generateDomainApiUrl(m, fmt.Sprintf("credentials%s", tail))
-	// return fmt.Sprintf("%s/domains/%s/credentials%s", apiBase, m.Domain(), tail)
+	return generateDomainApiUrlWithDomain(m, domain, fmt.Sprintf("credentials%s", tail))
+	// return fmt.Sprintf("%s/domains/%s/credentials%s", apiBase, domain, tail)
 }
 
 // generateStoredMessageUrl generates the URL needed to acquire a copy of a stored message.
@@ -283,8 +547,23 @@ func generateStoredMessageUrl(m Mailgun, endpoint, id string) string {
 }
 
 // generatePublicApiUrl works as generateApiUrl, except that generatePublicApiUrl has no need for the domain.
-func generatePublicApiUrl(endpoint string) string {
-	return fmt.Sprintf("%s/%s", apiBase, endpoint)
+func generatePublicApiUrl(m Mailgun, endpoint string) string {
+	return fmt.Sprintf("%s/%s", m.ApiBase(), endpoint)
+}
+
+// generateV4ApiUrl works as generatePublicApiUrl, but addresses version 4 of the API.
+func generateV4ApiUrl(m Mailgun, endpoint string) string {
+	return fmt.Sprintf("%s/v4/%s", strings.TrimSuffix(m.ApiBase(), "/v3"), endpoint)
+}
+
+// generateV1ApiUrl works as generatePublicApiUrl, but addresses version 1 of the API.
+func generateV1ApiUrl(m Mailgun, endpoint string) string {
+	return fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(m.ApiBase(), "/v3"), endpoint)
+}
+
+// generateV5ApiUrl works as generatePublicApiUrl, but addresses version 5 of the API.
+func generateV5ApiUrl(m Mailgun, endpoint string) string {
+	return fmt.Sprintf("%s/v5/%s", strings.TrimSuffix(m.ApiBase(), "/v3"), endpoint)
 }
 
 // generateParameterizedUrl works as generateApiUrl, but supports query parameters.
@@ -1,9 +1,34 @@
 package mailgun
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 const domain = "valid-mailgun-domain"
@@ -38,7 +63,7 @@ func TestMailgun(t *testing.T) {
 func TestBounceGetCode(t *testing.T) {
 	b1 := &Bounce{
 		CreatedAt: "blah",
-		code:      123,
+		Code:      123,
 		Address:   "blort",
 		Error:     "bletch",
 	}
@@ -52,7 +77,7 @@ func TestBounceGetCode(t *testing.T) {
 
 	b2 := &Bounce{
 		CreatedAt: "blah",
-		code:      "456",
+		Code:      "456",
 		Address:   "blort",
 		Error:     "Bletch",
 	}
@@ -66,7 +91,7 @@ func TestBounceGetCode(t *testing.T) {
 
 	b3 := &Bounce{
 		CreatedAt: "blah",
-		code:      "456H",
+		Code:      "456H",
 		Address:   "blort",
 		Error:     "Bletch",
 	}
@@ -76,3 +101,4061 @@ func TestBounceGetCode(t *testing.T) {
 		t.Fatal("Expected a syntax error in numeric conversion: got ", err)
 	}
 }
+
+func TestLinkHeatmapEntryCTR(t *testing.T) {
+	e := LinkHeatmapEntry{URL: "http://example.com/", TotalClicks: 7, UniqueClicks: 5}
+	if ctr := e.CTR(20); ctr != 0.25 {
+		t.Fatal("Expected CTR of 0.25; got ", ctr)
+	}
+	if ctr := e.CTR(0); ctr != 0 {
+		t.Fatal("Expected CTR of 0 without recipients; got ", ctr)
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	n := normalizeURL("HTTP://Example.COM/Path?q=1#top")
+	if n != "http://example.com/Path?q=1" {
+		t.Fatal("Unexpected normalized URL: ", n)
+	}
+}
+
+func TestHasValidDKIMRecord(t *testing.T) {
+	records := []DNSRecord{
+		{RecordType: "TXT", Name: "example.com", Valid: "valid", Value: "v=spf1 include:mailgun.org ~all"},
+		{RecordType: "TXT", Name: "k1._domainkey.example.com", Valid: "unknown"},
+	}
+	if hasValidDKIMRecord(records) {
+		t.Fatal("Expected unverified DKIM record to be ignored")
+	}
+	records[1].Valid = "valid"
+	if !hasValidDKIMRecord(records) {
+		t.Fatal("Expected verified DKIM record to be found")
+	}
+}
+
+func TestCombineErrors(t *testing.T) {
+	if combineErrors(nil) != nil {
+		t.Fatal("Expected no error")
+	}
+	e1 := fmt.Errorf("first")
+	if combineErrors([]error{e1}) != e1 {
+		t.Fatal("Expected the sole error to be returned as-is")
+	}
+	err := combineErrors([]error{e1, fmt.Errorf("second")})
+	if err == nil || err.Error() != "first; second" {
+		t.Fatal("Unexpected combined error: ", err)
+	}
+}
+
+func TestValidateAndSendNoValidRecipients(t *testing.T) {
+	mg := NewMailgun("example.com", "my_api_key", "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "not an address")
+	_, _, err := mg.ValidateAndSend(context.Background(), m, ValidationOptions{})
+	if err != ErrNoValidRecipients {
+		t.Fatal("Expected ErrNoValidRecipients; got ", err)
+	}
+}
+
+func TestEventStringNested(t *testing.T) {
+	e := Event{
+		"recipient":   "joe@example.com",
+		"geolocation": map[string]interface{}{"country": "US"},
+	}
+	if s := eventString(e, "geolocation", "country"); s != "US" {
+		t.Fatal("Expected US; got ", s)
+	}
+	if s := eventString(e, "recipient", "country"); s != "" {
+		t.Fatal("Expected no value when descending into a string; got ", s)
+	}
+}
+
+func TestOpensBreakdownMobileVsDesktop(t *testing.T) {
+	b := OpensBreakdown{ByDevice: map[string]int{"mobile": 3, "tablet": 1, "desktop": 5, "unknown": 2}}
+	mobile, desktop := b.MobileVsDesktop()
+	if mobile != 4 || desktop != 5 {
+		t.Fatalf("Expected 4 mobile and 5 desktop opens; got %d and %d", mobile, desktop)
+	}
+}
+
+func TestTransactionalSpecValidate(t *testing.T) {
+	mg := NewMailgun("example.com", "my_api_key", "")
+	spec := TransactionalSpec{
+		Message:         mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com"),
+		RequiredHeaders: map[string]string{"X-Message-Class": "transactional"},
+		ComplianceTag:   "compliance",
+	}
+	if err := spec.validate(); err != nil {
+		t.Fatal(err)
+	}
+	spec.RequiredHeaders["X-Patient-Notice"] = ""
+	if err := spec.validate(); err == nil {
+		t.Fatal("Expected an error for a required header without a value")
+	}
+	spec.RequiredHeaders = nil
+	if err := spec.validate(); err == nil {
+		t.Fatal("Expected an error for a spec without required headers")
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	h := http.Header{}
+	if _, ok := parseRateLimit(h); ok {
+		t.Fatal("Expected no rate limit without headers")
+	}
+	h.Set("X-RateLimit-Limit", "300")
+	h.Set("X-RateLimit-Remaining", "299")
+	h.Set("X-RateLimit-Reset", "1500000000000")
+	status, ok := parseRateLimit(h)
+	if !ok {
+		t.Fatal("Expected a rate limit")
+	}
+	if status.Limit != 300 || status.Remaining != 299 {
+		t.Fatalf("Unexpected rate limit: %#v", status)
+	}
+	if !status.Reset.Equal(time.Unix(1500000000, 0)) {
+		t.Fatal("Unexpected reset time: ", status.Reset)
+	}
+}
+
+func TestRateLimitUpdateHook(t *testing.T) {
+	mg := NewMailgun("example.com", "my_api_key", "").(*MailgunImpl)
+	var seen []RateLimitStatus
+	mg.SetRateLimitUpdateHook(func(s RateLimitStatus) {
+		seen = append(seen, s)
+	})
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("X-RateLimit-Limit", "10")
+	resp.Header.Set("X-RateLimit-Remaining", "9")
+	mg.observeResponse(resp)
+
+	if len(seen) != 1 || seen[0].Remaining != 9 {
+		t.Fatalf("Expected the hook to see one update; got %#v", seen)
+	}
+	status, err := mg.GetRateLimitStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Limit != 10 {
+		t.Fatal("Expected the cached limit; got ", status.Limit)
+	}
+}
+
+func TestMessageSetContent(t *testing.T) {
+	m := NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	if err := m.SetContent("New text", ""); err == nil {
+		t.Fatal("Expected an error for a blank HTML body")
+	}
+	if text, html := m.GetContent(); text != "Text" || html != "" {
+		t.Fatalf("Expected the content to remain unchanged; got %q and %q", text, html)
+	}
+	if err := m.SetContent("New text", "<p>New HTML</p>"); err != nil {
+		t.Fatal(err)
+	}
+	if text, html := m.GetContent(); text != "New text" || html != "<p>New HTML</p>" {
+		t.Fatalf("Unexpected content %q and %q", text, html)
+	}
+}
+
+func TestSendBatchReportsFailures(t *testing.T) {
+	mg := NewMailgun("example.com", "my_api_key", "")
+	invalid := mg.NewMessage("", "Subject", "Text", "you@example.com")
+	var failed []int
+	ids, err := mg.SendBatch(context.Background(), []*Message{invalid}, BatchOptions{
+		OnError: func(i int, _ *Message, _ error) {
+			failed = append(failed, i)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != "" {
+		t.Fatalf("Expected a single empty ID; got %#v", ids)
+	}
+	if len(failed) != 1 || failed[0] != 0 {
+		t.Fatalf("Expected message 0 to fail; got %#v", failed)
+	}
+}
+
+func TestWebhookConfigAllURLs(t *testing.T) {
+	c := WebhookConfig{
+		Delivered: []string{"https://a.example.com", "https://b.example.com"},
+		Opened:    []string{"https://b.example.com"},
+		Clicked:   []string{"https://c.example.com"},
+	}
+	all := c.AllURLs()
+	expected := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"}
+	if len(all) != len(expected) {
+		t.Fatalf("Expected %v; got %v", expected, all)
+	}
+	for i := range expected {
+		if all[i] != expected[i] {
+			t.Fatalf("Expected %v; got %v", expected, all)
+		}
+	}
+}
+
+func TestDiagnosticReportSuppress(t *testing.T) {
+	var r DiagnosticReport
+	r.suppress("unsubscribed", "First")
+	r.suppress("complained", "Second")
+	if !r.IsSuppressed || r.SuppressionReason != "unsubscribed; complained" {
+		t.Fatalf("Unexpected suppression %v %q", r.IsSuppressed, r.SuppressionReason)
+	}
+	if len(r.Recommendations) != 2 {
+		t.Fatal("Expected two recommendations; got ", len(r.Recommendations))
+	}
+}
+
+func TestCalendarInviteRender(t *testing.T) {
+	invite := CalendarInvite{
+		UID:       "1234@example.com",
+		Summary:   "Planning, round 2",
+		Organizer: CalendarPerson{Name: "Joe", Email: "joe@example.com"},
+		Attendees: []CalendarPerson{{Email: "jane@example.com"}},
+		Start:     time.Date(2017, 6, 1, 15, 0, 0, 0, time.UTC),
+		End:       time.Date(2017, 6, 1, 16, 0, 0, 0, time.UTC),
+	}
+	ics, err := invite.render(time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"METHOD:REQUEST\r\n",
+		"DTSTART:20170601T150000Z\r\n",
+		"SUMMARY:Planning\\, round 2\r\n",
+		"ORGANIZER;CN=\"Joe\":mailto:joe@example.com\r\n",
+		"ATTENDEE;ROLE=REQ-PARTICIPANT;RSVP=TRUE:mailto:jane@example.com\r\n",
+	} {
+		if !strings.Contains(string(ics), expected) {
+			t.Fatalf("Expected %q in:\n%s", expected, ics)
+		}
+	}
+
+	invite.End = invite.Start
+	if _, err := invite.render(time.Now()); err == nil {
+		t.Fatal("Expected an error for an empty time span")
+	}
+}
+
+func TestFoldCalendarLine(t *testing.T) {
+	folded := foldCalendarLine(strings.Repeat("x", 80))
+	if folded != strings.Repeat("x", 75)+"\r\n "+strings.Repeat("x", 5) {
+		t.Fatalf("Unexpected folding %q", folded)
+	}
+}
+
+func TestWithConcurrencyLimit(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	if mg.concurrencyLimit != DefaultConcurrencyLimit {
+		t.Fatal("Expected the default concurrency limit; got ", mg.concurrencyLimit)
+	}
+	mg = NewMailgun(domain, apiKey, "", WithConcurrencyLimit(0)).(*MailgunImpl)
+	if mg.concurrencyLimit != 1 {
+		t.Fatal("Expected a concurrency limit of 1; got ", mg.concurrencyLimit)
+	}
+}
+
+func TestFailureTotal(t *testing.T) {
+	if n := failureTotal(map[string]int{"espblock": 2, "bounce": 3}); n != 5 {
+		t.Fatal("Expected 5; got ", n)
+	}
+	if n := failureTotal(map[string]int{"bounce": 3, "total": 3}); n != 3 {
+		t.Fatal("Expected 3; got ", n)
+	}
+}
+
+func TestGenerateMessageID(t *testing.T) {
+	id := GenerateMessageID("example.com")
+	if !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@example.com>") || len(id) != len("<>@example.com")+36 {
+		t.Fatal("Malformed message ID ", id)
+	}
+	if id == GenerateMessageID("example.com") {
+		t.Fatal("Expected message IDs to differ")
+	}
+}
+
+func TestAutoSetMessageID(t *testing.T) {
+	m := NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	m.AddHeader("message-id", "<mine@example.com>")
+	m.AutoSetMessageID("example.com")
+	if len(m.headers) != 1 || m.headers["message-id"] != "<mine@example.com>" {
+		t.Fatalf("Expected the existing Message-ID to be kept; got %#v", m.headers)
+	}
+}
+
+func TestSetRecipientCC(t *testing.T) {
+	m := NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	if err := m.SetRecipientCC("stranger@example.com", "boss@example.com"); err == nil {
+		t.Fatal("Expected an error for a CC on someone who isn't a recipient")
+	}
+	if err := m.SetRecipientCC("you@example.com", "boss@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if cc := m.recipientCCs["you@example.com"]; len(cc) != 1 || cc[0] != "boss@example.com" {
+		t.Fatalf("Unexpected CCs %#v", m.recipientCCs)
+	}
+	if err := m.SetRecipientCC("you@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.recipientCCs) != 0 {
+		t.Fatalf("Expected the CCs to be removed; got %#v", m.recipientCCs)
+	}
+}
+
+func TestBufferedAttachmentsReread(t *testing.T) {
+	b, err := bufferReaderAttachments([]ReaderAttachment{
+		{Filename: "a.txt", ReadCloser: ioutil.NopCloser(strings.NewReader("hello"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		data, err := ioutil.ReadAll(b.readers()[0].ReadCloser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "hello" {
+			t.Fatalf("Read %d: expected hello; got %q", i, data)
+		}
+	}
+}
+
+func TestUnsubscribeURLRoundTrip(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	link := mg.GetUnsubscribeURL("example.com", "joe+news@example.com", "newsletter")
+	d, a, tag, err := mg.VerifyUnsubscribeURL(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != "example.com" || a != "joe+news@example.com" || tag != "newsletter" {
+		t.Fatalf("Unexpected fields %q %q %q", d, a, tag)
+	}
+
+	tampered := strings.Replace(link, "joe%2Bnews", "jane", 1)
+	if _, _, _, err := mg.VerifyUnsubscribeURL(tampered); err == nil {
+		t.Fatal("Expected a tampered link to fail verification")
+	}
+	other := NewMailgun(domain, "another-api-key", "")
+	if _, _, _, err := other.VerifyUnsubscribeURL(link); err == nil {
+		t.Fatal("Expected a link signed with another key to fail verification")
+	}
+}
+
+func TestMemberFromRecord(t *testing.T) {
+	header := []string{"name", "email", "subscribed", "plan"}
+	m, err := memberFromRecord(header, 1, []string{"Joe", " joe@example.com ", "no", "gold"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Address != "joe@example.com" || m.Name != "Joe" || m.Subscribed == nil || *m.Subscribed {
+		t.Fatalf("Unexpected member %#v", m)
+	}
+	if m.Vars["plan"] != "gold" {
+		t.Fatalf("Expected the plan variable; got %#v", m.Vars)
+	}
+	if _, err := memberFromRecord(header, 1, []string{"Joe", "joe@example.com", "maybe", ""}); err == nil {
+		t.Fatal("Expected an error for an invalid subscribed value")
+	}
+	if _, err := memberFromRecord(header, 1, []string{"Joe", "", "", ""}); err == nil {
+		t.Fatal("Expected an error for a missing address")
+	}
+}
+
+func TestValidatePhoneRequiresPublicKey(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	if _, err := mg.ValidatePhone(context.Background(), "+14155550123"); err != ErrPublicAPIKeyRequired {
+		t.Fatal("Expected ErrPublicAPIKeyRequired; got ", err)
+	}
+}
+
+func TestForecastDelivery(t *testing.T) {
+	records := []DNSRecord{
+		{RecordType: "TXT", Name: "example.com", Valid: "valid"},
+		{RecordType: "TXT", Name: "mx._domainkey.example.com", Valid: "valid"},
+	}
+	healthy := forecastDelivery(Domain{State: "active"}, records, &DomainStats{Accepted: 20000, Delivered: 19800})
+	if healthy.ConfidenceLevel != "high" || len(healthy.RiskFactors) != 0 {
+		t.Fatalf("Unexpected forecast for a healthy domain: %#v", healthy)
+	}
+	if !healthy.IsReadyToSend(0.9) {
+		t.Fatal("Expected a healthy domain to be ready to send; got ", healthy.ExpectedInboxRate)
+	}
+
+	disabled := forecastDelivery(Domain{State: "disabled"}, records, &DomainStats{Accepted: 20000, Delivered: 19800})
+	if disabled.IsReadyToSend(0.01) {
+		t.Fatal("Expected a disabled domain not to be ready to send")
+	}
+}
+
+func TestSendCancelledContext(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := mg.Send(ctx, m)
+	if err != context.Canceled {
+		t.Fatal("Expected context.Canceled; got ", err)
+	}
+}
+
+// pngFixture is a 1x1 transparent PNG.
+var pngFixture = []byte{
+	0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x48, 0x44, 0x52,
+	0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x08, 0x06, 0x00, 0x00, 0x00, 0x1f, 0x15, 0xc4,
+	0x89, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x44, 0x41, 0x54, 0x78, 0x9c, 0x63, 0x00, 0x01, 0x00, 0x00,
+	0x05, 0x00, 0x01, 0x0d, 0x0a, 0x2d, 0xb4, 0x00, 0x00, 0x00, 0x00, 0x49, 0x45, 0x4e, 0x44, 0xae,
+	0x42, 0x60, 0x82,
+}
+
+func TestReaderAndFileAttachments(t *testing.T) {
+	f, err := ioutil.TempFile("", "pixel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(pngFixture); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	m.AddAttachment(f.Name())
+	m.AddReaderAttachment("reader.png", bytes.NewReader(pngFixture))
+	m.AddBufferAttachment("buffer.png", pngFixture)
+	m.AddReaderInline("reader-inline.png", bytes.NewReader(pngFixture))
+	m.AddBufferInline("buffer-inline.png", pngFixture)
+
+	payload, err := newSendPayload(m, m.to, nil, m.readerAttachments, m.readerInlines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := payload.getPayloadBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(payload.getContentType())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	mr := multipart.NewReader(buf, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.FileName() == "" {
+			continue
+		}
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, pngFixture) {
+			t.Fatalf("Unexpected contents for %s", part.FileName())
+		}
+		files[part.FileName()] = part.FormName()
+	}
+	expected := map[string]string{
+		filepath.Base(f.Name()): "attachment",
+		"reader.png":            "attachment",
+		"buffer.png":            "attachment",
+		"reader-inline.png":     "inline",
+		"buffer-inline.png":     "inline",
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("Expected %d files; got %v", len(expected), files)
+	}
+	for name, field := range expected {
+		if files[name] != field {
+			t.Fatalf("Expected %s as %s; got %q", name, field, files[name])
+		}
+	}
+}
+
+func TestWithRegion(t *testing.T) {
+	us := NewMailgun(domain, apiKey, "")
+	if u := generateApiUrl(us, messagesEndpoint); u != "https://api.mailgun.net/v3/"+domain+"/messages" {
+		t.Fatal("Unexpected default URL: ", u)
+	}
+	eu := NewMailgun(domain, apiKey, "", WithRegion(RegionEU))
+	if u := generateApiUrl(eu, messagesEndpoint); u != "https://api.eu.mailgun.net/v3/"+domain+"/messages" {
+		t.Fatal("Unexpected EU URL: ", u)
+	}
+	if u := generateV4ApiUrl(eu, addressValidateEndpoint); u != "https://api.eu.mailgun.net/v4/address/validate" {
+		t.Fatal("Unexpected EU v4 URL: ", u)
+	}
+	local := NewMailgun(domain, apiKey, "", WithAPIBase("http://127.0.0.1:8080/v3/"), WithRegion(RegionEU))
+	if u := generateApiUrl(local, messagesEndpoint); u != "http://127.0.0.1:8080/v3/"+domain+"/messages" {
+		t.Fatal("Unexpected overridden URL: ", u)
+	}
+}
+
+func TestAPIErrorRetryable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"message": "Too many requests"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	_, _, err := mg.Send(context.Background(), m)
+	apiErr, ok := IsAPIError(err)
+	if !ok {
+		t.Fatal("Expected an APIError; got ", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || !apiErr.Retryable || apiErr.Message != "Too many requests" {
+		t.Fatalf("Unexpected APIError: %#v", apiErr)
+	}
+	if _, ok := apiErr.Unwrap().(*UnexpectedResponseError); !ok {
+		t.Fatal("Expected the APIError to wrap an UnexpectedResponseError")
+	}
+}
+
+func TestAddRecipientVariable(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Hello %recipient.name%", "Text", "alice@example.com")
+	m.AddCC("bob@example.com")
+	if err := m.AddRecipientVariable("alice@example.com", "name", "Alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddRecipientVariable("bob@example.com", "name", "Bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddRecipientVariable("alice@example.com", "id", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateMessage(m); err != nil {
+		t.Fatal("Expected variables for To: and Cc: recipients to be valid; got ", err)
+	}
+	payload, err := newSendPayload(m, m.to, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vars string
+	for _, kv := range payload.Values {
+		if kv.key == "recipient-variables" {
+			vars = kv.value
+		}
+	}
+	if vars != `{"alice@example.com":{"id":1,"name":"Alice"},"bob@example.com":{"name":"Bob"}}` {
+		t.Fatal("Unexpected recipient variables: ", vars)
+	}
+
+	if err := m.AddRecipientVariable("carol@example.com", "name", "Carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateMessage(m); err != ErrStrayRecipientVariables {
+		t.Fatal("Expected variables for a stranger to be invalid; got ", err)
+	}
+	if err := m.AddRecipientVariable("alice@example.com", "bad", func() {}); err == nil {
+		t.Fatal("Expected an unmarshalable value to be refused")
+	}
+}
+
+func TestGetEventsPaging(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprintf(w, `{"items": [], "paging": {"next": "%s/v3/%s/events?page=3"}}`, srv.URL, domain)
+			return
+		}
+		if r.URL.Query().Get("event") != "failed" || r.URL.Query().Get("recipient") != "joe@example.com" {
+			t.Error("Expected filters on the query; got ", r.URL.RawQuery)
+		}
+		fmt.Fprintf(w, `{"items": [{"event": "failed", "timestamp": 1500000000.5, "recipient": "joe@example.com",
+			"severity": "permanent", "tags": ["welcome"], "message": {"headers": {"message-id": "abc@example.com"}}}],
+			"paging": {"next": "%s/v3/%s/events?page=2"}}`, srv.URL, domain)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	events, token, err := mg.GetEvents(context.Background(), domain, EventsOptions{Event: "failed", Recipient: "joe@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || token == "" {
+		t.Fatalf("Expected one event and a token; got %d and %q", len(events), token)
+	}
+	e := events[0]
+	if e.Name() != "failed" || e.Recipient() != "joe@example.com" || e.Severity() != "permanent" || e.MessageID() != "abc@example.com" {
+		t.Fatalf("Unexpected event fields: %#v", e)
+	}
+	if tags := e.Tags(); len(tags) != 1 || tags[0] != "welcome" {
+		t.Fatal("Unexpected tags: ", tags)
+	}
+	if ts := e.Timestamp(); ts.Unix() != 1500000000 || ts.Nanosecond() != 500000000 {
+		t.Fatal("Unexpected timestamp: ", ts)
+	}
+
+	events, token, err = mg.GetNextPage(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 || token != "" {
+		t.Fatalf("Expected the events to run out; got %d and %q", len(events), token)
+	}
+
+	bare := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"items": [{"event": "delivered"}]}`)
+	}))
+	defer bare.Close()
+	mg.apiBase = bare.URL + "/v3"
+	events, token, err = mg.GetEvents(context.Background(), domain, EventsOptions{})
+	if err != nil || len(events) != 1 || token != "" {
+		t.Fatalf("Expected a page without paging links to end the events; got %d, %q, %v", len(events), token, err)
+	}
+}
+
+// fakeLists serves just enough of the mailing list API to exercise a client against.
+type fakeLists struct {
+	lists   map[string]List
+	members map[string]map[string]Member
+}
+
+func (f *fakeLists) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/v3/lists"), "/")
+	reply := func(v interface{}) {
+		bs, _ := json.Marshal(v)
+		w.Write(bs)
+	}
+	switch {
+	case len(path) == 1 && r.Method == "GET":
+		var items []List
+		for _, l := range f.lists {
+			items = append(items, l)
+		}
+		reply(map[string]interface{}{"items": items, "total_count": len(items)})
+	case len(path) == 1 && r.Method == "POST":
+		l := List{Address: r.FormValue("address"), Name: r.FormValue("name"), Description: r.FormValue("description")}
+		f.lists[l.Address] = l
+		f.members[l.Address] = map[string]Member{}
+		reply(map[string]interface{}{"list": l})
+	case len(path) == 2:
+		l, ok := f.lists[path[1]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case "PUT":
+			if d := r.FormValue("description"); d != "" {
+				l.Description = d
+			}
+			f.lists[l.Address] = l
+		case "DELETE":
+			delete(f.lists, l.Address)
+			delete(f.members, l.Address)
+		}
+		l.MembersCount = len(f.members[l.Address])
+		reply(map[string]interface{}{"list": l})
+	case len(path) == 3 && path[2] == "members.json" && r.Method == "POST":
+		var batch []Member
+		if err := json.Unmarshal([]byte(r.FormValue("members")), &batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, m := range batch {
+			f.members[path[1]][m.Address] = m
+		}
+		reply(map[string]string{"message": "ok"})
+	case len(path) == 3 && path[2] == "members" && r.Method == "POST":
+		m := Member{Address: r.FormValue("address"), Name: r.FormValue("name")}
+		f.members[path[1]][m.Address] = m
+		reply(map[string]interface{}{"member": m})
+	case len(path) == 3 && path[2] == "members" && r.Method == "GET":
+		items := []Member{}
+		for _, m := range f.members[path[1]] {
+			items = append(items, m)
+		}
+		total := len(items)
+		sort.Slice(items, func(i, j int) bool { return items[i].Address < items[j].Address })
+		if skip, err := strconv.Atoi(r.FormValue("skip")); err == nil && skip < len(items) {
+			items = items[skip:]
+		} else if err == nil {
+			items = items[:0]
+		}
+		if limit, err := strconv.Atoi(r.FormValue("limit")); err == nil && limit < len(items) {
+			items = items[:limit]
+		}
+		reply(map[string]interface{}{"items": items, "total_count": total})
+	case len(path) == 4 && path[2] == "members":
+		m, ok := f.members[path[1]][path[3]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case "PUT":
+			if n := r.FormValue("name"); n != "" {
+				m.Name = n
+			}
+			f.members[path[1]][m.Address] = m
+		case "DELETE":
+			delete(f.members[path[1]], m.Address)
+		}
+		reply(map[string]interface{}{"member": m})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestMailingListCRUD(t *testing.T) {
+	srv := httptest.NewServer(&fakeLists{lists: map[string]List{}, members: map[string]map[string]Member{}})
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	const addr = "news@example.com"
+
+	l, err := mg.CreateList(List{Address: addr, Name: "News", Description: "Old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Address != addr || l.Name != "News" {
+		t.Fatalf("Unexpected list created: %#v", l)
+	}
+	if l, err = mg.UpdateList(addr, List{Description: "New"}); err != nil || l.Description != "New" {
+		t.Fatalf("Unexpected list after update: %#v, %v", l, err)
+	}
+	if n, lists, err := mg.GetLists(DefaultLimit, DefaultSkip, ""); err != nil || n != 1 || lists[0].Address != addr {
+		t.Fatalf("Unexpected lists: %d %#v, %v", n, lists, err)
+	}
+
+	if err := mg.CreateMember(false, addr, Member{Address: "alice@example.com", Name: "Alice"}); err != nil {
+		t.Fatal(err)
+	}
+	bulk := make([]Member, 1500)
+	for i := range bulk {
+		bulk[i] = Member{Address: fmt.Sprintf("member%d@example.com", i)}
+	}
+	if err := mg.BulkAddMembers(context.Background(), addr, bulk); err != nil {
+		t.Fatal(err)
+	}
+	if l, err = mg.GetListByAddress(addr); err != nil || l.MembersCount != 1501 {
+		t.Fatalf("Expected 1501 members; got %d, %v", l.MembersCount, err)
+	}
+	if m, err := mg.UpdateMember("alice@example.com", addr, Member{Name: "Alicia"}); err != nil || m.Name != "Alicia" {
+		t.Fatalf("Unexpected member after update: %#v, %v", m, err)
+	}
+	if err := mg.DeleteMember("alice@example.com", addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mg.GetMemberByAddress("alice@example.com", addr); err == nil {
+		t.Fatal("Expected the deleted member to be gone")
+	}
+	if n, _, err := mg.GetMembers(DefaultLimit, DefaultSkip, nil, addr); err != nil || n != 1500 {
+		t.Fatalf("Expected 1500 members; got %d, %v", n, err)
+	}
+
+	if err := mg.DeleteList(addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mg.GetListByAddress(addr); err == nil {
+		t.Fatal("Expected the deleted list to be gone")
+	}
+}
+
+func TestAddAndGetDomain(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			if r.FormValue("name") != "new.example.com" || r.FormValue("spam_action") != Tag || r.FormValue("smtp_password") != "" {
+				t.Error("Unexpected form: ", r.Form)
+			}
+			fmt.Fprint(w, `{"domain": {"name": "new.example.com", "state": "unverified", "spam_action": "tag"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"domain": {"name": "new.example.com", "state": "unverified"},
+			"sending_dns_records": [{"record_type": "TXT", "name": "new.example.com", "valid": "valid"}],
+			"receiving_dns_records": [{"record_type": "MX", "value": "mxa.mailgun.org", "valid": "unknown"}]}`)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	d, err := mg.AddDomain(context.Background(), "new.example.com", DomainOptions{SpamAction: Tag})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "new.example.com" || d.State != "unverified" || d.SpamAction != Tag {
+		t.Fatalf("Unexpected domain: %#v", d)
+	}
+	d, records, err := mg.GetDomain(context.Background(), "new.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "new.example.com" || len(records) != 2 || records[0].RecordType != "TXT" || records[1].RecordType != "MX" {
+		t.Fatalf("Unexpected domain or records: %#v %#v", d, records)
+	}
+}
+
+func TestDomainCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/domains/other.example.com/credentials" && r.Method != "DELETE" {
+			t.Error("Unexpected path: ", r.URL.Path)
+		}
+		switch r.Method {
+		case "GET":
+			skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+			var items []string
+			for i := skip; i < 150 && i < skip+credentialsPageSize; i++ {
+				items = append(items, fmt.Sprintf(`{"login": "user%d", "mailbox": "user%d@other.example.com"}`, i, i))
+			}
+			fmt.Fprintf(w, `{"total_count": 150, "items": [%s]}`, strings.Join(items, ","))
+		case "DELETE":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Credentials not found"}`)
+		}
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	cs, err := mg.GetDomainCredentials(context.Background(), "other.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != 150 || cs[149].Mailbox != "user149@other.example.com" {
+		t.Fatalf("Expected 150 credentials; got %d", len(cs))
+	}
+
+	err = mg.DeleteDomainCredential(context.Background(), "other.example.com", "nobody")
+	apiErr, ok := IsAPIError(err)
+	if !ok || apiErr.StatusCode != http.StatusNotFound || apiErr.Retryable {
+		t.Fatalf("Expected a non-retryable 404 APIError; got %#v", err)
+	}
+}
+
+func TestGetStatsTotal(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("resolution") != "hour" {
+			t.Error("Expected an hourly resolution; got ", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"stats": [{"time": "Mon, 02 Oct 2017 10:00:00 UTC",
+			"accepted": {"total": 10}, "delivered": {"smtp": 7, "http": 1, "total": 8},
+			"failed": {"temporary": {"espblock": 1}, "permanent": {"bounce": 1, "total": 1}}}]}`)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	points, err := mg.GetStatsTotal(context.Background(), domain, StatsOptions{Resolution: "hour"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || !points[0].Time.Equal(time.Date(2017, 10, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Unexpected points: %#v", points)
+	}
+	c := points[0].Counters
+	if c["accepted"].Total != 10 || c["delivered"] != (StatCounter{Total: 8, ESP: 7, Webhook: 1}) || c["failed"].Total != 2 {
+		t.Fatalf("Unexpected counters: %#v", c)
+	}
+}
+
+func TestImportSuppressions(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Error("Expected a JSON body; got ", r.Header.Get("Content-Type"))
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		got = append(got, r.URL.Path+" "+string(body))
+		fmt.Fprint(w, `{"message": "ok"}`)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	ctx := context.Background()
+
+	if err := mg.ImportBounces(ctx, []Bounce{{Address: "a@example.com", Code: "550"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mg.ImportUnsubscribes(ctx, []Unsubscription{{Address: "b@example.com", Tag: "news"}, {Address: "c@example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mg.ImportComplaints(ctx, []Complaint{{Address: "d@example.com", Count: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"/v3/" + domain + `/bounces [{"code":"550","address":"a@example.com"}]`,
+		"/v3/" + domain + `/unsubscribes [{"address":"b@example.com","tags":["news"]},{"address":"c@example.com"}]`,
+		"/v3/" + domain + `/complaints [{"address":"d@example.com"}]`,
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Unexpected imports:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestBounceDecodedCode(t *testing.T) {
+	var b Bounce
+	if err := json.Unmarshal([]byte(`{"address": "a@example.com", "code": 550}`), &b); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := b.GetCode(); err != nil || c != 550 {
+		t.Fatalf("Expected 550; got %d, %v", c, err)
+	}
+}
+
+func TestCreateRouteEmptyExpression(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	mg.SetClient(&http.Client{Transport: failingTransport{t}})
+	for _, expr := range []string{"", "   "} {
+		_, err := mg.CreateRoute(Route{Expression: expr, Actions: []string{"stop()"}})
+		if err != ErrEmptyParam {
+			t.Fatalf("Expected ErrEmptyParam for %q; got %v", expr, err)
+		}
+	}
+}
+
+// failingTransport fails any test that makes an HTTP request through it.
+type failingTransport struct {
+	t *testing.T
+}
+
+func (f failingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	f.t.Fatal("Unexpected request: ", r.URL)
+	return nil, nil
+}
+
+func TestDomainWebhooks(t *testing.T) {
+	hooks := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var prefix string
+		for _, d := range []string{"other.example.com", domain} {
+			if p := "/v3/domains/" + d + "/webhooks"; strings.HasPrefix(r.URL.Path, p) {
+				prefix = p
+			}
+		}
+		if prefix == "" {
+			t.Error("Unexpected path: ", r.URL.Path)
+		}
+		id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		switch {
+		case r.Method == "POST":
+			hooks[r.FormValue("id")] = r.FormValue("url")
+		case r.Method == "PUT":
+			hooks[id] = r.FormValue("url")
+		case r.Method == "DELETE":
+			delete(hooks, id)
+		case id == "":
+			var items []string
+			for k, v := range hooks {
+				items = append(items, fmt.Sprintf(`"%s": {"urls": ["%s"]}`, k, v))
+			}
+			fmt.Fprintf(w, `{"webhooks": {%s}}`, strings.Join(items, ","))
+			return
+		default:
+			u, ok := hooks[id]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `{"webhook": {"urls": ["%s"]}}`, u)
+			return
+		}
+		fmt.Fprint(w, `{"message": "ok"}`)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	const d = "other.example.com"
+
+	if err := mg.CreateDomainWebhook(context.Background(), d, EventDelivered, "https://example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mg.UpdateDomainWebhook(context.Background(), d, EventDelivered, "https://example.com/b"); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := mg.GetDomainWebhook(context.Background(), d, EventDelivered); err != nil || u != "https://example.com/b" {
+		t.Fatalf("Unexpected webhook: %q, %v", u, err)
+	}
+	config, err := mg.GetDomainWebhooks(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Delivered) != 1 || config.Delivered[0] != "https://example.com/b" {
+		t.Fatalf("Unexpected webhooks: %#v", config)
+	}
+	if err := mg.DeleteDomainWebhook(context.Background(), d, EventDelivered); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mg.GetDomainWebhook(context.Background(), d, EventDelivered); err == nil {
+		t.Fatal("Expected the deleted webhook to be gone")
+	}
+
+	// The methods for the client's own domain read the same v3 responses.
+	if err := mg.CreateWebhook("opened", "https://example.com/o"); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := mg.GetWebhookByType("opened"); err != nil || u != "https://example.com/o" {
+		t.Fatalf("Unexpected webhook: %q, %v", u, err)
+	}
+	all, err := mg.GetWebhooks()
+	if err != nil || len(all) != 1 || all["opened"] != "https://example.com/o" {
+		t.Fatalf("Unexpected webhooks: %v, %v", all, err)
+	}
+}
+
+func TestParseWebhookPayload(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	// Signed with apiKey, as Mailgun would.
+	mac := hmac.New(sha256.New, []byte(apiKey))
+	mac.Write([]byte("1500000000" + "abcdef"))
+	sig := hex.EncodeToString(mac.Sum(nil))
+	if !VerifyWebhookSignature(apiKey, "abcdef", "1500000000", sig) {
+		t.Fatal("Expected a genuine signature to verify")
+	}
+	if VerifyWebhookSignature(apiKey, "abcdeg", "1500000000", sig) {
+		t.Fatal("Expected a signature over another token to fail")
+	}
+
+	body := fmt.Sprintf(`{"signature": {"timestamp": "1500000000", "token": "abcdef", "signature": "%s"},
+		"event-data": {"event": "delivered", "recipient": "joe@example.com"}}`, sig)
+	r, _ := http.NewRequest("POST", "https://example.com/hook", strings.NewReader(body))
+	payload, err := mg.ParseWebhookPayload(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.EventData.Name() != "delivered" || payload.EventData.Recipient() != "joe@example.com" {
+		t.Fatalf("Unexpected event: %#v", payload.EventData)
+	}
+
+	r, _ = http.NewRequest("POST", "https://example.com/hook", strings.NewReader(strings.Replace(body, "abcdef", "abcdeg", 1)))
+	if _, err := mg.ParseWebhookPayload(r); err != ErrInvalidWebhookSignature {
+		t.Fatal("Expected ErrInvalidWebhookSignature; got ", err)
+	}
+}
+
+func TestSendMime(t *testing.T) {
+	var mimeBody bytes.Buffer
+	w := multipart.NewWriter(&mimeBody)
+	fmt.Fprintf(&mimeBody, "From: me@example.com\r\nTo: you@example.com\r\nSubject: Hi\r\n"+
+		"MIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n", w.Boundary())
+	part, _ := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain"}})
+	fmt.Fprint(part, "Hello")
+	w.Close()
+	expected := mimeBody.String()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/"+domain+"/messages.mime" {
+			t.Error("Unexpected path: ", r.URL.Path)
+		}
+		f, h, err := r.FormFile("message")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if ct := h.Header.Get("Content-Type"); ct != "message/rfc822" {
+			t.Error("Expected a message/rfc822 part; got ", ct)
+		}
+		data, _ := ioutil.ReadAll(f)
+		if string(data) != expected {
+			t.Error("Unexpected message body: ", string(data))
+		}
+		if to := r.FormValue("to"); to != "you@example.com" {
+			t.Error("Unexpected recipient: ", to)
+		}
+		fmt.Fprint(w, `{"message": "Queued. Thank you.", "id": "<1@example.com>"}`)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	_, id, err := mg.SendMime(context.Background(), &MimeMessage{To: []string{"you@example.com"}, From: "me@example.com", MimeBody: &mimeBody})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "<1@example.com>" {
+		t.Fatal("Unexpected ID: ", id)
+	}
+}
+
+func TestValidateMessageErrors(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	tests := []struct {
+		m   *Message
+		err error
+	}{
+		{nil, ErrNilMessage},
+		{mg.NewMessage("", "Subject", "Text", "you@example.com"), ErrNoSender},
+		{mg.NewMessage("me@example.com", "Subject", "Text"), ErrNoRecipients},
+		{mg.NewMessage("me@example.com", "Subject", "Text", ""), ErrEmptyRecipient},
+		{mg.NewMessage("me@example.com", "Subject", "", "you@example.com"), ErrNoBody},
+		{mg.NewMIMEMessage(nil, "you@example.com"), ErrNoMIMEBody},
+	}
+	tooMany := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	tooMany.campaigns = []string{"a", "b", "c", "d"}
+	tests = append(tests, struct {
+		m   *Message
+		err error
+	}{tooMany, ErrTooManyCampaigns})
+
+	for i, test := range tests {
+		if err := validateMessage(test.m); err != test.err {
+			t.Errorf("Case %d: expected %v; got %v", i, test.err, err)
+		}
+	}
+	if _, _, err := mg.Send(context.Background(), tooMany); err != ErrTooManyCampaigns {
+		t.Fatal("Expected Send to report ErrTooManyCampaigns; got ", err)
+	}
+}
+
+func TestSetReplyTo(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	m.AddHeader("Reply-To", "old@example.com")
+	m.SetReplyTo("support@example.com")
+	if err := validateMessage(m); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := newSendPayload(m, m.to, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replyTo []string
+	for _, kv := range payload.Values {
+		if kv.key == "h:Reply-To" {
+			replyTo = append(replyTo, kv.value)
+		}
+	}
+	if len(replyTo) != 1 || replyTo[0] != "support@example.com" {
+		t.Fatal("Unexpected Reply-To headers: ", replyTo)
+	}
+
+	m.SetReplyTo("support")
+	if err := validateMessage(m); err != ErrInvalidReplyTo {
+		t.Fatal("Expected ErrInvalidReplyTo; got ", err)
+	}
+}
+
+func TestSetTrackingClicksMode(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	for _, test := range []struct {
+		set      func(*Message)
+		expected string
+	}{
+		{func(m *Message) { m.SetTrackingClicks(true) }, "yes"},
+		{func(m *Message) { m.SetTrackingClicks(false) }, "no"},
+		{func(m *Message) { m.SetTrackingClicksMode(TrackingClicksHTMLOnly) }, "htmlonly"},
+	} {
+		m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+		test.set(m)
+		payload, err := newSendPayload(m, m.to, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		for _, kv := range payload.Values {
+			if kv.key == "o:tracking-clicks" {
+				got = kv.value
+			}
+		}
+		if got != test.expected {
+			t.Fatalf("Expected o:tracking-clicks=%s; got %q", test.expected, got)
+		}
+	}
+}
+
+func TestTLSOptions(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	options := func(m *Message) map[string]string {
+		payload, err := newSendPayload(m, m.to, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := map[string]string{}
+		for _, kv := range payload.Values {
+			if kv.key == "o:require-tls" || kv.key == "o:skip-verification" {
+				found[kv.key] = kv.value
+			}
+		}
+		return found
+	}
+
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	if found := options(m); len(found) != 0 {
+		t.Fatal("Expected no TLS options by default; got ", found)
+	}
+	m.SetRequireTLS(true)
+	m.SetSkipVerification(false)
+	found := options(m)
+	if len(found) != 2 || found["o:require-tls"] != "yes" || found["o:skip-verification"] != "no" {
+		t.Fatal("Unexpected TLS options: ", found)
+	}
+}
+
+func TestValidateMessageRecipients(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text")
+	m.AddBCC("hidden@example.com")
+	if err := validateMessage(m); err != nil {
+		t.Fatal("Expected a Bcc:-only message to be valid; got ", err)
+	}
+
+	for i := 0; i < MaxNumberOfRecipients; i++ {
+		m.AddCC(fmt.Sprintf("cc%d@example.com", i))
+	}
+	err := validateMessage(m)
+	tooMany, ok := err.(*TooManyRecipientsError)
+	if !ok || tooMany.Count != MaxNumberOfRecipients+1 {
+		t.Fatal("Expected a TooManyRecipientsError counting 1001; got ", err)
+	}
+	if !errors.Is(err, ErrTooManyRecipients) {
+		t.Fatal("Expected the error to match ErrTooManyRecipients")
+	}
+}
+
+func TestAddTag(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	for _, tag := range []string{"news", "News", "weekly", "promo"} {
+		if err := m.AddTag(tag); err != nil {
+			t.Fatalf("Unexpected error adding %s: %v", tag, err)
+		}
+	}
+	if len(m.tags) != 3 {
+		t.Fatal("Expected duplicate tags to be dropped; got ", m.tags)
+	}
+	if err := m.AddTag("extra"); err != ErrTooManyTags {
+		t.Fatal("Expected ErrTooManyTags; got ", err)
+	}
+	if err := m.AddTag("WEEKLY"); err != nil {
+		t.Fatal("Expected a duplicate to be accepted at the limit; got ", err)
+	}
+}
+
+func TestValidateAddressAndBulk(t *testing.T) {
+	var uploaded string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v4/address/validate":
+			fmt.Fprint(w, `{"address": "joe@exmaple.com", "result": "undeliverable", "risk": "high",
+				"is_disposable_address": true, "did_you_mean": "joe@example.com"}`)
+		case strings.HasPrefix(r.URL.Path, "/v4/address/validate/bulk/") && r.Method == "POST":
+			f, _, err := r.FormFile("file")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			data, _ := ioutil.ReadAll(f)
+			uploaded = string(data)
+			fmt.Fprint(w, `{"message": "The validation job was submitted."}`)
+		case strings.HasPrefix(r.URL.Path, "/v4/address/validate/bulk/"):
+			fmt.Fprint(w, `{"id": "job", "status": "uploaded", "quantity": 2, "records_processed": 2,
+				"download_url": {"csv": "https://example.com/job.csv"}, "summary": {"result": {"deliverable": 2}}}`)
+		default:
+			t.Error("Unexpected path: ", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	ctx := context.Background()
+
+	v, err := mg.ValidateAddress(ctx, "joe@exmaple.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.IsValid || v.RiskLevel != RiskHigh || !v.IsDisposableAddress || v.DidYouMean != "joe@example.com" {
+		t.Fatalf("Unexpected validation: %#v", v)
+	}
+
+	job, err := mg.ValidateEmailBulk(ctx, []string{"a@example.com", "b@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded != "email\na@example.com\nb@example.com\n" {
+		t.Fatalf("Unexpected upload: %q", uploaded)
+	}
+	job, err = mg.GetBulkValidation(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != "uploaded" || job.Summary.Result["deliverable"] != 2 || job.DownloadURL.CSV == "" {
+		t.Fatalf("Unexpected job: %#v", job)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var hits int
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		data, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/bounces/down@example.com"):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case strings.HasSuffix(r.URL.Path, "/bounces/gone@example.com"):
+			w.WriteHeader(http.StatusNotFound)
+		case hits < 3:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			fmt.Fprint(w, `{"id": "<20190101@example.com>", "message": "Queued. Thank you."}`)
+		}
+	}))
+	defer srv.Close()
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, Jitter: 0.5}
+	mg := NewMailgun(domain, apiKey, "", WithRetryPolicy(policy)).(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	m.AddReaderAttachment("note.txt", strings.NewReader("attached"))
+	_, id, err := mg.Send(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits != 3 || id != "<20190101@example.com>" {
+		t.Fatalf("Expected success on the third attempt; got %d attempts and id %q", hits, id)
+	}
+	for i, body := range bodies {
+		if !strings.Contains(body, "attached") {
+			t.Fatalf("Expected attempt %d to carry the attachment", i+1)
+		}
+	}
+
+	hits = 0
+	_, err = mg.GetSingleBounce("down@example.com")
+	apiErr, ok := IsAPIError(err)
+	if !ok || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.RetryCount != 2 || hits != 3 {
+		t.Fatalf("Expected 503 after 2 retries; got %v after %d attempts", err, hits)
+	}
+
+	hits = 0
+	_, err = mg.GetSingleBounce("gone@example.com")
+	apiErr, ok = IsAPIError(err)
+	if !ok || apiErr.StatusCode != http.StatusNotFound || apiErr.RetryCount != 0 || hits != 1 {
+		t.Fatalf("Expected 404 without retries; got %v after %d attempts", err, hits)
+	}
+
+	mg.retryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r := newHTTPRequest(generateApiUrl(mg, bouncesEndpoint) + "/down@example.com")
+	r.setContext(ctx)
+	r.setClient(mg)
+	if _, err := makeGetRequest(r); err != context.DeadlineExceeded {
+		t.Fatalf("Expected the wait between attempts to end with the context; got %v", err)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 3}
+	for retry, want := range []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second, time.Second} {
+		if got := p.backoff(retry + 1); got != want {
+			t.Errorf("Expected retry %d to wait %v; got %v", retry+1, want, got)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	_, err := mg.GetSingleBounce("busy@example.com")
+	apiErr, ok := IsAPIError(err)
+	if !ok || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected a 429; got %v", err)
+	}
+	if got := apiErr.RetryAfter(); got != 30*time.Second {
+		t.Fatalf("Expected to be asked to wait 30s; got %v", got)
+	}
+
+	mg.retryPolicy = RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour, MaxRetryAfter: time.Millisecond}
+	hits = 0
+	start := time.Now()
+	_, err = mg.GetSingleBounce("busy@example.com")
+	if apiErr, ok := IsAPIError(err); !ok || apiErr.RetryCount != 1 || hits != 2 {
+		t.Fatalf("Expected a 429 after 1 retry; got %v after %d attempts", err, hits)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Expected the Retry-After wait to be capped; took %v", elapsed)
+	}
+
+	for header, want := range map[string]time.Duration{"": 0, "soon": 0, "-5": 0, "Mon, 02 Jan 2006 15:04:05 GMT": 0} {
+		if got := parseRetryAfter(header); got != want {
+			t.Errorf("Expected Retry-After %q to give %v; got %v", header, want, got)
+		}
+	}
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(future); got <= 0 || got > time.Minute {
+		t.Errorf("Expected Retry-After %q to give up to a minute; got %v", future, got)
+	}
+}
+
+func TestAPIErrorDebug(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "2b6b1a2c")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"message": "Sandbox subdomains are for test purposes only", "detail": "you@example.com"}`)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	_, _, err := mg.Send(context.Background(), mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com"))
+	apiErr, ok := IsAPIError(err)
+	if !ok {
+		t.Fatalf("Expected an APIError; got %v", err)
+	}
+	if apiErr.RequestID != "2b6b1a2c" || !strings.Contains(string(apiErr.RawBody), `"detail": "you@example.com"`) {
+		t.Fatalf("Unexpected request ID %q or body %q", apiErr.RequestID, apiErr.RawBody)
+	}
+	debug := apiErr.Debug()
+	for _, want := range []string{"StatusCode=400", `RequestID="2b6b1a2c"`, "/v3/" + domain + "/messages", "detail"} {
+		if !strings.Contains(debug, want) {
+			t.Errorf("Expected %q in %s", want, debug)
+		}
+	}
+}
+
+func TestLogger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"message": "Created 1 credentials pair(s)"}`)
+	}))
+	defer srv.Close()
+
+	var info, debug bytes.Buffer
+	for _, l := range []struct {
+		w     *bytes.Buffer
+		level LogLevel
+	}{{&info, LogLevelInfo}, {&debug, LogLevelDebug}} {
+		mg := NewMailgun(domain, apiKey, "", WithLogger(NewDefaultLogger(l.w, l.level))).(*MailgunImpl)
+		mg.apiBase = srv.URL + "/v3"
+		if err := mg.CreateCredential("alice@"+domain, "s3cr3t-passw0rd"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	line := fmt.Sprintf("mailgun: POST %s/v3/domains/%s/credentials 200 OK ", srv.URL, domain)
+	if !strings.HasPrefix(info.String(), line) || strings.Count(info.String(), "\n") != 1 {
+		t.Fatalf("Unexpected info log: %q", info.String())
+	}
+	if !strings.HasPrefix(debug.String(), line) || !strings.Contains(debug.String(), "Created 1 credentials pair(s)") ||
+		!strings.Contains(debug.String(), "login=alice%40"+domain) {
+		t.Fatalf("Unexpected debug log: %q", debug.String())
+	}
+	secret := base64.StdEncoding.EncodeToString([]byte(basicAuthUser + ":" + apiKey))
+	for _, leaked := range []string{secret, apiKey, "s3cr3t-passw0rd"} {
+		if strings.Contains(debug.String(), leaked) {
+			t.Fatalf("Expected %q to be redacted from %s", leaked, debug.String())
+		}
+	}
+	if !strings.Contains(debug.String(), "Authorization: "+redacted) {
+		t.Fatalf("Expected the Authorization header to be redacted in %s", debug.String())
+	}
+}
+
+func TestSpanOperation(t *testing.T) {
+	for _, c := range []struct {
+		method, url       string
+		operation, domain string
+	}{
+		{"POST", "https://api.mailgun.net/v3/example.com/messages", "send", "example.com"},
+		{"POST", "https://api.mailgun.net/v3/example.com/messages.mime", "sendMime", "example.com"},
+		{"GET", "https://api.mailgun.net/v3/example.com/events?limit=300", "listEvents", "example.com"},
+		{"GET", "https://api.mailgun.net/v3/example.com/bounces/you@example.com", "getBounces", "example.com"},
+		{"PUT", "https://api.mailgun.net/v3/domains/example.com/tracking/click", "putTracking", "example.com"},
+		{"GET", "https://api.mailgun.net/v3/domains/example.com", "getDomains", "example.com"},
+		{"GET", "https://api.mailgun.net/v3/domains", "getDomains", ""},
+		{"GET", "https://api.mailgun.net/v1/ip_pools", "getIpPools", ""},
+		{"GET", "http://127.0.0.1:8080/mock/v3/lists/team@example.com/members", "getLists", ""},
+	} {
+		req := httptest.NewRequest(c.method, c.url, nil)
+		if operation, domain := spanOperation(req); operation != c.operation || domain != c.domain {
+			t.Errorf("Expected %s %s to be %s for %q; got %s for %q", c.method, c.url, c.operation, c.domain, operation, domain)
+		}
+	}
+}
+
+func TestRateLimitedTransport(t *testing.T) {
+	var limited bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limited {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"id": "<20190101@example.com>", "message": "Queued. Thank you."}`)
+	}))
+	defer srv.Close()
+	transport := NewRateLimitedTransport(nil, map[EndpointFamily]RateLimit{
+		EndpointFamilySend: {Rate: 50, Burst: 2},
+	}, 5*time.Second)
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	mg.SetClient(&http.Client{Transport: transport})
+	ctx := context.Background()
+	send := func(ctx context.Context) error {
+		_, _, err := mg.Send(ctx, mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com"))
+		return err
+	}
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := send(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("Expected the sends beyond the burst to wait their turn; took %v", elapsed)
+	}
+	for u, want := range map[string]EndpointFamily{
+		"/v3/" + domain + "/messages":      EndpointFamilySend,
+		"/v3/" + domain + "/messages.mime": EndpointFamilySend,
+		"/v3/" + domain + "/events":        EndpointFamilyEvents,
+		"/v4/address/validate":             EndpointFamilyValidation,
+		"/v3/" + domain + "/bounces":       EndpointFamilyOther,
+	} {
+		method := "POST"
+		if want == EndpointFamilyEvents || want == EndpointFamilyValidation {
+			method = "GET"
+		}
+		if got := endpointFamily(httptest.NewRequest(method, u, nil)); got != want {
+			t.Errorf("Expected %s to be in family %s; got %s", u, want, got)
+		}
+	}
+
+	limited = true
+	if err := send(ctx); err == nil {
+		t.Fatal("Expected the send to be rate limited")
+	}
+	limited = false
+	err := send(ctx)
+	if apiErr, ok := IsAPIError(err); !ok || !errors.Is(apiErr, ErrRateLimitWait) {
+		t.Fatalf("Expected the Retry-After wait to exceed MaxWait; got %v", err)
+	}
+
+	b := &tokenBucket{rate: 10, burst: 1, tokens: 1}
+	now := time.Now()
+	if wait := b.reserve(now, 0); wait != 0 {
+		t.Fatal("Expected the first request to go at once; waited ", wait)
+	}
+	if wait := b.reserve(now, 0); wait != 100*time.Millisecond {
+		t.Fatal("Expected the second request to wait 100ms; waited ", wait)
+	}
+	if wait := b.reserve(now, 150*time.Millisecond); wait != -1 {
+		t.Fatal("Expected the third request to be refused; waited ", wait)
+	}
+}
+
+func TestStoredMessageByURL(t *testing.T) {
+	var deleted bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/domains/"+domain+"/messages/KEY" {
+			t.Error("Unexpected path: ", r.URL.Path)
+			return
+		}
+		switch {
+		case r.Method == "DELETE":
+			deleted = true
+			fmt.Fprint(w, `{"message": "Message has been deleted"}`)
+		case r.Header.Get("Accept") == "message/rfc2822":
+			fmt.Fprint(w, `{"recipients": "you@example.com", "body-mime": "Subject: Hi\r\n\r\nHello\r\n"}`)
+		default:
+			fmt.Fprint(w, `{"recipients": "you@example.com", "from": "me@example.com", "subject": "Hi",
+				"body-plain": "Hello", "attachments": [{"name": "a.txt", "size": 3, "content-type": "text/plain"}],
+				"message-headers": [["Subject", "Hi"]]}`)
+		}
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	ctx := context.Background()
+	e := Event{"storage": map[string]interface{}{"url": srv.URL + "/v3/domains/" + domain + "/messages/KEY"}}
+
+	m, err := mg.GetStoredMessageByURL(ctx, e.StorageURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.From != "me@example.com" || m.BodyPlain != "Hello" || len(m.Attachments) != 1 || m.MessageHeaders[0][1] != "Hi" {
+		t.Fatalf("Unexpected message: %#v", m)
+	}
+
+	rc, err := mg.GetStoredMessageRawByURL(ctx, e.StorageURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	raw, _ := ioutil.ReadAll(rc)
+	if string(raw) != "Subject: Hi\r\n\r\nHello\r\n" {
+		t.Fatalf("Unexpected MIME: %q", raw)
+	}
+	raw, err = mg.GetStoredMessageMimeBytes(ctx, e.StorageURL())
+	if err != nil || string(raw) != "Subject: Hi\r\n\r\nHello\r\n" {
+		t.Fatalf("Unexpected MIME bytes %q: %v", raw, err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := mg.GetStoredMessageMimeReader(cancelled, e.StorageURL()); err != context.Canceled {
+		t.Fatalf("Expected the fetch to end with the context; got %v", err)
+	}
+
+	if err := mg.DeleteStoredMessage("KEY"); err != nil || !deleted {
+		t.Fatalf("Expected the message to be deleted; got %v", err)
+	}
+}
+
+func TestMimeBytesMatchesEncodingJSON(t *testing.T) {
+	for _, doc := range []string{
+		`"Subject: Hi\r\n\r\nHello\r\n"`,
+		`"caf\u00e9 \ud83d\ude00 \/ \"q\" \\ \t\b\f"`,
+		`"lone \ud83d here, \ude00 there, \ud83d\u0041"`,
+		`""`,
+	} {
+		var want string
+		if err := json.Unmarshal([]byte(doc), &want); err != nil {
+			t.Fatal(err)
+		}
+		var got mimeBytes
+		if err := json.Unmarshal([]byte(doc), &got); err != nil || string(got) != want {
+			t.Fatalf("Decoding %s: got %q, want %q (%v)", doc, got, want, err)
+		}
+	}
+	for _, doc := range []string{`"\x"`, `"\u12"`, `12`} {
+		var got mimeBytes
+		if err := json.Unmarshal([]byte(doc), &got); err == nil {
+			t.Fatalf("Expected %s to be refused", doc)
+		}
+	}
+}
+
+func TestDomainCampaigns(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		base := "/v3/other.example.com/campaigns"
+		switch {
+		case r.URL.Path == base && r.Method == "GET":
+			if r.URL.Query().Get("limit") != "10" || r.URL.Query().Get("skip") != "" {
+				t.Error("Unexpected paging: ", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"total_count": 1, "items": [{"id": "spring", "name": "Spring", "submitted_count": 5}]}`)
+		case r.URL.Path == base && r.Method == "POST":
+			fmt.Fprintf(w, `{"message": "Campaign created", "campaign": {"id": "c1", "name": %q}}`, r.FormValue("name"))
+		case r.URL.Path == base+"/spring" && r.Method == "GET":
+			fmt.Fprint(w, `{"id": "spring", "name": "Spring", "opened_count": 3, "clicked_count": 2}`)
+		case r.URL.Path == base+"/spring" && r.Method == "POST":
+			if r.FormValue("id") != "" {
+				t.Error("Expected the ID to be kept")
+			}
+			fmt.Fprintf(w, `{"message": "Campaign updated", "campaign": {"id": "spring", "name": %q}}`, r.FormValue("name"))
+		case r.URL.Path == base+"/spring" && r.Method == "DELETE":
+			fmt.Fprint(w, `{"message": "Campaign deleted", "id": "spring"}`)
+		default:
+			t.Error("Unexpected request: ", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	const d = "other.example.com"
+
+	campaigns, err := mg.GetDomainCampaigns(context.Background(), d, 10, DefaultSkip)
+	if err != nil || len(campaigns) != 1 || campaigns[0].SubmittedCount != 5 {
+		t.Fatalf("Unexpected campaigns: %v, %v", campaigns, err)
+	}
+	c, err := mg.CreateDomainCampaign(context.Background(), d, "Summer")
+	if err != nil || c.Id != "c1" || c.Name != "Summer" {
+		t.Fatalf("Unexpected campaign: %v, %v", c, err)
+	}
+	if _, err := mg.CreateDomainCampaign(context.Background(), d, ""); err != ErrEmptyParam {
+		t.Fatalf("Expected ErrEmptyParam; got %v", err)
+	}
+	c, err = mg.GetDomainCampaign(context.Background(), d, "spring")
+	if err != nil || c.OpenedCount != 3 || c.ClickedCount != 2 {
+		t.Fatalf("Unexpected campaign: %v, %v", c, err)
+	}
+	c, err = mg.UpdateDomainCampaign(context.Background(), d, "spring", "Spring 2019")
+	if err != nil || c.Name != "Spring 2019" {
+		t.Fatalf("Unexpected campaign: %v, %v", c, err)
+	}
+	if err := mg.DeleteDomainCampaign(context.Background(), d, "spring"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventsIterator(t *testing.T) {
+	var srv *httptest.Server
+	fail := false
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		var items string
+		switch page {
+		case "":
+			if r.URL.Query().Get("event") != "delivered" {
+				t.Error("Expected the event filter to be sent")
+			}
+			items, page = `{"event": "delivered", "recipient": "a@example.com"}`, "2"
+		case "2":
+			items, page = `{"event": "delivered", "recipient": "b@example.com"}`, "3"
+		}
+		next := fmt.Sprintf("%s/v3/%s/events?page=%s", srv.URL, domain, page)
+		fmt.Fprintf(w, `{"items": [%s], "paging": {"next": %q, "previous": %q}}`, items, next, next)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	ctx := context.Background()
+
+	it := mg.Events(domain, EventsOptions{Event: "delivered"})
+	var recipients []string
+	for {
+		events, ok := it.Next(ctx)
+		if !ok {
+			break
+		}
+		for _, e := range events {
+			recipients = append(recipients, e.Recipient())
+		}
+	}
+	if it.Err() != nil || strings.Join(recipients, ",") != "a@example.com,b@example.com" {
+		t.Fatalf("Unexpected walk: %v, %v", recipients, it.Err())
+	}
+	if _, ok := it.Next(ctx); ok {
+		t.Fatal("Expected the iterator to stay exhausted")
+	}
+
+	it.Rewind()
+	events, ok := it.Next(ctx)
+	if !ok || events[0].Recipient() != "a@example.com" {
+		t.Fatalf("Expected Rewind to return to the first page; got %v", events)
+	}
+
+	fail = true
+	if _, ok := it.Next(ctx); ok {
+		t.Fatal("Expected a failed fetch to stop the iterator")
+	}
+	if apiErr, ok := IsAPIError(it.Err()); !ok || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Expected Err to report the API error; got %v", it.Err())
+	}
+
+	fail = false
+	it.Rewind()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, ok := it.Next(cancelled); ok || it.Err() != context.Canceled {
+		t.Fatalf("Expected the iterator to honor cancellation; got %v", it.Err())
+	}
+}
+
+func TestNewHTMLMessage(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewHTMLMessage("me@example.com", "Subject", "<p>Hello</p>", "Hello", "you@example.com")
+	pm := m.specific.(*plainMessage)
+	if pm.html != "<p>Hello</p>" || pm.text != "Hello" {
+		t.Fatalf("Unexpected bodies: %q, %q", pm.html, pm.text)
+	}
+	if err := validateMessage(m); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bodies := range [][2]string{{"", "Hello"}, {"<p>Hello</p>", " "}} {
+		m := NewHTMLMessage("me@example.com", "Subject", bodies[0], bodies[1], "you@example.com")
+		if err := validateMessage(m); err == nil {
+			t.Errorf("Expected Send to refuse bodies %q", bodies)
+		}
+		if _, _, err := mg.Send(context.Background(), m); err == nil {
+			t.Errorf("Expected Send to refuse bodies %q", bodies)
+		}
+	}
+}
+
+func TestAMPHtml(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	m.SetAMPHtml("<!doctype html><html ⚡4email></html>")
+	if err := validateMessage(m); err != ErrAMPWithoutHTML {
+		t.Fatalf("Expected ErrAMPWithoutHTML; got %v", err)
+	}
+
+	m.SetHtml("<p>Hello</p>")
+	if err := validateMessage(m); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := newSendPayload(m, m.to, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var amp string
+	for _, kv := range payload.Values {
+		if kv.key == "amp-html" {
+			amp = kv.value
+		}
+	}
+	if amp != "<!doctype html><html ⚡4email></html>" {
+		t.Fatalf("Expected the AMP body in the payload; got %q", amp)
+	}
+}
+
+func TestMessageTTL(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	for _, test := range []struct {
+		ttl      time.Duration
+		expected string
+	}{
+		{10 * time.Minute, "600"},
+		{1500 * time.Millisecond, "2"},
+		{time.Millisecond, "1"},
+	} {
+		m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+		m.SetMessageTTL(test.ttl)
+		if err := validateMessage(m); err != nil {
+			t.Fatal(err)
+		}
+		payload, err := newSendPayload(m, m.to, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		for _, kv := range payload.Values {
+			if kv.key == "o:message-ttl" {
+				got = kv.value
+			}
+		}
+		if got != test.expected {
+			t.Fatalf("Expected o:message-ttl=%s for %v; got %q", test.expected, test.ttl, got)
+		}
+	}
+
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+		m.SetMessageTTL(ttl)
+		if err := validateMessage(m); err != ErrInvalidMessageTTL {
+			t.Fatalf("Expected ErrInvalidMessageTTL for %v; got %v", ttl, err)
+		}
+	}
+}
+
+func TestMessageClone(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	m.AddCC("cc@example.com")
+	m.AddTag("base")
+	m.AddHeader("X-Base", "yes")
+	m.AddVariable("base", "yes")
+	m.SetDeliveryTime(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	c := m.Clone()
+	if err := c.AddRecipient("other@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	c.AddCC("other-cc@example.com")
+	c.AddTag("variant")
+	c.AddHeader("X-Variant", "yes")
+	c.AddVariable("variant", "yes")
+	c.SetDeliveryTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	if len(m.to) != 1 || len(c.to) != 2 {
+		t.Fatalf("Expected the clone's new recipient not to appear in the original; got %v and %v", m.to, c.to)
+	}
+	if cc := m.specific.(*plainMessage).cc; len(cc) != 1 {
+		t.Fatalf("Expected the original to keep one Cc: recipient; got %v", cc)
+	}
+	if len(m.tags) != 1 || len(m.headers) != 1 || len(m.variables) != 1 {
+		t.Fatalf("Expected the original to keep its tags, headers, and variables; got %v, %v, %v", m.tags, m.headers, m.variables)
+	}
+	if m.deliveryTime.Year() != 2019 {
+		t.Fatalf("Expected the original to keep its delivery time; got %v", m.deliveryTime)
+	}
+	if len(c.tags) != 2 || len(c.headers) != 2 || len(c.variables) != 2 || c.specific.recipientCount() != 2 {
+		t.Fatal("Expected the clone to start from the original's settings")
+	}
+}
+
+func TestClearDeliveryTime(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	hasDeliveryTime := func(m *Message) bool {
+		payload, err := newSendPayload(m, m.to, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, kv := range payload.Values {
+			if kv.key == "o:deliverytime" {
+				return true
+			}
+		}
+		return false
+	}
+
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	m.SetDeliveryTime(time.Now().Add(time.Hour))
+	if !hasDeliveryTime(m) {
+		t.Fatal("Expected o:deliverytime once scheduled")
+	}
+	m.ClearDeliveryTime()
+	if hasDeliveryTime(m) {
+		t.Fatal("Expected ClearDeliveryTime to omit o:deliverytime")
+	}
+
+	m.SetDeliveryTime(time.Now().Add(time.Hour))
+	m.SetDeliveryTime(time.Time{})
+	if hasDeliveryTime(m) {
+		t.Fatal("Expected the zero time to omit o:deliverytime")
+	}
+}
+
+func TestIPs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v3/ips" && r.Method == "GET":
+			if r.URL.Query().Get("dedicated") != "true" {
+				t.Error("Expected only dedicated IPs to be asked for")
+			}
+			fmt.Fprint(w, `{"total_count": 2, "items": ["192.0.2.1", "192.0.2.2"]}`)
+		case r.URL.Path == "/v3/ips/192.0.2.1":
+			fmt.Fprint(w, `{"ip": "192.0.2.1", "rdns": "mail.example.com", "dedicated": true}`)
+		case r.URL.Path == "/v3/domains/"+domain+"/ips" && r.Method == "GET":
+			fmt.Fprint(w, `{"total_count": 1, "items": ["192.0.2.1"]}`)
+		case r.URL.Path == "/v3/domains/"+domain+"/ips" && r.Method == "POST":
+			if r.FormValue("ip") != "192.0.2.2" {
+				t.Error("Unexpected IP: ", r.FormValue("ip"))
+			}
+			fmt.Fprint(w, `{"message": "success"}`)
+		case r.URL.Path == "/v3/domains/"+domain+"/ips/192.0.2.2" && r.Method == "DELETE":
+			fmt.Fprint(w, `{"message": "success"}`)
+		case r.URL.Path == "/v1/ip_pools":
+			fmt.Fprint(w, `{"ip_pools": [{"pool_id": "p1", "name": "Transactional", "ips": ["192.0.2.1"]}], "message": "success"}`)
+		default:
+			t.Error("Unexpected request: ", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	ips, err := mg.GetIPs(context.Background(), true)
+	if err != nil || len(ips) != 2 || ips[1].IP != "192.0.2.2" {
+		t.Fatalf("Unexpected IPs: %v, %v", ips, err)
+	}
+	ip, err := mg.GetIP(context.Background(), "192.0.2.1")
+	if err != nil || ip.RDNS != "mail.example.com" || !ip.Dedicated {
+		t.Fatalf("Unexpected IP: %v, %v", ip, err)
+	}
+	ips, err = mg.GetDomainIPs(context.Background(), domain)
+	if err != nil || len(ips) != 1 {
+		t.Fatalf("Unexpected domain IPs: %v, %v", ips, err)
+	}
+	if err := mg.AssignIPToDomain(context.Background(), domain, "192.0.2.2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mg.DeleteDomainIP(context.Background(), domain, "192.0.2.2"); err != nil {
+		t.Fatal(err)
+	}
+	pools, err := mg.GetIPPools(context.Background())
+	if err != nil || len(pools) != 1 || pools[0].ID != "p1" || pools[0].IPs[0] != "192.0.2.1" {
+		t.Fatalf("Unexpected pools: %v, %v", pools, err)
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := mg.GetIPs(cancelled, false); err != context.Canceled {
+		t.Fatalf("Expected the call to end with the context; got %v", err)
+	}
+}
+
+func TestDomainTracking(t *testing.T) {
+	updates := map[string]url.Values{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		base := "/v3/domains/" + domain + "/tracking"
+		if r.Method == "GET" && r.URL.Path == base {
+			fmt.Fprint(w, `{"tracking": {"click": {"active": "htmlonly"}, "open": {"active": true},
+				"unsubscribe": {"active": false, "html_footer": "<a href=\"%unsubscribe_url%\">Unsubscribe</a>", "text_footer": "%unsubscribe_url%"}}}`)
+			return
+		}
+		if r.Method != "PUT" || !strings.HasPrefix(r.URL.Path, base+"/") {
+			t.Error("Unexpected request: ", r.Method, r.URL.Path)
+			return
+		}
+		r.ParseForm()
+		updates[strings.TrimPrefix(r.URL.Path, base+"/")] = r.PostForm
+		fmt.Fprint(w, `{"message": "Domain tracking settings have been updated"}`)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	tracking, err := mg.GetDomainTracking(context.Background(), domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracking.ClickTracking() != "htmlonly" || !tracking.Open.Active || tracking.Unsubscribe.Active || tracking.Unsubscribe.TextFooter != "%unsubscribe_url%" {
+		t.Fatalf("Unexpected tracking: %#v", tracking)
+	}
+
+	if err := mg.UpdateClickTracking(context.Background(), domain, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := mg.UpdateOpenTracking(context.Background(), domain, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := mg.UpdateUnsubscribeTracking(context.Background(), domain, "yes", "", "Unsubscribe: %unsubscribe_url%"); err != nil {
+		t.Fatal(err)
+	}
+	if updates["click"].Get("active") != "yes" || updates["open"].Get("active") != "no" {
+		t.Fatalf("Unexpected updates: %v", updates)
+	}
+	if u := updates["unsubscribe"]; u.Get("active") != "yes" || u.Get("text_footer") != "Unsubscribe: %unsubscribe_url%" || u["html_footer"] != nil {
+		t.Fatalf("Unexpected unsubscribe update: %v", u)
+	}
+	if err := mg.UpdateUnsubscribeTracking(context.Background(), domain, "maybe", "", ""); err == nil {
+		t.Fatal("Expected an error for an unknown setting")
+	}
+}
+
+func TestDomainConnection(t *testing.T) {
+	var update url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/domains/"+domain+"/connection" {
+			t.Error("Unexpected path: ", r.URL.Path)
+			return
+		}
+		if r.Method == "PUT" {
+			r.ParseForm()
+			update = r.PostForm
+			fmt.Fprint(w, `{"message": "Domain connection settings have been updated, may take 10 minutes to fully propagate"}`)
+			return
+		}
+		fmt.Fprint(w, `{"connection": {"require_tls": true, "skip_verification": false}}`)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	conn, err := mg.GetDomainConnection(context.Background(), domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !conn.RequireTLS || conn.SkipVerification {
+		t.Fatalf("Unexpected connection settings: %#v", conn)
+	}
+	if err := mg.UpdateDomainConnection(context.Background(), domain, DomainConnection{SkipVerification: true}); err != nil {
+		t.Fatal(err)
+	}
+	if update.Get("require_tls") != "false" || update.Get("skip_verification") != "true" {
+		t.Fatalf("Unexpected update: %v", update)
+	}
+}
+
+func TestDomainSpamAction(t *testing.T) {
+	var calls int
+	var update url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/v3/domains/"+domain {
+			t.Error("Unexpected path: ", r.URL.Path)
+			return
+		}
+		if r.Method == "PUT" {
+			r.ParseForm()
+			update = r.PostForm
+			fmt.Fprint(w, `{"message": "Domain has been updated"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"domain": {"name": %q, "spam_action": "tag"}}`, domain)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	action, err := mg.GetDomainSpamAction(context.Background(), domain)
+	if err != nil || action != SpamActionTag {
+		t.Fatalf("Expected %q; got %q (%v)", SpamActionTag, action, err)
+	}
+	if err := mg.SetDomainSpamAction(context.Background(), domain, SpamActionBlock); err != nil {
+		t.Fatal(err)
+	}
+	if update.Get("spam_action") != "block" {
+		t.Fatalf("Unexpected update: %v", update)
+	}
+	calls = 0
+	if err := mg.SetDomainSpamAction(context.Background(), domain, "quarantine"); err == nil || calls != 0 {
+		t.Fatalf("Expected an unknown action to be refused without a call; got %v after %d calls", err, calls)
+	}
+	err = mg.UpdateDomainPolicy(context.Background(), domain, DomainPolicyUpdate{SpamAction: "quarantine"})
+	if err == nil || calls != 0 {
+		t.Fatalf("Expected the policy update to refuse an unknown action without a call; got %v after %d calls", err, calls)
+	}
+}
+
+func TestDomainDKIM(t *testing.T) {
+	settings := map[string]url.Values{}
+	var rotated bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v3/domains/"+domain:
+			selector := "mx"
+			if rotated {
+				selector = "pdk1"
+			}
+			fmt.Fprintf(w, `{"domain": {"name": %q}, "sending_dns_records": [
+				{"record_type": "TXT", "name": %q, "value": "v=spf1 include:mailgun.org ~all", "valid": "valid"},
+				{"record_type": "TXT", "name": "%s._domainkey.%s", "value": "k=rsa; p=MIGfMA0GCSqGSIb3", "valid": "unknown"}]}`,
+				domain, domain, selector, domain)
+		case r.Method == "POST" && r.URL.Path == "/v1/dkim_management/domains/"+domain+"/rotate":
+			rotated = true
+			fmt.Fprint(w, `{"message": "DKIM key rotated"}`)
+		case r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/v3/domains/"+domain+"/dkim_"):
+			r.ParseForm()
+			settings[path.Base(r.URL.Path)] = r.PostForm
+			fmt.Fprint(w, `{"message": "Domain DKIM settings have been updated"}`)
+		default:
+			t.Error("Unexpected request: ", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	info, err := mg.GetDomainDKIM(context.Background(), domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Selector != "mx" || info.PublicKey != "MIGfMA0GCSqGSIb3" || info.Valid {
+		t.Fatalf("Unexpected DKIM info: %#v", info)
+	}
+	info, err = mg.RotateDKIMKey(context.Background(), domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Selector != "pdk1" {
+		t.Fatal("Expected the rotated key; got selector ", info.Selector)
+	}
+
+	if err := mg.SetDKIMSelector(context.Background(), domain, "s2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mg.SetDKIMAuthority(context.Background(), domain, true); err != nil {
+		t.Fatal(err)
+	}
+	if settings["dkim_selector"].Get("dkim_selector") != "s2" || settings["dkim_authority"].Get("self") != "true" {
+		t.Fatalf("Unexpected settings: %v", settings)
+	}
+	if err := mg.SetDKIMSelector(context.Background(), domain, ""); err != ErrEmptyParam {
+		t.Fatalf("Expected ErrEmptyParam; got %v", err)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		base := "/v3/" + domain + "/templates"
+		r.ParseForm()
+		switch {
+		case r.URL.Path == base && r.Method == "POST":
+			if r.PostForm.Get("template") != "Hi {{name}}" || r.PostForm.Get("tag") != "v1" || r.PostForm.Get("active") != "yes" {
+				t.Error("Unexpected template: ", r.PostForm)
+			}
+			fmt.Fprintf(w, `{"message": "template has been stored", "template": {"name": %q, "createdAt": "Wed, 29 Aug 2018 23:31:11 UTC",
+				"version": {"tag": "v1", "template": "Hi {{name}}", "engine": "handlebars", "active": true}}}`, r.PostForm.Get("name"))
+		case r.URL.Path == base && r.Method == "GET":
+			if r.URL.Query().Get("p") == "" {
+				next := srv.URL + base + "?p=next&limit=100"
+				fmt.Fprintf(w, `{"items": [{"name": "welcome"}, {"name": "receipt"}], "paging": {"next": %q}}`, next)
+			} else {
+				fmt.Fprint(w, `{"items": [], "paging": {"next": ""}}`)
+			}
+		case r.URL.Path == base+"/welcome" && r.Method == "GET":
+			if r.URL.Query().Get("active") != "yes" {
+				t.Error("Expected the active version to be asked for")
+			}
+			fmt.Fprint(w, `{"template": {"name": "welcome", "description": "Greeting", "version": {"tag": "v1", "template": "Hi {{name}}"}}}`)
+		case r.URL.Path == base+"/welcome" && r.Method == "PUT":
+			if r.PostForm.Get("description") != "New greeting" {
+				t.Error("Unexpected description: ", r.PostForm)
+			}
+			fmt.Fprint(w, `{"message": "template has been updated", "template": {"name": "welcome"}}`)
+		case r.URL.Path == base+"/welcome/versions" && r.Method == "POST":
+			fmt.Fprintf(w, `{"message": "new version of the template has been stored", "template": {"name": "welcome",
+				"version": {"tag": %q, "template": %q, "engine": "handlebars"}}}`, r.PostForm.Get("tag"), r.PostForm.Get("template"))
+		case r.URL.Path == base+"/welcome/versions" && r.Method == "GET":
+			fmt.Fprint(w, `{"template": {"name": "welcome", "versions": []}, "paging": {}}`)
+		case r.URL.Path == base+"/welcome/versions/v2" && r.Method == "GET":
+			fmt.Fprint(w, `{"template": {"name": "welcome", "version": {"tag": "v2", "template": "Hello {{name}}"}}}`)
+		case r.URL.Path == base+"/welcome/versions/v2" && r.Method == "PUT":
+			if r.PostForm.Get("active") != "yes" || r.PostForm.Get("template") != "" {
+				t.Error("Unexpected version update: ", r.PostForm)
+			}
+			fmt.Fprint(w, `{"message": "version has been updated", "template": {"name": "welcome", "version": {"tag": "v2"}}}`)
+		case strings.HasPrefix(r.URL.Path, base+"/welcome") && r.Method == "DELETE":
+			fmt.Fprint(w, `{"message": "deleted"}`)
+		default:
+			t.Error("Unexpected request: ", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	ctx := context.Background()
+
+	tmpl := &Template{Name: "welcome", Version: TemplateVersion{Tag: "v1", Template: "Hi {{name}}", Active: true}}
+	if err := mg.CreateTemplate(ctx, tmpl); err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.CreatedAt == "" || tmpl.Version.Engine != TemplateEngineHandlebars {
+		t.Fatalf("Expected the template to be updated from the response; got %#v", tmpl)
+	}
+	if err := mg.CreateTemplate(ctx, &Template{}); err != ErrEmptyParam {
+		t.Fatalf("Expected ErrEmptyParam; got %v", err)
+	}
+	tmpl, err := mg.GetTemplate(ctx, "welcome")
+	if err != nil || tmpl.Description != "Greeting" || tmpl.Version.Template != "Hi {{name}}" {
+		t.Fatalf("Unexpected template: %#v, %v", tmpl, err)
+	}
+	tmpl.Description = "New greeting"
+	if err := mg.UpdateTemplate(ctx, tmpl); err != nil {
+		t.Fatal(err)
+	}
+	templates, err := mg.ListTemplates(ctx)
+	if err != nil || len(templates) != 2 || templates[1].Name != "receipt" {
+		t.Fatalf("Unexpected templates: %v, %v", templates, err)
+	}
+
+	v := &TemplateVersion{Tag: "v2", Template: "Hello {{name}}"}
+	if err := mg.CreateTemplateVersion(ctx, "welcome", v); err != nil || v.Engine != TemplateEngineHandlebars {
+		t.Fatalf("Unexpected version: %#v, %v", v, err)
+	}
+	if v, err = mg.GetTemplateVersion(ctx, "welcome", "v2"); err != nil || v.Template != "Hello {{name}}" {
+		t.Fatalf("Unexpected version: %#v, %v", v, err)
+	}
+	if err := mg.UpdateTemplateVersion(ctx, "welcome", &TemplateVersion{Tag: "v2", Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	if versions, err := mg.ListTemplateVersions(ctx, "welcome"); err != nil || len(versions) != 0 {
+		t.Fatalf("Unexpected versions: %v, %v", versions, err)
+	}
+	if err := mg.DeleteTemplateVersion(ctx, "welcome", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mg.DeleteTemplate(ctx, "welcome"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMessageTemplate(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "", "you@example.com")
+	if err := validateMessage(m); err != ErrNoBody {
+		t.Fatalf("Expected ErrNoBody without a template; got %v", err)
+	}
+	m.SetTemplate("welcome")
+	m.SetTemplateVersion("v2")
+	m.AddVariable("name", "Joe")
+	if err := validateMessage(m); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := newSendPayload(m, m.to, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]string{}
+	for _, kv := range payload.Values {
+		found[kv.key] = kv.value
+	}
+	if found["template"] != "welcome" || found["t:version"] != "v2" {
+		t.Fatalf("Expected the template in the payload; got %v", found)
+	}
+	if _, ok := found["text"]; ok {
+		t.Fatal("Expected no empty text body to be sent along with the template")
+	}
+}
+
+func TestSendingIP(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	for _, ip := range []string{"192.0.2.1", "2001:db8::1"} {
+		m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+		m.SetSendingIP(ip)
+		if err := validateMessage(m); err != nil {
+			t.Fatalf("Expected %s to be accepted; got %v", ip, err)
+		}
+		payload, err := newSendPayload(m, m.to, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		for _, kv := range payload.Values {
+			if kv.key == "o:sending-ip" {
+				got = kv.value
+			}
+		}
+		if got != ip {
+			t.Fatalf("Expected o:sending-ip=%s; got %q", ip, got)
+		}
+	}
+
+	for _, ip := range []string{"192.0.2", "mail.example.com", "2001:db8::1::2"} {
+		m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+		m.SetSendingIP(ip)
+		if err := validateMessage(m); err != ErrInvalidSendingIP {
+			t.Fatalf("Expected ErrInvalidSendingIP for %s; got %v", ip, err)
+		}
+	}
+}
+
+func TestEnvelopeSender(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("John Doe <john@example.com>", "Subject", "Text", "you@example.com")
+	m.AddHeader("Sender", "other@example.com")
+	m.SetEnvelopeSender("bounces@example.com")
+	if err := validateMessage(m); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := newSendPayload(m, m.to, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var senders []string
+	var from string
+	for _, kv := range payload.Values {
+		switch kv.key {
+		case "h:Sender":
+			senders = append(senders, kv.value)
+		case "from":
+			from = kv.value
+		}
+	}
+	if len(senders) != 1 || senders[0] != "bounces@example.com" {
+		t.Fatalf("Expected h:Sender=bounces@example.com alone; got %q", senders)
+	}
+	if from != "John Doe <john@example.com>" {
+		t.Fatalf("Expected the From: address to be left alone; got %q", from)
+	}
+
+	m.SetEnvelopeSender("bounces")
+	if err := validateMessage(m); err != ErrInvalidEnvelopeSender {
+		t.Fatalf("Expected ErrInvalidEnvelopeSender; got %v", err)
+	}
+}
+
+func TestBatchSend(t *testing.T) {
+	var chunks [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Error(err)
+		}
+		to := r.MultipartForm.Value["to"]
+		chunks = append(chunks, to)
+		if !strings.Contains(r.MultipartForm.Value["recipient-variables"][0], to[0]) {
+			t.Errorf("Expected the recipient variables of %s", to[0])
+		}
+		if len(r.MultipartForm.File["attachment"]) != 1 {
+			t.Error("Expected every chunk to carry the attachment")
+		}
+		if to[0] == "c@example.com" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"message": "Rejected"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"id": "<%s>", "message": "Queued. Thank you."}`, to[0])
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	m := mg.NewMessage("me@example.com", "Subject", "Hello %recipient.name%")
+	for _, to := range []string{"a", "b", "c", "d", "e"} {
+		if err := m.AddRecipientAndVariables(to+"@example.com", map[string]interface{}{"name": to}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.AddReaderAttachment("note.txt", strings.NewReader("attached"))
+	results, err := mg.BatchSend(m, 2)
+	if err == nil {
+		t.Fatal("Expected the failed chunk to be reported")
+	}
+	if len(results) != 3 || len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks to be sent; got %d results for %d calls", len(results), len(chunks))
+	}
+	for i, want := range []string{"<a@example.com>", "", "<e@example.com>"} {
+		if r := results[i]; r.Chunk != i || r.MessageID != want || (r.Error != nil) != (want == "") {
+			t.Errorf("Unexpected result for chunk %d: %+v", i, r)
+		}
+	}
+	if len(chunks[0]) != 2 || len(chunks[2]) != 1 {
+		t.Fatalf("Expected chunks of 2, 2, and 1 recipients; got %q", chunks)
+	}
+	if len(m.to) != 5 {
+		t.Fatal("Expected the original message to keep all its recipients; got ", len(m.to))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := mg.BatchSendContext(ctx, m, 2); err != context.Canceled {
+		t.Fatalf("Expected the batch to stop with the context; got %v", err)
+	}
+}
+
+func TestListUnsubscribe(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	if err := m.SetListUnsubscribe("unsubscribe@example.com", "https://example.com/unsubscribe?u=1"); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := newSendPayload(m, m.to, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := make(map[string]string)
+	for _, kv := range payload.Values {
+		if strings.HasPrefix(kv.key, "h:") {
+			headers[kv.key] = kv.value
+		}
+	}
+	if got := headers["h:List-Unsubscribe"]; got != "<mailto:unsubscribe@example.com>, <https://example.com/unsubscribe?u=1>" {
+		t.Fatalf("Unexpected List-Unsubscribe: %q", got)
+	}
+	if got := headers["h:List-Unsubscribe-Post"]; got != "List-Unsubscribe=One-Click" {
+		t.Fatalf("Unexpected List-Unsubscribe-Post: %q", got)
+	}
+
+	if err := m.SetListUnsubscribe("mailto:unsubscribe@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.headers["List-Unsubscribe"]; got != "<mailto:unsubscribe@example.com>" {
+		t.Fatalf("Unexpected List-Unsubscribe: %q", got)
+	}
+	if _, ok := m.headers["List-Unsubscribe-Post"]; ok {
+		t.Fatal("Expected List-Unsubscribe-Post to go without an HTTP URL")
+	}
+
+	for _, u := range []string{"", "http://example.com/unsubscribe"} {
+		if err := m.SetListUnsubscribe("", u); err != ErrInvalidListUnsubscribe {
+			t.Fatalf("Expected ErrInvalidListUnsubscribe for %q; got %v", u, err)
+		}
+	}
+	if got := m.headers["List-Unsubscribe"]; got != "<mailto:unsubscribe@example.com>" {
+		t.Fatalf("Expected a refused link to leave the message unchanged; got %q", got)
+	}
+}
+
+func TestNewMessageWithOptions(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	dt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	m := mg.NewMessageWithOptions("me@example.com", "Subject", "Text", []string{"you@example.com"},
+		WithCC("cc@example.com"),
+		WithBCC("bcc@example.com"),
+		WithHtml("<p>Text</p>"),
+		WithTag("a", "b"),
+		WithHeader("X-Custom", "yes"),
+		WithVariable("user", 42),
+		WithDeliveryTime(dt),
+		WithTracking(false),
+	)
+	if err := validateMessage(m); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := newSendPayload(m, m.to, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]string)
+	for _, kv := range payload.Values {
+		got[kv.key] = append(got[kv.key], kv.value)
+	}
+	expected := map[string]string{
+		"to":             "you@example.com",
+		"cc":             "cc@example.com",
+		"bcc":            "bcc@example.com",
+		"html":           "<p>Text</p>",
+		"o:tag":          "a,b",
+		"h:X-Custom":     "yes",
+		"v:user":         "42",
+		"o:deliverytime": formatMailgunTime(&dt),
+		"o:tracking":     "no",
+	}
+	for key, want := range expected {
+		if v := strings.Join(got[key], ","); v != want {
+			t.Errorf("Expected %s=%q; got %q", key, want, v)
+		}
+	}
+
+	var tags []MessageOption
+	for i := 0; i <= MaxNumberOfTags; i++ {
+		tags = append(tags, WithTag(strconv.Itoa(i)))
+	}
+	m = mg.NewMessageWithOptions("me@example.com", "Subject", "Text", []string{"you@example.com"}, tags...)
+	if err := validateMessage(m); err != ErrTooManyTags {
+		t.Fatalf("Expected the failed option to stop the message; got %v", err)
+	}
+}
+
+func TestParseInboundMessage(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	newRequest := func(signature string) *http.Request {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		fields := map[string]string{
+			"sender":          "bounce@example.com",
+			"recipient":       "support@" + domain,
+			"from":            "Jane Doe <jane@example.com>",
+			"subject":         "Help",
+			"body-plain":      "Please help.",
+			"body-html":       "<p>Please help.</p>",
+			"message-headers": `[["Subject", "Help"], ["X-Mailer", "test"]]`,
+			"timestamp":       "1546300800",
+			"token":           "token",
+			"signature":       signature,
+		}
+		for k, v := range fields {
+			w.WriteField(k, v)
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="attachment-1"; filename="note.txt"`)
+		h.Set("Content-Type", "text/plain")
+		part, _ := w.CreatePart(h)
+		io.WriteString(part, "attached")
+		w.Close()
+		r := httptest.NewRequest("POST", "/inbound", &body)
+		r.Header.Set("Content-Type", w.FormDataContentType())
+		return r
+	}
+
+	mac := hmac.New(sha256.New, []byte(apiKey))
+	mac.Write([]byte("1546300800token"))
+	m, err := mg.ParseInboundMessage(newRequest(hex.EncodeToString(mac.Sum(nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Sender != "bounce@example.com" || m.Recipient != "support@"+domain || m.Subject != "Help" ||
+		m.BodyPlain != "Please help." || m.BodyHtml != "<p>Please help.</p>" || m.Token != "token" {
+		t.Fatalf("Unexpected message: %#v", m)
+	}
+	if !m.Timestamp.Equal(time.Unix(1546300800, 0)) {
+		t.Fatal("Unexpected timestamp: ", m.Timestamp)
+	}
+	if len(m.MessageHeaders) != 2 || m.MessageHeaders[1][1] != "test" {
+		t.Fatalf("Unexpected headers: %q", m.MessageHeaders)
+	}
+	if len(m.Attachments) != 1 || m.Attachments[0].Name != "note.txt" || m.Attachments[0].ContentType != "text/plain" {
+		t.Fatalf("Unexpected attachments: %#v", m.Attachments)
+	}
+	rc := m.Attachments[0].Open()
+	data, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(data) != "attached" {
+		t.Fatalf("Unexpected attachment content %q: %v", data, err)
+	}
+
+	if _, err := mg.ParseInboundMessage(newRequest("forged")); err != ErrInvalidWebhookSignature {
+		t.Fatalf("Expected ErrInvalidWebhookSignature; got %v", err)
+	}
+}
+
+func TestAddVariableJSON(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	if err := m.AddVariableJSON("order", `{"id": 42, "items": ["a", "b"]}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddVariable("note", `{"id": 42}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddVariableJSON("broken", `{"id": `); err != ErrInvalidVariableJSON {
+		t.Fatalf("Expected ErrInvalidVariableJSON; got %v", err)
+	}
+	if _, ok := m.variables["broken"]; ok {
+		t.Fatal("Expected the invalid variable to be left out")
+	}
+	if got := m.variables["order"]; got != `{"id": 42, "items": ["a", "b"]}` {
+		t.Fatalf("Expected the JSON to be kept as is; got %s", got)
+	}
+	if got := m.variables["note"]; got != `"{\"id\": 42}"` {
+		t.Fatalf("Expected AddVariable to encode the string; got %s", got)
+	}
+}
+
+func TestAttachmentFromBytes(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	m.AddAttachmentFromBytes("report.csv", "text/csv", []byte("a,b\n1,2\n"))
+	m.AddInlineFromBytes("logo.png", "", pngFixture)
+
+	payload, err := newSendPayload(m, m.to, nil, m.readerAttachments, m.readerInlines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := payload.getPayloadBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(payload.getContentType())
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]string{}
+	mr := multipart.NewReader(buf, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.FileName() != "" {
+			types[part.FormName()+"/"+part.FileName()] = part.Header.Get("Content-Type")
+		}
+	}
+	if types["attachment/report.csv"] != "text/csv" || types["inline/logo.png"] != "application/octet-stream" {
+		t.Fatalf("Unexpected file parts: %v", types)
+	}
+}
+
+func TestAddCampaignLimit(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	for _, c := range []string{"a", "b", "a", "c"} {
+		if err := m.AddCampaign(c); err != nil {
+			t.Fatalf("Expected campaign %s to be accepted; got %v", c, err)
+		}
+	}
+	if err := m.AddCampaign("d"); err != ErrTooManyCampaigns {
+		t.Fatalf("Expected ErrTooManyCampaigns; got %v", err)
+	}
+	if err := m.AddCampaign("b"); err != nil {
+		t.Fatalf("Expected a campaign already added to be accepted; got %v", err)
+	}
+	if strings.Join(m.campaigns, ",") != "a,b,c" {
+		t.Fatalf("Unexpected campaigns: %q", m.campaigns)
+	}
+}
+
+func TestMessageAccessors(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewHTMLMessage("me@example.com", "Your order is ready", "<p>Ready</p>", "Ready", "you@example.com")
+	c := m.Clone()
+	c.SetSubject("Hi Jane, your order is ready")
+	if m.GetSubject() != "Your order is ready" || c.GetSubject() != "Hi Jane, your order is ready" {
+		t.Fatalf("Unexpected subjects %q and %q", m.GetSubject(), c.GetSubject())
+	}
+	if c.GetFrom() != "me@example.com" || c.GetText() != "Ready" || c.GetHtml() != "<p>Ready</p>" {
+		t.Fatalf("Unexpected message: from %q, text %q, html %q", c.GetFrom(), c.GetText(), c.GetHtml())
+	}
+
+	mm := mg.NewMIMEMessage(ioutil.NopCloser(strings.NewReader("Subject: Hi\r\n\r\nHello\r\n")), "you@example.com")
+	mm.SetSubject("Ignored")
+	if mm.GetSubject() != "" || mm.GetFrom() != "" || mm.GetText() != "" || mm.GetHtml() != "" {
+		t.Fatal("Expected MIME messages to report blanks")
+	}
+}
+
+func TestInvalidVariableJSON(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	if err := m.AddVariableJSON("ok", `{"id": 1}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateMessage(m); err != nil {
+		t.Fatal(err)
+	}
+	m.variables["foo"] = `{"id": `
+	m.variables["zoo"] = `nope`
+	err := validateMessage(m)
+	invalid, ok := err.(*InvalidVariableJSONError)
+	if !ok || invalid.Variable != "foo" || !errors.Is(err, ErrInvalidVariableJSON) {
+		t.Fatalf("Expected variable foo to be refused; got %v", err)
+	}
+}
+
+func TestMessageBuilder(t *testing.T) {
+	m, err := NewMessageBuilder("me@example.com", "Hello").
+		To("you@example.com").
+		CC("cc@example.com").
+		Text("Hello world!").
+		HTML("<p>Hello world!</p>").
+		Tag("welcome").
+		Header("X-Campaign", "spring").
+		Variable("user", 42).
+		Attachment("report.csv", "text/csv", []byte("a,b")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, html := m.GetContent(); text != "Hello world!" || html != "<p>Hello world!</p>" {
+		t.Fatalf("Unexpected content %q and %q", text, html)
+	}
+	if len(m.to) != 1 || m.tags[0] != "welcome" || m.headers["X-Campaign"] != "spring" || m.variables["user"] != "42" ||
+		len(m.readerAttachments) != 1 {
+		t.Fatalf("Unexpected message: %#v", m)
+	}
+
+	m, err = NewMessageBuilder("", "Hello").
+		To("").
+		Tag("a", "b", "c", "d").
+		Variable("bad", make(chan int)).
+		Build()
+	if m != nil || err == nil {
+		t.Fatal("Expected the broken message to be refused")
+	}
+	errs, ok := err.(multiError)
+	if !ok || len(errs) != 4 {
+		t.Fatalf("Expected four problems; got %v", err)
+	}
+	for i, want := range []error{ErrNoSender, ErrEmptyRecipient, ErrTooManyTags} {
+		if errs[i] != want {
+			t.Errorf("Expected problem %d to be %v; got %v", i, want, errs[i])
+		}
+	}
+	if !strings.Contains(errs[3].Error(), "bad") {
+		t.Errorf("Unexpected problems: %v", err)
+	}
+
+	_, err = NewMessageBuilder("me@example.com", "Hello").Text("Hello").Build()
+	if err != ErrNoRecipients {
+		t.Fatalf("Expected Build to check the message as Send does; got %v", err)
+	}
+}
+
+func TestCustomMessageID(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("me@example.com", "Subject", "Text", "you@example.com")
+	m.AddHeader("Message-ID", "<other@example.com>")
+	m.SetCustomMessageID("<order-42@example.com>")
+	m.AutoSetMessageID(domain)
+	if err := validateMessage(m); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := newSendPayload(m, m.to, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, kv := range payload.Values {
+		if strings.EqualFold(kv.key, "h:Message-Id") {
+			ids = append(ids, kv.value)
+		}
+	}
+	if len(ids) != 1 || ids[0] != "<order-42@example.com>" {
+		t.Fatalf("Expected h:Message-Id=<order-42@example.com> alone; got %q", ids)
+	}
+
+	for _, id := range []string{"order-42@example.com", "<order-42>", "<@example.com>", "<order@>", "<a b@example.com>"} {
+		m.SetCustomMessageID(id)
+		if err := validateMessage(m); err != ErrInvalidMessageID {
+			t.Errorf("Expected ErrInvalidMessageID for %q; got %v", id, err)
+		}
+	}
+	m.SetCustomMessageID(GenerateMessageID(domain))
+	if err := validateMessage(m); err != nil {
+		t.Fatalf("Expected a generated ID to be accepted; got %v", err)
+	}
+}
+
+func TestListIterators(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3/"+domain+"/complaints" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var items, page string
+		switch r.URL.Query().Get("page") {
+		case "":
+			if r.URL.Query().Get("limit") != "2" {
+				t.Error("Expected the page size to be sent")
+			}
+			items, page = `{"address": "a@example.com"}, {"address": "b@example.com"}`, "2"
+		case "2":
+			items, page = `{"address": "c@example.com"}`, "3"
+		}
+		next := fmt.Sprintf("%s%s?page=%s", srv.URL, r.URL.Path, page)
+		fmt.Fprintf(w, `{"items": [%s], "paging": {"next": %q}}`, items, next)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	ctx := context.Background()
+	opts := ListOptions{Limit: 2}
+
+	var addresses []string
+	unsubscribes := mg.ListUnsubscribes(domain, opts)
+	for unsubscribes.Next(ctx) {
+		addresses = append(addresses, unsubscribes.Item().Address)
+	}
+	bounces := mg.ListBounces(domain, opts)
+	for bounces.Next(ctx) {
+		addresses = append(addresses, bounces.Item().Address)
+	}
+	members := mg.ListMembers("list@example.com", opts)
+	for members.Next(ctx) {
+		addresses = append(addresses, members.Item().Address)
+	}
+	if unsubscribes.Err() != nil || bounces.Err() != nil || members.Err() != nil {
+		t.Fatal(unsubscribes.Err(), bounces.Err(), members.Err())
+	}
+	walk := []string{"a@example.com", "b@example.com", "c@example.com"}
+	if got, want := strings.Join(addresses, ","), strings.Join(append(append(walk, walk...), walk...), ","); got != want {
+		t.Fatal("Unexpected walk: ", got)
+	}
+	if unsubscribes.Next(ctx) {
+		t.Fatal("Expected the iterator to stay exhausted")
+	}
+
+	complaints := mg.ListComplaints(domain, opts)
+	if complaints.Next(ctx) {
+		t.Fatal("Expected a failed fetch to stop the iterator")
+	}
+	if apiErr, ok := IsAPIError(complaints.Err()); !ok || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatal("Expected Err to report the API error; got ", complaints.Err())
+	}
+}
+
+func TestGetMessageEvents(t *testing.T) {
+	var calls int
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "" {
+			fmt.Fprintf(w, `{"items": [], "paging": {"next": ""}}`)
+			return
+		}
+		calls++
+		if got := r.URL.Query().Get("message-id"); got != "abc@example.com" {
+			t.Error("Expected the message-id filter, without brackets; got ", got)
+		}
+		items := `[]`
+		switch r.URL.Query().Get("event") {
+		case "accepted OR delivered":
+			items = `[{"event": "delivered", "timestamp": 1500000002}, {"event": "accepted", "timestamp": 1500000001}]`
+		case "clicked":
+		case "opened":
+			if calls > 2 {
+				items = `[{"event": "opened", "timestamp": 1500000003}]`
+			}
+		default:
+			t.Error("Unexpected event filter: ", r.URL.RawQuery)
+		}
+		fmt.Fprintf(w, `{"items": %s, "paging": {"next": "%s/v3/%s/events?page=2"}}`, items, srv.URL, domain)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	ctx := context.Background()
+	events, err := mg.GetMessageEvents(ctx, domain, "<abc@example.com>", MessageEventOptions{EventTypes: []string{"accepted", "delivered"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Name() != "accepted" || events[1].Name() != "delivered" {
+		t.Fatalf("Expected accepted then delivered; got %v", events)
+	}
+	if _, err := mg.GetMessageEvents(ctx, domain, "", MessageEventOptions{}); err != ErrEmptyParam {
+		t.Fatal("Expected a missing message ID to be refused; got ", err)
+	}
+
+	e, err := mg.PollMessageEvent(ctx, domain, "abc@example.com", "opened", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Name() != "opened" || calls != 3 {
+		t.Fatalf("Expected the opened event on the second poll; got %v after %d calls", e, calls)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := mg.PollMessageEvent(ctx, domain, "abc@example.com", "clicked", time.Millisecond); err == nil {
+		t.Fatal("Expected polling to stop once the context ended")
+	}
+}
+
+func TestTags(t *testing.T) {
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v3/"+domain+"/tags":
+			if r.URL.Query().Get("limit") != "10" {
+				t.Error("Expected a limit of 10; got ", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"items": [{"tag": "welcome", "description": "", "first-seen": "2018-01-02T03:04:05Z", "last-seen": "2018-06-02T03:04:05Z"},
+				{"tag": "spring sale", "description": "Old campaign"}], "paging": {}}`)
+		case r.Method == "GET" && r.URL.EscapedPath() == "/v3/"+domain+"/tags/spring%20sale":
+			fmt.Fprint(w, `{"tag": "spring sale", "description": "Old campaign", "first-seen": "2017-03-01T00:00:00Z", "last-seen": "2017-04-01T00:00:00Z"}`)
+		case r.Method == "DELETE":
+			deleted = append(deleted, r.URL.Path)
+			fmt.Fprint(w, `{"message": "Tag deleted"}`)
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	tags, err := mg.GetTags(context.Background(), domain, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0].Tag != "welcome" || tags[0].LastSeen != "2018-06-02T03:04:05Z" || tags[1].Description != "Old campaign" {
+		t.Fatalf("Unexpected tags: %#v", tags)
+	}
+
+	tag, err := mg.GetTag(context.Background(), domain, "spring sale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Tag != "spring sale" || tag.FirstSeen != "2017-03-01T00:00:00Z" {
+		t.Fatalf("Unexpected tag: %#v", tag)
+	}
+	if _, err := mg.GetTag(context.Background(), domain, ""); err != ErrEmptyParam {
+		t.Fatal("Expected a blank tag to be refused; got ", err)
+	}
+
+	if err := mg.DeleteDomainTag(context.Background(), domain, "spring sale"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mg.DeleteTag("welcome"); err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 || deleted[0] != "/v3/"+domain+"/tags/spring sale" || deleted[1] != "/v3/"+domain+"/tags/welcome" {
+		t.Fatal("Unexpected deletions: ", deleted)
+	}
+}
+
+func TestGetDomainStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/"+domain+"/stats/total" {
+			t.Error("Unexpected path: ", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"stats": [
+			{"time": "Mon, 02 Oct 2017 00:00:00 UTC", "accepted": {"incoming": 2, "outgoing": 10, "total": 12},
+				"delivered": {"smtp": 7, "http": 2, "total": 9}, "opened": {"total": 4},
+				"failed": {"temporary": {"espblock": 1}, "permanent": {"bounce": 1, "total": 1}}},
+			{"time": "Tue, 03 Oct 2017 00:00:00 UTC", "accepted": {"outgoing": 5, "total": 5},
+				"delivered": {"smtp": 5, "total": 5}, "clicked": {"total": 2}, "complained": {"total": 1},
+				"failed": {"permanent": {"suppress-bounce": 2}}}]}`)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+
+	stats, err := mg.GetDomainStats(context.Background(), domain, StatsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sent.Total != 15 || stats.Accepted.Total != 17 || stats.Delivered != (StatCounter{Total: 14, ESP: 12, Webhook: 2}) {
+		t.Fatalf("Unexpected sending counts: %#v", stats)
+	}
+	if stats.Failed != (StatCounter{Total: 4, Temporary: 1, Permanent: 3}) {
+		t.Fatalf("Unexpected failures: %#v", stats.Failed)
+	}
+	if stats.Opened.Total != 4 || stats.Clicked.Total != 2 || stats.Complained.Total != 1 || stats.Stored.Total != 0 {
+		t.Fatalf("Unexpected engagement counts: %#v", stats)
+	}
+}
+
+func TestLint(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("joe@example.com", "Hello", "", "alice@example.com")
+	m.SetTemplate("welcome")
+	m.SetHtml("<p>Hello</p>")
+	if w := m.Lint(); len(w) != 1 || !strings.Contains(w[0], "plain-text") {
+		t.Fatal("Expected a warning about the missing plain-text body; got ", w)
+	}
+
+	if err := m.SetContent("Hello", "<p>Hello</p>"); err != nil {
+		t.Fatal(err)
+	}
+	if w := m.Lint(); w != nil {
+		t.Fatal("Expected no warnings; got ", w)
+	}
+
+	m.SetSubject(" ")
+	if w := m.Lint(); len(w) != 1 || w[0] != "message has no subject" {
+		t.Fatal("Expected a warning about the missing subject; got ", w)
+	}
+
+	mime := mg.NewMIMEMessage(ioutil.NopCloser(strings.NewReader("Subject: hi\r\n\r\nhi")), "alice@example.com")
+	if w := mime.Lint(); w != nil {
+		t.Fatal("Expected MIME messages to pass; got ", w)
+	}
+}
+
+func TestNewMailgunFromEnv(t *testing.T) {
+	vars := []string{"MAILGUN_DOMAIN", "MAILGUN_API_KEY", "MAILGUN_PUBLIC_API_KEY", "MAILGUN_REGION", "MAILGUN_BASE_URL"}
+	for _, name := range vars {
+		old, ok := os.LookupEnv(name)
+		os.Unsetenv(name)
+		if ok {
+			defer os.Setenv(name, old)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+
+	if _, err := NewMailgunFromEnv(); err == nil || !strings.Contains(err.Error(), "MAILGUN_DOMAIN, MAILGUN_API_KEY") {
+		t.Fatal("Expected both missing variables to be named; got ", err)
+	}
+
+	os.Setenv("MAILGUN_DOMAIN", domain)
+	os.Setenv("MAILGUN_API_KEY", apiKey)
+	os.Setenv("MAILGUN_REGION", "EU")
+	mg, err := NewMailgunFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mg.Domain() != domain || mg.ApiKey() != apiKey || mg.ApiBase() != apiBaseEU {
+		t.Fatalf("Unexpected configuration: %s %s %s", mg.Domain(), mg.ApiKey(), mg.ApiBase())
+	}
+
+	os.Setenv("MAILGUN_BASE_URL", "http://127.0.0.1:8080/v3/")
+	if mg, err = NewMailgunFromEnv(); err != nil || mg.ApiBase() != "http://127.0.0.1:8080/v3" {
+		t.Fatal("Expected the base URL to take precedence over the region; got ", err)
+	}
+
+	os.Setenv("MAILGUN_REGION", "mars")
+	if _, err := NewMailgunFromEnv(); err == nil || !strings.Contains(err.Error(), "mars") {
+		t.Fatal("Expected an unknown region to be refused; got ", err)
+	}
+}
+
+func TestTrackingPixelOnly(t *testing.T) {
+	trackingFields := func(m *Message) map[string]string {
+		payload, err := newSendPayload(m, m.to, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields := map[string]string{}
+		for _, kv := range payload.Values {
+			if strings.HasPrefix(kv.key, "o:tracking") {
+				fields[kv.key] = kv.value
+			}
+		}
+		return fields
+	}
+
+	m := NewMessage("joe@example.com", "Hello", "Hello", "alice@example.com")
+	m.SetTrackingPixelOnly()
+	want := map[string]string{"o:tracking-opens": "yes", "o:tracking-clicks": "no"}
+	if got := trackingFields(m); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Expected %v alone; got %v", want, got)
+	}
+
+	m = NewMessage("joe@example.com", "Hello", "Hello", "alice@example.com")
+	m.SetTrackingOpens(false)
+	m.SetTrackingClicksMode(TrackingClicksHTMLOnly)
+	want = map[string]string{"o:tracking-opens": "no", "o:tracking-clicks": "htmlonly"}
+	if got := trackingFields(m); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Expected %v alone; got %v", want, got)
+	}
+}
+
+func TestListRoutes(t *testing.T) {
+	routes := []string{"a", "b", "c", "d", "e"}
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+		if r.URL.Query().Get("limit") != "2" {
+			t.Error("Expected pages of two routes; got ", r.URL.RawQuery)
+		}
+		var items []string
+		for i := skip; i < skip+2 && i < len(routes); i++ {
+			items = append(items, fmt.Sprintf(`{"id": %q, "expression": "catch_all()"}`, routes[i]))
+		}
+		fmt.Fprintf(w, `{"total_count": %d, "items": [%s]}`, len(routes), strings.Join(items, ","))
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	var got []string
+	it := mg.ListRoutes(ListOptions{Limit: 2})
+	for it.Next(context.Background()) {
+		got = append(got, it.Item().ID)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, "") != "abcde" || calls != 3 {
+		t.Fatalf("Expected every route in three calls; got %q in %d", got, calls)
+	}
+}
+
+func TestRouteExpression(t *testing.T) {
+	msg := routeMessage{
+		recipient: "support@example.com",
+		headers:   map[string]string{"from": "Bob <bob@example.org>", "subject": "Help needed", "to": "support@example.com"},
+	}
+	tests := []struct {
+		expression string
+		match      bool
+	}{
+		{`catch_all()`, true},
+		{`match_recipient("support@example.com")`, true},
+		{`match_recipient(".*@example.com")`, true},
+		{`match_recipient("sales@example.com")`, false},
+		{`match_recipient("example.com")`, false},
+		{`match_header("Subject", "Help")`, true},
+		{`match_header("subject", ".*needed")`, true},
+		{`match_header("X-Priority", ".*")`, false},
+		{` match_recipient(".*@example\.com")  and match_header("from", ".*bob@")`, true},
+		{`match_recipient(".*@example\.com") and match_header("from", "alice")`, false},
+		{`match_header("subject", "say \"hi\"")`, false},
+	}
+	for _, test := range tests {
+		match, err := parseRouteExpression(test.expression)
+		if err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		if got := match(msg); got != test.match {
+			t.Errorf("%s: expected %v; got %v", test.expression, test.match, got)
+		}
+	}
+
+	for _, expression := range []string{``, `catch_all`, `match_recipient(support)`, `match_body("x")`,
+		`catch_all() or catch_all()`, `match_recipient("(")`, `match_header("subject")`, `match_recipient("x`} {
+		if _, err := parseRouteExpression(expression); err == nil {
+			t.Errorf("Expected %q to be refused", expression)
+		}
+	}
+}
+
+func TestTestRoute(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("skip") != "0" {
+			fmt.Fprint(w, `{"total_count": 4, "items": []}`)
+			return
+		}
+		fmt.Fprint(w, `{"total_count": 4, "items": [
+			{"id": "3", "priority": 10, "expression": "catch_all()", "actions": ["store()"]},
+			{"id": "4", "priority": 1, "expression": "match_recipient(\"(?!noreply).*@example.com\")", "actions": ["stop()"]},
+			{"id": "1", "priority": 0, "expression": "match_recipient(\"support@.*\")", "actions": ["forward(\"https://example.com/support\")"]},
+			{"id": "2", "priority": 5, "expression": "match_header(\"subject\", \"urgent\")", "actions": ["forward(\"ops@example.com\")", "stop()"]}]}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(domain, apiKey, "").(*MailgunImpl)
+	mg.apiBase = srv.URL + "/v3"
+	result, err := mg.TestRoute(context.Background(), `match_recipient(".*@example.com")`, "support@example.com", "bob@example.org", "urgent: site down", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `forward("https://example.com/support") forward("ops@example.com") stop()`
+	if !result.Matched || strings.Join(result.Actions, " ") != want {
+		t.Fatalf("Unexpected result: %#v", result)
+	}
+	if len(result.SkippedRoutes) != 1 || !strings.HasPrefix(result.SkippedRoutes[0], "route 4: ") {
+		t.Fatalf("Expected the route with a lookahead to be skipped; got %q", result.SkippedRoutes)
+	}
+
+	result, err = mg.TestRoute(context.Background(), `match_recipient("sales@.*")`, "info@example.com", "bob@example.org", "Hello", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Matched || strings.Join(result.Actions, " ") != "store()" {
+		t.Fatalf("Unexpected result: %#v", result)
+	}
+
+	if _, err := mg.TestRoute(context.Background(), `match_recipient(`, "info@example.com", "", "", ""); err == nil {
+		t.Fatal("Expected a malformed expression to be refused")
+	}
+}
+
+func TestSubaccounts(t *testing.T) {
+	var onBehalfOf []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		onBehalfOf = append(onBehalfOf, r.Header.Get("X-Mailgun-On-Behalf-Of"))
+		account := `{"id": "sub1", "name": "Acme", "status": "open", "created_at": "Mon, 02 Oct 2017 10:00:00 UTC"}`
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v5/accounts/subaccounts":
+			if r.URL.Query().Get("limit") != "10" || r.URL.Query().Get("skip") != "" {
+				t.Error("Unexpected paging: ", r.URL.RawQuery)
+			}
+			fmt.Fprintf(w, `{"subaccounts": [%s], "total": 1}`, account)
+		case "POST /v5/accounts/subaccounts":
+			r.ParseForm()
+			if r.PostForm.Get("name") != "Acme" {
+				t.Error("Expected the name; got ", r.PostForm)
+			}
+			fmt.Fprintf(w, `{"subaccount": %s}`, account)
+		case "GET /v5/accounts/subaccounts/sub1", "POST /v5/accounts/subaccounts/sub1/enable":
+			fmt.Fprintf(w, `{"subaccount": %s}`, account)
+		case "POST /v5/accounts/subaccounts/sub1/disable":
+			fmt.Fprint(w, `{"subaccount": {"id": "sub1", "name": "Acme", "status": "disabled"}}`)
+		case "GET /v3/" + domain + "/tags":
+			fmt.Fprint(w, `{"items": []}`)
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"))
+	accounts, err := mg.GetSubaccounts(ctx, 10, DefaultSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0] != (Subaccount{ID: "sub1", Name: "Acme", Status: "open", CreatedAt: "Mon, 02 Oct 2017 10:00:00 UTC"}) {
+		t.Fatalf("Unexpected subaccounts: %#v", accounts)
+	}
+	for _, f := range []func() (*Subaccount, error){
+		func() (*Subaccount, error) { return mg.GetSubaccount(ctx, "sub1") },
+		func() (*Subaccount, error) { return mg.CreateSubaccount(ctx, "Acme") },
+		func() (*Subaccount, error) { return mg.EnableSubaccount(ctx, "sub1") },
+	} {
+		if a, err := f(); err != nil || a.ID != "sub1" || a.Status != "open" {
+			t.Fatalf("Unexpected subaccount: %#v, %v", a, err)
+		}
+	}
+	if a, err := mg.DisableSubaccount(ctx, "sub1"); err != nil || a.Status != "disabled" {
+		t.Fatalf("Unexpected subaccount: %#v, %v", a, err)
+	}
+	if _, err := mg.GetSubaccount(ctx, ""); err != ErrEmptyParam {
+		t.Fatal("Expected a blank ID to be refused; got ", err)
+	}
+
+	sub := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"), WithSubaccount("sub1"))
+	if _, err := sub.GetTags(context.Background(), domain, DefaultLimit); err != nil {
+		t.Fatal(err)
+	}
+	if last := onBehalfOf[len(onBehalfOf)-1]; last != "sub1" {
+		t.Fatal("Expected the call to be on behalf of the subaccount; got ", last)
+	}
+	for _, h := range onBehalfOf[:len(onBehalfOf)-1] {
+		if h != "" {
+			t.Fatal("Expected the primary account's calls to be its own; got ", h)
+		}
+	}
+}
+
+func TestSendWithTemplate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := map[string]string{
+			"template":  "welcome",
+			"t:version": "v2",
+			"v:name":    `"Alice"`,
+			"v:plan":    `{"tier":"pro"}`,
+			"v:id":      "7",
+		}
+		for key, value := range want {
+			if got := r.FormValue(key); got != value {
+				t.Errorf("Expected %s=%s; got %q", key, value, got)
+			}
+		}
+		fmt.Fprint(w, `{"message": "Queued. Thank you.", "id": "<1@example.com>"}`)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"))
+	ctx := context.Background()
+
+	m := mg.NewMessage("me@example.com", "Welcome", "", "alice@example.com")
+	if err := m.AddVariable("id", 7); err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]interface{}{"name": "Alice", "plan": map[string]string{"tier": "pro"}}
+	_, id, err := mg.SendWithTemplate(ctx, m, "welcome", "v2", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "<1@example.com>" {
+		t.Fatal("Unexpected message ID: ", id)
+	}
+
+	if _, _, err := mg.SendWithTemplate(ctx, m, "", "", nil); err != ErrEmptyParam {
+		t.Fatal("Expected a blank template name to be refused; got ", err)
+	}
+	if _, _, err := mg.SendWithTemplate(ctx, m, "welcome", "", map[string]interface{}{"bad": func() {}}); err == nil {
+		t.Fatal("Expected an unmarshalable variable to be refused")
+	}
+	mime := mg.NewMIMEMessage(ioutil.NopCloser(strings.NewReader("Subject: hi\r\n\r\nhi")), "alice@example.com")
+	if _, _, err := mg.SendWithTemplate(ctx, mime, "welcome", "", nil); err == nil {
+		t.Fatal("Expected a MIME message to be refused")
+	}
+}
+
+func TestGetAllEvents(t *testing.T) {
+	begin := time.Unix(1500000000, 0)
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "" {
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+			return
+		}
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		layout := "Mon, 2 Jan 2006 15:04:05 -0700"
+		from, err1 := time.Parse(layout, r.URL.Query().Get("begin"))
+		to, err2 := time.Parse(layout, r.URL.Query().Get("end"))
+		if err1 != nil || err2 != nil || r.URL.Query().Get("event") != "delivered" {
+			t.Error("Unexpected query: ", r.URL.RawQuery)
+		}
+		// An event every ten seconds, including those at the end of the period, as Mailgun does.
+		var items []string
+		for ts := from.Unix(); ts <= to.Unix(); ts++ {
+			if ts%10 == 0 {
+				items = append(items, fmt.Sprintf(`{"event": "delivered", "timestamp": %d}`, ts))
+			}
+		}
+		fmt.Fprintf(w, `{"items": [%s], "paging": {"next": "%s/v3/%s/events?page=2"}}`, strings.Join(items, ","), srv.URL, domain)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"))
+
+	opts := EventsOptions{Begin: begin, End: begin.Add(100 * time.Second), Event: "delivered", Concurrency: 4}
+	events, errs := mg.GetAllEvents(context.Background(), domain, opts)
+	var got []int64
+	for e := range events {
+		got = append(got, e.Timestamp().Unix())
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 11 {
+		t.Fatalf("Expected 11 events, without duplicates; got %v", got)
+	}
+	for i, ts := range got {
+		if ts != begin.Unix()+int64(10*i) {
+			t.Fatalf("Expected the events in order; got %v", got)
+		}
+	}
+	if maxInFlight < 2 || maxInFlight > 4 {
+		t.Fatalf("Expected up to four concurrent calls; got %d", maxInFlight)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	events, errs = mg.GetAllEvents(ctx, domain, opts)
+	for range events {
+	}
+	if err := <-errs; err != context.Canceled {
+		t.Fatal("Expected the cancellation to be reported; got ", err)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	var calls int
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/v3/domains" || r.URL.Query().Get("limit") != "1" {
+			t.Error("Unexpected request: ", r.URL)
+		}
+		if _, key, _ := r.BasicAuth(); key != apiKey {
+			t.Error("Expected the call to be authenticated")
+		}
+		w.Header().Set("X-RateLimit-Limit", "100")
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"total_count": 1, "items": []}`)
+	}))
+	defer srv.Close()
+
+	var results []HealthCheckResult
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"), WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+		WithHealthCheckHook(func(r HealthCheckResult) { results = append(results, r) }))
+	latency, err := mg.HealthCheck(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latency <= 0 || len(results) != 1 || results[0].Latency != latency {
+		t.Fatalf("Unexpected latency: %v; %#v", latency, results)
+	}
+	if r := results[0]; r.APIVersion != "v3" || r.Region != "" || r.RateLimitRemaining != 42 || r.Err != nil {
+		t.Fatalf("Unexpected result: %#v", r)
+	}
+
+	status = http.StatusServiceUnavailable
+	if _, err := mg.HealthCheck(context.Background()); err == nil {
+		t.Fatal("Expected the failure to be reported")
+	}
+	if calls != 2 || len(results) != 2 || results[1].Err == nil {
+		t.Fatalf("Expected one call, reported to the hook; got %d calls and %#v", calls, results)
+	}
+
+	if v, region := describeApiBase(apiBaseEU); v != "v3" || region != RegionEU {
+		t.Fatalf("Unexpected description of %s: %s %s", apiBaseEU, v, region)
+	}
+}
+
+func TestDeliveryTimeRFC2822(t *testing.T) {
+	deliveryTime := func(m *Message) string {
+		payload, err := newSendPayload(m, m.to, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, kv := range payload.Values {
+			if kv.key == "o:deliverytime" {
+				return kv.value
+			}
+		}
+		return ""
+	}
+
+	m := NewMessage("joe@example.com", "Hello", "Hello", "alice@example.com")
+	const tokyo = "Tue, 01 Oct 2019 09:00:00 +0900"
+	if err := m.SetDeliveryTimeRFC2822(tokyo); err != nil {
+		t.Fatal(err)
+	}
+	if got := deliveryTime(m); got != tokyo {
+		t.Fatalf("Expected the time verbatim; got %q", got)
+	}
+	if err := m.SetDeliveryTimeRFC2822("Tue, 1 Oct 2019 09:00:00 +0900"); err != nil {
+		t.Fatal("Expected a day without a leading zero to be accepted; got ", err)
+	}
+
+	for _, bad := range []string{"", "2019-10-01T09:00:00+09:00", "Tue, 01 Oct 2019 09:00:00 JST"} {
+		if err := m.SetDeliveryTimeRFC2822(bad); err != ErrInvalidDeliveryTime {
+			t.Errorf("Expected %q to be refused; got %v", bad, err)
+		}
+	}
+	if got := deliveryTime(m); got != "Tue, 1 Oct 2019 09:00:00 +0900" {
+		t.Fatalf("Expected a refused time to leave the schedule alone; got %q", got)
+	}
+
+	m.SetDeliveryTime(time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC))
+	if got := deliveryTime(m); got != "Tue, 1 Oct 2019 00:00:00 +0000" {
+		t.Fatalf("Expected SetDeliveryTime to replace the text; got %q", got)
+	}
+	m.ClearDeliveryTime()
+	if got := deliveryTime(m); got != "" {
+		t.Fatalf("Expected no schedule; got %q", got)
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	name, email, err := ParseAddress(`"Alice Smith" <alice@example.com>`)
+	if err != nil || name != "Alice Smith" || email != "alice@example.com" {
+		t.Fatalf("Unexpected parse: %q %q %v", name, email, err)
+	}
+	if name, email, err = ParseAddress("bob@example.com"); err != nil || name != "" || email != "bob@example.com" {
+		t.Fatalf("Unexpected parse: %q %q %v", name, email, err)
+	}
+	if _, _, err := ParseAddress("Alice <alice@"); err == nil {
+		t.Fatal("Expected a malformed address to be refused")
+	}
+
+	mg := NewMailgun(domain, apiKey, "")
+	m := mg.NewMessage("Joe <joe@example.com>", "Hello", "Hello", `"Alice" <alice@example.com>`)
+	m.AddCC("carol@example.com")
+	m.SetReplyTo("Support <support@example.com>")
+	if err := validateMessage(m); err != nil {
+		t.Fatal("Expected well-formed addresses to pass; got ", err)
+	}
+
+	tests := []struct {
+		field string
+		edit  func(*Message)
+	}{
+		{"to", func(m *Message) { m.to = append(m.to, "bob at example.com") }},
+		{"from", func(m *Message) { m.specific.(*plainMessage).from = "joe@" }},
+		{"cc", func(m *Message) { m.AddCC("carol@example.com, dave@example.com") }},
+		{"bcc", func(m *Message) { m.AddBCC("<eve@example.com") }},
+	}
+	for _, test := range tests {
+		c := m.Clone()
+		test.edit(c)
+		err := validateMessage(c)
+		var addrErr *InvalidAddressError
+		if !errors.As(err, &addrErr) || addrErr.Field != test.field || !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("Expected a malformed %s address to be refused; got %v", test.field, err)
+		}
+	}
+
+	m.SetReplyTo("Support <support@")
+	if err := validateMessage(m); err != ErrInvalidReplyTo {
+		t.Fatal("Expected a malformed Reply-To: address to be refused; got ", err)
+	}
+}
+
+func TestSendWithIdempotencyKey(t *testing.T) {
+	var keys []string
+	fail := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-Idempotency-Key"))
+		if fail > 0 {
+			fail--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"message": "Queued. Thank you.", "id": "<20111114174239.25659.5817@samples.mailgun.org>"}`)
+	}))
+	defer srv.Close()
+
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+	m := mg.NewMessage("me@example.com", "Hello", "Hello there", "you@example.com")
+	if _, _, err := mg.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Fatalf("Expected both attempts to carry the same generated key; got %q", keys)
+	}
+
+	keys, fail = nil, 1
+	if _, _, err := mg.SendWithIdempotencyKey(context.Background(), m, "order-42"); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "order-42" || keys[1] != "order-42" {
+		t.Fatalf("Expected both attempts to carry the key given; got %q", keys)
+	}
+
+	keys, fail = nil, 0
+	m.SetRecipientCC("you@example.com", "boss@example.com")
+	m.AddRecipient("them@example.com")
+	if _, _, err := mg.SendWithIdempotencyKey(context.Background(), m, "order-43"); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "order-43" || keys[1] != "order-43/you@example.com" {
+		t.Fatalf("Expected a key for each call; got %q", keys)
+	}
+
+	if _, _, err := mg.SendWithIdempotencyKey(context.Background(), m, ""); err != ErrEmptyParam {
+		t.Fatalf("Expected an empty key to be refused; got %v", err)
+	}
+
+	keys = nil
+	mg = NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"))
+	if _, _, err := mg.Send(context.Background(), mg.NewMessage("me@example.com", "Hello", "Hello there", "you@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "" {
+		t.Fatalf("Expected no key without retries; got %q", keys)
+	}
+}
+
+func TestWebhookMiddleware(t *testing.T) {
+	mg := NewMailgun(domain, apiKey, "")
+	mac := hmac.New(sha256.New, []byte(apiKey))
+	mac.Write([]byte("1500000000" + "abcdef"))
+	sig := hex.EncodeToString(mac.Sum(nil))
+
+	var reached []string
+	h := WebhookMiddleware(apiKey, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") == "application/json" {
+			payload, err := mg.ParseWebhookPayload(r)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			reached = append(reached, payload.EventData.Recipient())
+			return
+		}
+		reached = append(reached, r.FormValue("recipient"))
+	}))
+	serve := func(contentType, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	jsonBody := fmt.Sprintf(`{"signature": {"timestamp": "1500000000", "token": "abcdef", "signature": "%s"},
+		"event-data": {"event": "delivered", "recipient": "joe@example.com"}}`, sig)
+	formBody := url.Values{
+		"timestamp": {"1500000000"}, "token": {"abcdef"}, "signature": {sig}, "recipient": {"jane@example.com"},
+	}.Encode()
+	if w := serve("application/json", jsonBody); w.Code != http.StatusOK {
+		t.Fatal("Expected a signed JSON request to pass; got ", w.Code)
+	}
+	if w := serve("application/x-www-form-urlencoded", formBody); w.Code != http.StatusOK {
+		t.Fatal("Expected a signed form to pass; got ", w.Code)
+	}
+	if len(reached) != 2 || reached[0] != "joe@example.com" || reached[1] != "jane@example.com" {
+		t.Fatalf("Expected the handler to see both requests whole; got %q", reached)
+	}
+
+	for _, w := range []*httptest.ResponseRecorder{
+		serve("application/json", strings.Replace(jsonBody, "abcdef", "abcdeg", 1)),
+		serve("application/json", "{"),
+		serve("application/x-www-form-urlencoded", strings.Replace(formBody, "abcdef", "abcdeg", 1)),
+		serve("", ""),
+	} {
+		if w.Code != http.StatusForbidden || w.Body.String() != `{"error":"invalid signature"}` ||
+			w.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("Expected the request to be refused; got %d %s", w.Code, w.Body)
+		}
+	}
+	if len(reached) != 2 {
+		t.Fatal("Expected refused requests not to reach the handler")
+	}
+}
+
+func TestListMembersSubscribed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/lists/list@example.com/members/pages" {
+			t.Error("Unexpected path: ", r.URL.Path)
+		}
+		if r.URL.Query().Get("page") != "" {
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+			return
+		}
+		var items []string
+		subscribed, filtered := r.URL.Query()["subscribed"]
+		if !filtered || subscribed[0] == "yes" {
+			items = append(items, `{"address": "in@example.com", "subscribed": true}`)
+		}
+		if !filtered || subscribed[0] == "no" {
+			items = append(items, `{"address": "out@example.com", "subscribed": false}`)
+		}
+		next := "http://" + r.Host + r.URL.Path + "?page=2"
+		fmt.Fprintf(w, `{"items": [%s], "paging": {"next": %q}}`, strings.Join(items, ","), next)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"))
+
+	for _, test := range []struct {
+		subscribed *bool
+		limit      int
+		want       string
+	}{
+		{All, 0, "in@example.com,out@example.com"},
+		{Subscribed, 0, "in@example.com"},
+		{Unsubscribed, 10, "out@example.com"},
+	} {
+		var addresses []string
+		it := mg.ListMembers("list@example.com", ListOptions{Limit: test.limit, Subscribed: test.subscribed})
+		for it.Next(context.Background()) {
+			addresses = append(addresses, it.Item().Address)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(addresses, ","); got != test.want {
+			t.Fatalf("Expected %s; got %s", test.want, got)
+		}
+	}
+}
+
+func TestExportSuppressions(t *testing.T) {
+	// The bounces' server holds back the rest of the CSV until the client has read the header line.
+	headerRead := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/csv" {
+			t.Error("Expected CSV to be asked for; got ", r.Header.Get("Accept"))
+		}
+		switch r.URL.Path {
+		case "/v3/" + domain + "/bounces":
+			w.Header().Set("Content-Type", "text/csv")
+			fmt.Fprint(w, "address,code,error,created_at\n")
+			w.(http.Flusher).Flush()
+			select {
+			case <-headerRead:
+			case <-time.After(5 * time.Second):
+				t.Error("Expected the CSV to be read as it arrives")
+			}
+			fmt.Fprint(w, "bad@example.com,550,No such user,Mon, 01 Jan 2018 00:00:00 UTC\n")
+		case "/v3/" + domain + "/unsubscribes":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			fmt.Fprint(w, "address,tags,created_at\n")
+		case "/v3/" + domain + "/complaints":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"items": []}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"))
+	ctx := context.Background()
+
+	rc, err := mg.ExportBounces(ctx, domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bufio.NewReader(rc)
+	header, err := lines.ReadString('\n')
+	if err != nil || header != "address,code,error,created_at\n" {
+		t.Fatalf("Unexpected header %q: %v", header, err)
+	}
+	close(headerRead)
+	data, err := ioutil.ReadAll(lines)
+	rc.Close()
+	if err != nil || !strings.HasPrefix(string(data), "bad@example.com,") {
+		t.Fatalf("Unexpected bounces %q: %v", data, err)
+	}
+
+	var buf bytes.Buffer
+	if err := mg.WriteUnsubscribesCSV(ctx, domain, &buf); err != nil || buf.String() != "address,tags,created_at\n" {
+		t.Fatalf("Unexpected unsubscribes %q: %v", buf.String(), err)
+	}
+
+	if err := mg.WriteComplaintsCSV(ctx, domain, &buf); err == nil {
+		t.Fatal("Expected a JSON answer to be refused")
+	}
+	if _, err := mg.ExportBounces(ctx, "other.example.com"); err == nil {
+		t.Fatal("Expected the API error to be reported")
+	}
+
+	var calls int
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "address,created_at\n")
+	}))
+	defer flaky.Close()
+	mg = NewMailgun(domain, apiKey, "", WithAPIBase(flaky.URL+"/v3"),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+	buf.Reset()
+	if err := mg.WriteComplaintsCSV(ctx, domain, &buf); err != nil || calls != 2 || buf.String() != "address,created_at\n" {
+		t.Fatalf("Expected the failed call to be retried; got %d calls, %q, %v", calls, buf.String(), err)
+	}
+}
+
+func TestBatchSendWithCC(t *testing.T) {
+	var sizes []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The chunks have more parts than ParseMultipartForm allows, so count them as they come.
+		mr, err := r.MultipartReader()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var to, cc int
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			switch part.FormName() {
+			case "to":
+				to++
+			case "cc":
+				cc++
+			}
+		}
+		sizes = append(sizes, to)
+		if cc != 1 {
+			t.Errorf("Expected every chunk to carry the CC; got %d", cc)
+		}
+		fmt.Fprint(w, `{"id": "<id@example.com>", "message": "Queued. Thank you."}`)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"))
+
+	to := make([]string, 1500)
+	for i := range to {
+		to[i] = fmt.Sprintf("user%d@example.com", i)
+	}
+	m := mg.NewMessage("me@example.com", "Subject", "Hello", to...)
+	m.AddCC("boss@example.com")
+	if _, err := mg.BatchSend(m, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 2 || sizes[0] != MaxNumberOfRecipients-1 || sizes[1] != 1500-sizes[0] {
+		t.Fatalf("Expected chunks leaving room for the CC; got %v", sizes)
+	}
+
+	m = mg.NewMessage("me@example.com", "Subject", "Hello", "you@example.com")
+	for i := 0; i < MaxNumberOfRecipients; i++ {
+		m.AddBCC(fmt.Sprintf("watcher%d@example.com", i))
+	}
+	if _, err := mg.BatchSend(m, 0); !errors.Is(err, ErrTooManyRecipients) {
+		t.Fatalf("Expected ErrTooManyRecipients; got %v", err)
+	}
+}
+
+func TestImportMailingListUncounted(t *testing.T) {
+	const addr = "news@example.com"
+	lists := &fakeLists{
+		lists:   map[string]List{addr: {Address: addr}},
+		members: map[string]map[string]Member{addr: {}},
+	}
+	// Once the first batch is in, the list's member count can no longer be fetched.
+	var batches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/v3/lists/"+addr && batches > 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Method == "POST" {
+			batches++
+		}
+		lists.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"))
+
+	csv := "email,name\na@example.com,A\nb@example.com,B\nc@example.com,C\n"
+	result, err := mg.ImportMailingList(context.Background(), addr, strings.NewReader(csv), ImportOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Uncounted != 3 || result.Added != 0 || result.Failed != 0 || len(result.RowErrors) != 0 {
+		t.Fatalf("Expected the batches to be applied, uncounted; got %+v", result)
+	}
+	if batches != 2 || len(lists.members[addr]) != 3 {
+		t.Fatalf("Expected 3 members added in 2 batches; got %d in %d", len(lists.members[addr]), batches)
+	}
+}
+
+func TestExportMailingList(t *testing.T) {
+	const addr = "news@example.com"
+	lists := &fakeLists{
+		lists:   map[string]List{addr: {Address: addr}, "empty@example.com": {Address: "empty@example.com"}},
+		members: map[string]map[string]Member{addr: {}, "empty@example.com": {}},
+	}
+	for i := 0; i < exportPageSize+1; i++ {
+		m := Member{Address: fmt.Sprintf("user%03d@example.com", i), Name: "User", Subscribed: &yes}
+		if i == 0 {
+			m.Vars = map[string]interface{}{"plan": "pro"}
+			m.Subscribed = &no
+		}
+		lists.members[addr][m.Address] = m
+	}
+	var pages int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/members") {
+			pages++
+		}
+		lists.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	var progress bytes.Buffer
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"), WithLogger(NewDefaultLogger(&progress, LogLevelInfo)))
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	if err := mg.ExportMailingList(ctx, addr, ExportCSV, &out); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != exportPageSize+2 || strings.Join(rows[0], ",") != "email,name,subscribed,vars_json" {
+		t.Fatalf("Expected a header and %d members; got %d rows, starting %q", exportPageSize+1, len(rows), rows[0])
+	}
+	if got := strings.Join(rows[1], "|"); got != `user000@example.com|User|false|{"plan":"pro"}` {
+		t.Fatal("Unexpected row: ", got)
+	}
+	if got := strings.Join(rows[len(rows)-1], "|"); got != "user100@example.com|User|true|null" {
+		t.Fatal("Unexpected row: ", got)
+	}
+	if pages != 2 {
+		t.Fatalf("Expected 2 pages to be fetched; got %d", pages)
+	}
+	if !strings.Contains(progress.String(), "mailgun: exported 101 members of "+addr+"\n") {
+		t.Fatalf("Expected progress to reach the logger; got %q", progress.String())
+	}
+
+	out.Reset()
+	if err := mg.ExportMailingList(ctx, addr, ExportJSON, &out); err != nil {
+		t.Fatal(err)
+	}
+	var members []Member
+	if err := json.Unmarshal(out.Bytes(), &members); err != nil {
+		t.Fatalf("Expected a JSON array; got %v", err)
+	}
+	if len(members) != exportPageSize+1 || members[100].Address != "user100@example.com" {
+		t.Fatalf("Unexpected members: %d", len(members))
+	}
+
+	out.Reset()
+	if err := mg.ExportMailingList(ctx, "empty@example.com", ExportJSON, &out); err != nil || out.String() != "[]\n" {
+		t.Fatalf("Expected an empty array; got %q, %v", out.String(), err)
+	}
+
+	if err := mg.ExportMailingList(ctx, addr, "xml", &out); err == nil || !strings.Contains(err.Error(), `"xml"`) {
+		t.Fatal("Expected an unknown format to be refused; got ", err)
+	}
+}
+
+func TestGetRecipientHistory(t *testing.T) {
+	var limits, pages []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("recipient") != "joe@example.com" || q.Get("ascending") != "yes" {
+			t.Error("Expected the recipient filter, in ascending order; got ", r.URL.RawQuery)
+		}
+		limits = append(limits, q.Get("limit"))
+		pages = append(pages, q.Get("page"))
+		next := "http://" + r.Host + r.URL.Path + "?recipient=joe@example.com&ascending=yes&page="
+		switch q.Get("page") {
+		case "":
+			fmt.Fprintf(w, `{"items": [{"event": "delivered", "timestamp": 1500000002}, {"event": "accepted", "timestamp": 1500000001},
+				{"event": "opened", "timestamp": 1500000003}], "paging": {"next": %q}}`, next+"2")
+		case "2":
+			fmt.Fprintf(w, `{"items": [{"event": "clicked", "timestamp": 1500000005}, {"event": "opened", "timestamp": 1500000004},
+				{"event": "unsubscribed", "timestamp": 1500000006}], "paging": {"next": %q}}`, next+"3")
+		default:
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+		}
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"))
+	names := func(events []Event) string {
+		var all []string
+		for _, e := range events {
+			all = append(all, e.Name())
+		}
+		return strings.Join(all, " ")
+	}
+
+	events, err := mg.GetRecipientHistory(context.Background(), domain, "joe@example.com", EventOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(events); got != "accepted delivered opened opened clicked unsubscribed" {
+		t.Fatal("Expected every event, oldest first; got ", got)
+	}
+	if limits[0] != "300" || len(pages) != 3 {
+		t.Fatalf("Expected full pages to be fetched until they ran out; got limits %q, pages %q", limits, pages)
+	}
+
+	limits, pages = nil, nil
+	events, err = mg.GetRecipientHistory(context.Background(), domain, "joe@example.com", EventOptions{Limit: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(events); got != "accepted delivered opened clicked" {
+		t.Fatal("Expected the first 4 events to arrive, oldest first; got ", got)
+	}
+	if limits[0] != "4" || len(pages) != 2 {
+		t.Fatalf("Expected paging to stop at the limit; got limits %q, pages %q", limits, pages)
+	}
+}
+
+func TestGetClicksForURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("event") != "clicked" {
+			t.Error("Expected only clicks to be asked for; got ", r.URL.RawQuery)
+		}
+		if r.URL.Query().Get("page") != "" {
+			fmt.Fprint(w, `{"items": [], "paging": {}}`)
+			return
+		}
+		click := func(url, recipient, id string) string {
+			return fmt.Sprintf(`{"event": "clicked", "url": %q, "recipient": %q, "message": {"headers": {"message-id": %q}}}`,
+				url, recipient, id)
+		}
+		items := []string{
+			click("https://example.com/sale", "ann@example.com", "a@example.com"),
+			click("HTTPS://Example.com/sale#top", "Ann@example.com", "a@example.com"),
+			click("https://example.com/other", "ann@example.com", "a@example.com"),
+			click("https://example.com/sale", "bob@example.com", "b@example.com"),
+			click("https://example.com/sale", "ann@example.com", "b@example.com"),
+			click("https://example.com/sale", "cat@example.com", "b@example.com"),
+		}
+		next := "http://" + r.Host + r.URL.Path + "?event=clicked&page=2"
+		fmt.Fprintf(w, `{"items": [%s], "paging": {"next": %q}}`, strings.Join(items, ","), next)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"))
+
+	stats, err := mg.GetClicksForURL(context.Background(), domain, "https://example.com/sale", StatsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.URL != "https://example.com/sale" || stats.TotalClicks != 5 || stats.UniqueClicks != 3 {
+		t.Fatalf("Unexpected totals: %+v", stats)
+	}
+	want := "b@example.com 3 3, a@example.com 2 1"
+	var got []string
+	for _, m := range stats.ByMessage {
+		got = append(got, fmt.Sprintf("%s %d %d", m.MessageID, m.TotalClicks, m.UniqueClicks))
+	}
+	if strings.Join(got, ", ") != want {
+		t.Fatalf("Expected clicks by message %s; got %s", want, strings.Join(got, ", "))
+	}
+}
+
+func TestSandboxRecipients(t *testing.T) {
+	recipients := map[string]bool{"Joe@Example.com": true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const path = "/v5/sandbox/auth_recipients"
+		if r.URL.Query().Get("domain") != domain {
+			t.Error("Expected the sandbox domain; got ", r.URL.RawQuery)
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == path:
+			var items []string
+			for email, activated := range recipients {
+				items = append(items, fmt.Sprintf(`{"email": %q, "activated": %t}`, email, activated))
+			}
+			sort.Strings(items)
+			fmt.Fprintf(w, `{"recipients": [%s]}`, strings.Join(items, ","))
+		case r.Method == "POST" && r.URL.Path == path:
+			recipients[r.FormValue("email")] = false
+			fmt.Fprint(w, `{"message": "ok"}`)
+		case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, path+"/"):
+			delete(recipients, strings.TrimPrefix(r.URL.Path, path+"/"))
+			fmt.Fprint(w, `{"message": "ok"}`)
+		default:
+			t.Error("Unexpected call: ", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"))
+	ctx := context.Background()
+
+	if err := mg.AddSandboxAllowedRecipient(ctx, "ann@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	emails, err := mg.ListSandboxAllowedRecipients(ctx)
+	if err != nil || strings.Join(emails, ",") != "Joe@Example.com,ann@example.com" {
+		t.Fatalf("Unexpected recipients: %q, %v", emails, err)
+	}
+	if ok, err := mg.IsSandboxRecipientAllowed(ctx, "joe@example.COM"); err != nil || !ok {
+		t.Fatalf("Expected an activated recipient to be allowed, whatever the case; got %t, %v", ok, err)
+	}
+	if ok, err := mg.IsSandboxRecipientAllowed(ctx, "ann@example.com"); err != nil || ok {
+		t.Fatalf("Expected a recipient yet to confirm not to be allowed; got %t, %v", ok, err)
+	}
+	if err := mg.DeleteSandboxAllowedRecipient(ctx, "Joe@Example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := mg.IsSandboxRecipientAllowed(ctx, "joe@example.com"); err != nil || ok {
+		t.Fatalf("Expected a deleted recipient not to be allowed; got %t, %v", ok, err)
+	}
+}
+
+func TestSendVerificationMessage(t *testing.T) {
+	const d = "other.example.com"
+	id := "<verify@other.example.com>"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/"+d+"/messages" {
+			t.Error("Expected the named domain's endpoint; got ", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Error(err)
+		}
+		if r.FormValue("o:testmode") != "yes" || r.FormValue("from") != "test@"+d || r.FormValue("to") != "postmaster@"+d {
+			t.Errorf("Unexpected message: %v", r.MultipartForm.Value)
+		}
+		fmt.Fprintf(w, `{"id": %q, "message": "Queued. Thank you."}`, id)
+	}))
+	defer srv.Close()
+	mg := NewMailgun(domain, apiKey, "", WithAPIBase(srv.URL+"/v3"))
+
+	got, err := mg.SendVerificationMessage(context.Background(), d)
+	if err != nil || got != id {
+		t.Fatalf("Unexpected result: %q, %v", got, err)
+	}
+
+	id = ""
+	if _, err := mg.SendVerificationMessage(context.Background(), d); err == nil {
+		t.Fatal("Expected a reply without a message ID to be reported")
+	}
+}
@@ -0,0 +1,491 @@
+// Package mailguntest provides a mock Mailgun API server, for the unit tests of programs which use the mailgun package.
+// The mock keeps everything in memory, and needs no credentials or network access beyond the loopback interface.
+//
+// It covers the messages, events, bounces, and unsubscribes endpoints:
+// messages sent through it are recorded, rather than delivered, and show up as accepted and delivered events;
+// bounces and unsubscribes are kept in per-domain tables.
+// Calls to other endpoints fail with HTTP 404.
+//
+//	mg, srv := mailguntest.NewMockMailgun()
+//	defer srv.Close()
+//	notifyCustomer(mg, "joe@example.com")
+//	mg.AssertSent(t, "joe@example.com", "Your order has shipped")
+package mailguntest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mailgun/mailgun-go"
+)
+
+// Domain and APIKey are the domain and API key of the client NewMockMailgun returns.
+// The mock server accepts any domain, but only this API key.
+const (
+	Domain = "mailgun.test"
+	APIKey = "mailguntest-api-key"
+)
+
+// A SentMessage records a message sent through the mock server.
+// To, Cc, and Bcc list the recipients as given, one address per entry.
+// MIME holds the message itself, for messages sent as MIME; the other fields are then empty, save for To.
+// Values holds every form field the client sent, including options such as o:tag and variables such as v:name.
+type SentMessage struct {
+	ID      string
+	Domain  string
+	From    string
+	To      []string
+	Cc      []string
+	Bcc     []string
+	Subject string
+	Text    string
+	HTML    string
+	Tags    []string
+	MIME    string
+	Values  url.Values
+}
+
+// MockMailgun is a Mailgun client bound to a mock server.
+// It implements the Mailgun interface by way of a real client, so code under test can't tell it apart,
+// and adds methods to inspect and reset what the mock server has recorded.
+// It's safe for concurrent use.
+type MockMailgun struct {
+	mailgun.Mailgun
+
+	mu           sync.Mutex
+	sent         []SentMessage
+	events       map[string][]mailgun.Event
+	bounces      map[string]map[string]mailgun.Bounce
+	unsubscribes map[string]map[string]mailgun.Unsubscription
+	calls        map[string]int
+}
+
+var _ mailgun.Mailgun = (*MockMailgun)(nil)
+
+// NewMockMailgun starts a mock server, and returns a client bound to it, along with the server itself.
+// Close the server once done with it.
+func NewMockMailgun() (*MockMailgun, *httptest.Server) {
+	m := &MockMailgun{}
+	m.Reset()
+	srv := httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	m.Mailgun = mailgun.NewMailgun(Domain, APIKey, "", mailgun.WithAPIBase(srv.URL+"/v3"))
+	return m, srv
+}
+
+// Reset forgets every message, event, bounce, unsubscription, and call the mock server has recorded.
+func (m *MockMailgun) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = nil
+	m.events = map[string][]mailgun.Event{}
+	m.bounces = map[string]map[string]mailgun.Bounce{}
+	m.unsubscribes = map[string]map[string]mailgun.Unsubscription{}
+	m.calls = map[string]int{}
+}
+
+// Sent returns the messages sent through the mock server so far, oldest first.
+func (m *MockMailgun) Sent() []SentMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]SentMessage(nil), m.sent...)
+}
+
+// Calls returns the number of API calls the mock server has taken, by method and endpoint, as in "POST messages" or "GET bounces".
+func (m *MockMailgun) Calls(method, endpoint string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls[method+" "+endpoint]
+}
+
+// AssertSent fails the test unless a message with the given subject was sent to the given address,
+// whether as a To:, Cc:, or Bcc: recipient.
+func (m *MockMailgun) AssertSent(t testing.TB, to, subject string) {
+	t.Helper()
+	for _, msg := range m.Sent() {
+		if msg.Subject == subject && hasRecipient(msg, to) {
+			return
+		}
+	}
+	t.Errorf("mailguntest: no message with subject %q was sent to %s", subject, to)
+}
+
+// AssertNotSent fails the test if any message was sent to the given address.
+func (m *MockMailgun) AssertNotSent(t testing.TB, to string) {
+	t.Helper()
+	for _, msg := range m.Sent() {
+		if hasRecipient(msg, to) {
+			t.Errorf("mailguntest: message %q was sent to %s", msg.Subject, to)
+		}
+	}
+}
+
+// AssertSentCount fails the test unless exactly n messages were sent.
+func (m *MockMailgun) AssertSentCount(t testing.TB, n int) {
+	t.Helper()
+	if got := len(m.Sent()); got != n {
+		t.Errorf("mailguntest: expected %d messages to be sent; got %d", n, got)
+	}
+}
+
+// hasRecipient reports whether the message went to the address, which the message may carry with a display name.
+func hasRecipient(msg SentMessage, address string) bool {
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		for _, r := range list {
+			if r == address || strings.HasSuffix(r, "<"+address+">") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveHTTP routes an API call to the handler for its endpoint.
+// Paths have the form /v3/<domain>/<endpoint>[/<address>].
+func (m *MockMailgun) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, key, ok := r.BasicAuth(); !ok || user != "api" || key != APIKey {
+		writeMessage(w, http.StatusUnauthorized, "Forbidden")
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v3/"), "/", 3)
+	if len(parts) < 2 {
+		writeMessage(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	domain, endpoint, target := parts[0], parts[1], ""
+	if len(parts) == 3 {
+		target = parts[2]
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls[r.Method+" "+endpoint]++
+
+	switch {
+	case endpoint == "messages" && target == "" && r.Method == "POST":
+		m.handleSend(w, r, domain, false)
+	case endpoint == "messages.mime" && target == "" && r.Method == "POST":
+		m.handleSend(w, r, domain, true)
+	case endpoint == "events" && target == "" && r.Method == "GET":
+		m.handleEvents(w, r, domain)
+	case endpoint == "bounces":
+		m.handleBounces(w, r, domain, target)
+	case endpoint == "unsubscribes":
+		m.handleUnsubscribes(w, r, domain, target)
+	default:
+		writeMessage(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (m *MockMailgun) handleSend(w http.ResponseWriter, r *http.Request, domain string, mime bool) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	f := r.MultipartForm.Value
+	msg := SentMessage{
+		ID:      fmt.Sprintf("<%d.%d@%s>", time.Now().UnixNano(), len(m.sent)+1, domain),
+		Domain:  domain,
+		From:    first(f["from"]),
+		To:      splitAddresses(f["to"]),
+		Cc:      splitAddresses(f["cc"]),
+		Bcc:     splitAddresses(f["bcc"]),
+		Subject: first(f["subject"]),
+		Text:    first(f["text"]),
+		HTML:    first(f["html"]),
+		Tags:    f["o:tag"],
+		Values:  url.Values(f),
+	}
+	if mime {
+		file, _, err := r.FormFile("message")
+		if err != nil {
+			writeMessage(w, http.StatusBadRequest, "Need at least one message")
+			return
+		}
+		data, _ := ioutil.ReadAll(file)
+		file.Close()
+		msg.MIME = string(data)
+	} else if msg.From == "" {
+		writeMessage(w, http.StatusBadRequest, "'from' parameter is missing")
+		return
+	}
+	if len(msg.To)+len(msg.Cc)+len(msg.Bcc) == 0 {
+		writeMessage(w, http.StatusBadRequest, "'to' parameter is missing")
+		return
+	}
+	m.sent = append(m.sent, msg)
+
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		for _, recipient := range list {
+			for _, name := range []string{"accepted", "delivered"} {
+				m.events[domain] = append(m.events[domain], newEvent(name, recipient, msg))
+			}
+		}
+	}
+	writeJSON(w, map[string]string{"id": msg.ID, "message": "Queued. Thank you."})
+}
+
+// newEvent renders an event about a message sent through the mock server, in the form Mailgun reports events.
+func newEvent(name, recipient string, msg SentMessage) mailgun.Event {
+	tags := make([]interface{}, len(msg.Tags))
+	for i, tag := range msg.Tags {
+		tags[i] = tag
+	}
+	return mailgun.Event{
+		"id":        fmt.Sprintf("%s-%s-%s", msg.ID, name, recipient),
+		"event":     name,
+		"timestamp": float64(time.Now().UnixNano()) / 1e9,
+		"recipient": recipient,
+		"tags":      tags,
+		"message": map[string]interface{}{
+			"headers": map[string]interface{}{
+				"message-id": strings.Trim(msg.ID, "<>"),
+				"from":       msg.From,
+				"subject":    msg.Subject,
+				"to":         strings.Join(msg.To, ", "),
+			},
+		},
+	}
+}
+
+// handleEvents serves the domain's events, oldest first, filtered by event, recipient, and message-id,
+// all on the first page.  The event filter may name several kinds of event, joined by " OR ".
+// The page after it is empty, which tells clients they've reached the end.
+func (m *MockMailgun) handleEvents(w http.ResponseWriter, r *http.Request, domain string) {
+	q := r.URL.Query()
+	items := []mailgun.Event{}
+	if q.Get("page") == "" {
+		for _, e := range m.events[domain] {
+			if matchesEventFilter(e.Name(), q.Get("event")) && (q.Get("recipient") == "" || e.Recipient() == q.Get("recipient")) &&
+				(q.Get("message-id") == "" || e.MessageID() == q.Get("message-id")) {
+				items = append(items, e)
+			}
+		}
+	}
+	q.Set("page", "next")
+	next := fmt.Sprintf("http://%s%s?%s", r.Host, r.URL.Path, q.Encode())
+	writeJSON(w, map[string]interface{}{
+		"items":  items,
+		"paging": map[string]string{"next": next, "previous": next},
+	})
+}
+
+// matchesEventFilter reports whether an event's name satisfies an event filter, such as "delivered OR failed".
+// An empty filter matches every event.
+func matchesEventFilter(name, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	for _, want := range strings.Split(filter, " OR ") {
+		if strings.TrimSpace(want) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MockMailgun) handleBounces(w http.ResponseWriter, r *http.Request, domain, address string) {
+	table := m.bounces[domain]
+	if table == nil {
+		table = map[string]mailgun.Bounce{}
+		m.bounces[domain] = table
+	}
+	switch {
+	case r.Method == "GET" && address == "":
+		addresses := make([]string, 0, len(table))
+		for address := range table {
+			addresses = append(addresses, address)
+		}
+		sort.Strings(addresses)
+		lo, hi := pageBounds(r, len(addresses))
+		items := []mailgun.Bounce{}
+		for _, address := range addresses[lo:hi] {
+			items = append(items, table[address])
+		}
+		writeJSON(w, map[string]interface{}{"total_count": len(table), "items": items})
+	case r.Method == "GET":
+		b, ok := table[address]
+		if !ok {
+			writeMessage(w, http.StatusNotFound, "Address not found in bounces table")
+			return
+		}
+		writeJSON(w, map[string]interface{}{"bounce": b})
+	case r.Method == "POST" && address == "":
+		var bounces []mailgun.Bounce
+		if isJSON(r) {
+			if err := json.NewDecoder(r.Body).Decode(&bounces); err != nil {
+				writeMessage(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		} else {
+			r.ParseForm()
+			b := mailgun.Bounce{Address: r.PostForm.Get("address"), Error: r.PostForm.Get("error")}
+			if code := r.PostForm.Get("code"); code != "" {
+				b.Code = code
+			}
+			bounces = append(bounces, b)
+		}
+		for _, b := range bounces {
+			if b.Address == "" {
+				writeMessage(w, http.StatusBadRequest, "'address' parameter is missing")
+				return
+			}
+			if b.CreatedAt == "" {
+				b.CreatedAt = now()
+			}
+			if b.Code == nil {
+				b.Code = "550"
+			}
+			table[b.Address] = b
+		}
+		writeMessage(w, http.StatusOK, "Address has been added to the bounces table")
+	case r.Method == "DELETE" && address != "":
+		delete(table, address)
+		writeMessage(w, http.StatusOK, "Bounced address has been removed")
+	default:
+		writeMessage(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (m *MockMailgun) handleUnsubscribes(w http.ResponseWriter, r *http.Request, domain, address string) {
+	table := m.unsubscribes[domain]
+	if table == nil {
+		table = map[string]mailgun.Unsubscription{}
+		m.unsubscribes[domain] = table
+	}
+	switch {
+	case r.Method == "GET" && address == "":
+		addresses := make([]string, 0, len(table))
+		for address := range table {
+			addresses = append(addresses, address)
+		}
+		sort.Strings(addresses)
+		lo, hi := pageBounds(r, len(addresses))
+		items := []mailgun.Unsubscription{}
+		for _, address := range addresses[lo:hi] {
+			items = append(items, table[address])
+		}
+		writeJSON(w, map[string]interface{}{"total_count": len(table), "items": items})
+	case r.Method == "GET":
+		u, ok := table[address]
+		if !ok {
+			writeMessage(w, http.StatusNotFound, "Address not found in unsubscribers table")
+			return
+		}
+		writeJSON(w, map[string]interface{}{"total_count": 1, "items": []mailgun.Unsubscription{u}})
+	case r.Method == "POST" && address == "":
+		var unsubscribes []mailgun.Unsubscription
+		if isJSON(r) {
+			var imports []struct {
+				Address   string   `json:"address"`
+				Tags      []string `json:"tags"`
+				CreatedAt string   `json:"created_at"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&imports); err != nil {
+				writeMessage(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			for _, u := range imports {
+				unsubscribes = append(unsubscribes, mailgun.Unsubscription{Address: u.Address, Tag: first(u.Tags), CreatedAt: u.CreatedAt})
+			}
+		} else {
+			r.ParseForm()
+			unsubscribes = append(unsubscribes, mailgun.Unsubscription{Address: r.PostForm.Get("address"), Tag: r.PostForm.Get("tag")})
+		}
+		for _, u := range unsubscribes {
+			if u.Address == "" {
+				writeMessage(w, http.StatusBadRequest, "'address' parameter is missing")
+				return
+			}
+			if u.Tag == "" {
+				u.Tag = "*"
+			}
+			if u.CreatedAt == "" {
+				u.CreatedAt = now()
+			}
+			u.ID = strconv.Itoa(len(table) + 1)
+			table[u.Address] = u
+		}
+		writeMessage(w, http.StatusOK, "Address has been added to the unsubscribes table")
+	case r.Method == "DELETE" && address != "":
+		delete(table, address)
+		for id, u := range table {
+			if u.ID == address {
+				delete(table, id)
+			}
+		}
+		writeMessage(w, http.StatusOK, "Unsubscribe event has been removed")
+	default:
+		writeMessage(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// pageBounds applies the limit and skip parameters of a list request to a list of n items,
+// giving the bounds of the page within the list.  Mailgun's default limit is 100.
+func pageBounds(r *http.Request, n int) (lo, hi int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+	if skip < 0 {
+		skip = 0
+	}
+	lo, hi = skip, skip+limit
+	if lo > n {
+		lo = n
+	}
+	if hi > n {
+		hi = n
+	}
+	return lo, hi
+}
+
+// splitAddresses flattens form values which may each hold several comma-separated addresses.
+func splitAddresses(values []string) []string {
+	var addresses []string
+	for _, v := range values {
+		for _, a := range strings.Split(v, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				addresses = append(addresses, a)
+			}
+		}
+	}
+	return addresses
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func isJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+}
+
+// now gives the current time in the RFC-2822 form Mailgun reports times in.
+func now() string {
+	return time.Now().UTC().Format(time.RFC1123)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeMessage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
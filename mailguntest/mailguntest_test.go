@@ -0,0 +1,68 @@
+package mailguntest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mailgun/mailgun-go"
+)
+
+func TestMockMailgun(t *testing.T) {
+	mg, srv := NewMockMailgun()
+	defer srv.Close()
+	ctx := context.Background()
+
+	m := mg.NewMessage("me@example.com", "Welcome", "Hello", "Joe <joe@example.com>")
+	m.AddCC("cc@example.com")
+	m.AddTag("welcome")
+	_, id, err := mg.Send(ctx, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mg.AssertSent(t, "joe@example.com", "Welcome")
+	mg.AssertSent(t, "cc@example.com", "Welcome")
+	mg.AssertNotSent(t, "jane@example.com")
+	mg.AssertSentCount(t, 1)
+	if sent := mg.Sent()[0]; sent.ID != id || sent.Text != "Hello" || sent.Tags[0] != "welcome" {
+		t.Fatalf("Unexpected record: %#v", sent)
+	}
+	if mg.Calls("POST", "messages") != 1 {
+		t.Fatal("Expected one call to send a message")
+	}
+
+	events, _, err := mg.GetEvents(ctx, Domain, mailgun.EventsOptions{Event: "delivered"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Recipient() != "Joe <joe@example.com>" || events[0].Tags()[0] != "welcome" {
+		t.Fatalf("Unexpected events: %v", events)
+	}
+
+	if err := mg.AddBounce("gone@example.com", "550", "No such mailbox"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := mg.GetSingleBounce("gone@example.com")
+	if code, _ := b.GetCode(); err != nil || code != 550 || b.Error != "No such mailbox" {
+		t.Fatalf("Unexpected bounce: %#v, %v", b, err)
+	}
+	if err := mg.DeleteBounce("gone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mg.GetSingleBounce("gone@example.com"); err == nil {
+		t.Fatal("Expected the bounce to be gone")
+	}
+
+	if err := mg.ImportUnsubscribes(ctx, []mailgun.Unsubscription{{Address: "a@example.com"}, {Address: "b@example.com", Tag: "news"}}); err != nil {
+		t.Fatal(err)
+	}
+	n, unsubscribes, err := mg.GetUnsubscribes(1, 1)
+	if err != nil || n != 2 || len(unsubscribes) != 1 || unsubscribes[0].Tag != "news" {
+		t.Fatalf("Unexpected unsubscribes: %d, %v, %v", n, unsubscribes, err)
+	}
+
+	mg.Reset()
+	mg.AssertSentCount(t, 0)
+	if _, _, err := mg.GetDomains(mailgun.DefaultLimit, mailgun.DefaultSkip); err == nil {
+		t.Fatal("Expected an unmocked endpoint to fail")
+	}
+}
@@ -1,9 +1,13 @@
 package mailgun
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"strconv"
+	"strings"
 )
 
 // A mailing list may have one of three membership modes.
@@ -28,6 +32,38 @@ var (
 	Unsubscribed *bool = &no
 )
 
+// BulkAddMembers adds the members to the mailing list, posting them to Mailgun as JSON
+// in batches of up to 1000, the most Mailgun accepts per call.
+// Each member needs at least its Address set.
+// Members already on the list are left as they are.
+// If a batch fails, the members of earlier batches remain on the list.
+func (mg *MailgunImpl) BulkAddMembers(ctx context.Context, addr string, members []Member) error {
+	for start := 0; start < len(members); start += maxImportBatchSize {
+		end := start + maxImportBatchSize
+		if end > len(members) {
+			end = len(members)
+		}
+		if err := mg.addMembersBatch(ctx, addr, false, members[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportMailingList writes members in one of two formats.
+// ExportCSV writes a header line, followed by one line per member with the columns email, name, subscribed, and vars_json.
+// ExportJSON writes a JSON array of Member structures.
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+)
+
+// maxImportBatchSize is the largest number of members Mailgun accepts in a single bulk addition.
+const maxImportBatchSize = 1000
+
+// exportPageSize is the number of members ExportMailingList fetches at once; it's the most Mailgun allows.
+const exportPageSize = 100
+
 // yes and no are variables which provide us the ability to take their addresses.
 // Subscribed and Unsubscribed are pointers to these booleans.
 //
@@ -62,8 +98,8 @@ type Member struct {
 
 // GetLists returns the specified set of mailing lists administered by your account.
 func (mg *MailgunImpl) GetLists(limit, skip int, filter string) (int, []List, error) {
-	r := newHTTPRequest(generatePublicApiUrl(listsEndpoint))
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generatePublicApiUrl(mg, listsEndpoint))
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newUrlEncodedPayload()
 	if limit != DefaultLimit {
@@ -93,8 +129,8 @@ func (mg *MailgunImpl) GetLists(limit, skip int, filter string) (int, []List, er
 // If unspecified, Description remains blank,
 // while AccessLevel defaults to Everyone.
 func (mg *MailgunImpl) CreateList(prototype List) (List, error) {
-	r := newHTTPRequest(generatePublicApiUrl(listsEndpoint))
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generatePublicApiUrl(mg, listsEndpoint))
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newUrlEncodedPayload()
 	if prototype.Address != "" {
@@ -113,16 +149,18 @@ func (mg *MailgunImpl) CreateList(prototype List) (List, error) {
 	if err != nil {
 		return List{}, err
 	}
-	var l List
-	err = response.parseFromJSON(&l)
-	return l, err
+	var envelope struct {
+		List `json:"list"`
+	}
+	err = response.parseFromJSON(&envelope)
+	return envelope.List, err
 }
 
 // DeleteList removes all current members of the list, then removes the list itself.
 // Attempts to send e-mail to the list will fail subsequent to this call.
 func (mg *MailgunImpl) DeleteList(addr string) error {
-	r := newHTTPRequest(generatePublicApiUrl(listsEndpoint) + "/" + addr)
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generatePublicApiUrl(mg, listsEndpoint) + "/" + addr)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	_, err := makeDeleteRequest(r)
 	return err
@@ -131,10 +169,13 @@ func (mg *MailgunImpl) DeleteList(addr string) error {
 // GetListByAddress allows your application to recover the complete List structure
 // representing a mailing list, so long as you have its e-mail address.
 func (mg *MailgunImpl) GetListByAddress(addr string) (List, error) {
-	r := newHTTPRequest(generatePublicApiUrl(listsEndpoint) + "/" + addr)
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generatePublicApiUrl(mg, listsEndpoint) + "/" + addr)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	response, err := makeGetRequest(r)
+	if err != nil {
+		return List{}, err
+	}
 	var envelope struct {
 		List `json:"list"`
 	}
@@ -150,8 +191,8 @@ func (mg *MailgunImpl) GetListByAddress(addr string) (List, error) {
 // e-mail sent to the old address will not succeed.
 // Make sure you account for the change accordingly.
 func (mg *MailgunImpl) UpdateList(addr string, prototype List) (List, error) {
-	r := newHTTPRequest(generatePublicApiUrl(listsEndpoint) + "/" + addr)
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generatePublicApiUrl(mg, listsEndpoint) + "/" + addr)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newUrlEncodedPayload()
 	if prototype.Address != "" {
@@ -166,13 +207,15 @@ func (mg *MailgunImpl) UpdateList(addr string, prototype List) (List, error) {
 	if prototype.AccessLevel != "" {
 		p.addValue("access_level", prototype.AccessLevel)
 	}
-	var l List
 	response, err := makePutRequest(r, p)
 	if err != nil {
-		return l, err
+		return List{}, err
 	}
-	err = response.parseFromJSON(&l)
-	return l, err
+	var envelope struct {
+		List `json:"list"`
+	}
+	err = response.parseFromJSON(&envelope)
+	return envelope.List, err
 }
 
 // GetMembers returns the list of members belonging to the indicated mailing list.
@@ -180,8 +223,8 @@ func (mg *MailgunImpl) UpdateList(addr string, prototype List) (List, error) {
 // All indicates that you want both Members and unsubscribed members alike, while
 // Subscribed and Unsubscribed indicate you want only those eponymous subsets.
 func (mg *MailgunImpl) GetMembers(limit, skip int, s *bool, addr string) (int, []Member, error) {
-	r := newHTTPRequest(generateMemberApiUrl(listsEndpoint, addr))
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, addr))
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newUrlEncodedPayload()
 	if limit != DefaultLimit {
@@ -208,8 +251,8 @@ func (mg *MailgunImpl) GetMembers(limit, skip int, s *bool, addr string) (int, [
 // GetMemberByAddress returns a complete Member structure for a member of a mailing list,
 // given only their subscription e-mail address.
 func (mg *MailgunImpl) GetMemberByAddress(s, l string) (Member, error) {
-	r := newHTTPRequest(generateMemberApiUrl(listsEndpoint, l) + "/" + s)
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, l) + "/" + s)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	response, err := makeGetRequest(r)
 	if err != nil {
@@ -231,8 +274,8 @@ func (mg *MailgunImpl) CreateMember(merge bool, addr string, prototype Member) e
 		return err
 	}
 
-	r := newHTTPRequest(generateMemberApiUrl(listsEndpoint, addr))
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, addr))
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newFormDataPayload()
 	p.addValue("upsert", yesNo(merge))
@@ -249,8 +292,8 @@ func (mg *MailgunImpl) CreateMember(merge bool, addr string, prototype Member) e
 // UpdateMember lets you change certain details about the indicated mailing list member.
 // Address, Name, Vars, and Subscribed fields may be changed.
 func (mg *MailgunImpl) UpdateMember(s, l string, prototype Member) (Member, error) {
-	r := newHTTPRequest(generateMemberApiUrl(listsEndpoint, l) + "/" + s)
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, l) + "/" + s)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newFormDataPayload()
 	if prototype.Address != "" {
@@ -282,8 +325,8 @@ func (mg *MailgunImpl) UpdateMember(s, l string, prototype Member) (Member, erro
 
 // DeleteMember removes the member from the list.
 func (mg *MailgunImpl) DeleteMember(member, addr string) error {
-	r := newHTTPRequest(generateMemberApiUrl(listsEndpoint, addr) + "/" + member)
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, addr) + "/" + member)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	_, err := makeDeleteRequest(r)
 	return err
@@ -299,8 +342,8 @@ func (mg *MailgunImpl) DeleteMember(member, addr string) error {
 // Otherwise, each Member needs to have at least the Address field filled out.
 // Other fields are optional, but may be set according to your needs.
 func (mg *MailgunImpl) CreateMemberList(s *bool, addr string, newMembers []interface{}) error {
-	r := newHTTPRequest(generateMemberApiUrl(listsEndpoint, addr) + ".json")
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, addr) + ".json")
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newFormDataPayload()
 	if s != nil {
@@ -310,8 +353,303 @@ func (mg *MailgunImpl) CreateMemberList(s *bool, addr string, newMembers []inter
 	if err != nil {
 		return err
 	}
-	fmt.Println(string(bs))
 	p.addValue("members", string(bs))
 	_, err = makePostRequest(r, p)
 	return err
 }
+
+// ExportMailingList writes every member of the mailing list to w, in the given format (ExportCSV or ExportJSON).
+// Members are fetched a page at a time, and each page is written out before the next is fetched,
+// so that even large lists don't have to fit in memory.
+// If the client's logger is a ProgressLogger (see WithLogger), each page is reported to it.
+// Should an error occur part way through, w will hold only part of the list.
+func (mg *MailgunImpl) ExportMailingList(ctx context.Context, listAddress string, format string, w io.Writer) error {
+	var write func(Member) error
+	var finish func() error
+	switch format {
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"email", "name", "subscribed", "vars_json"}); err != nil {
+			return err
+		}
+		write = func(m Member) error {
+			vars, err := json.Marshal(m.Vars)
+			if err != nil {
+				return err
+			}
+			subscribed := ""
+			if m.Subscribed != nil {
+				subscribed = strconv.FormatBool(*m.Subscribed)
+			}
+			return cw.Write([]string{m.Address, m.Name, subscribed, string(vars)})
+		}
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case ExportJSON:
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
+		first := true
+		write = func(m Member) error {
+			b, err := json.Marshal(m)
+			if err != nil {
+				return err
+			}
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			_, err = w.Write(b)
+			return err
+		}
+		finish = func() error {
+			_, err := io.WriteString(w, "]\n")
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	exported := 0
+	for {
+		members, err := mg.getMembersPage(ctx, listAddress, exportPageSize, exported)
+		if err != nil {
+			return err
+		}
+		for _, m := range members {
+			if err := write(m); err != nil {
+				return err
+			}
+		}
+		exported += len(members)
+		mg.logProgress("exported %d members of %s", exported, listAddress)
+		if len(members) < exportPageSize {
+			break
+		}
+	}
+	return finish()
+}
+
+// getMembersPage fetches a single page of the mailing list's members.
+func (mg *MailgunImpl) getMembersPage(ctx context.Context, addr string, limit, skip int) ([]Member, error) {
+	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, addr))
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	r.addParameter("limit", strconv.Itoa(limit))
+	r.addParameter("skip", strconv.Itoa(skip))
+	var envelope struct {
+		Items []Member `json:"items"`
+	}
+	err := getResponseFromJSON(r, &envelope)
+	return envelope.Items, err
+}
+
+// ImportOptions controls how ImportMailingList adds members.
+// Upsert updates members already on the list; otherwise, they're left as they are.
+// BatchSize sets how many members to add per API call; it defaults to, and may not exceed, 1000.
+// OnError, if set, is called for every row which fails to import, with its row number and the reason.
+type ImportOptions struct {
+	Upsert    bool
+	BatchSize int
+	OnError   func(row int, err error)
+}
+
+// ImportResult reports the outcome of ImportMailingList.
+// Added counts the members new to the list, and Updated the existing members whose settings were replaced.
+// Uncounted counts the members of batches Mailgun accepted, but which couldn't be told apart into added and updated,
+// for want of the list's member count; these members were imported all the same.
+// Failed counts the rows which could not be imported; RowErrors gives the details of each.
+type ImportResult struct {
+	Added     int
+	Updated   int
+	Uncounted int
+	Failed    int
+	RowErrors []ImportRowError
+}
+
+// An ImportRowError explains why a row of an import failed.
+// Rows are numbered from 1, which is the header row.
+type ImportRowError struct {
+	Row int
+	Err error
+}
+
+// Error() describes the failure, along with the row it happened on.
+func (e ImportRowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+// ImportMailingList adds the members listed in CSV form by r to the mailing list.
+// The first row must name the columns.  An email column is required;
+// name and subscribed (true or false, or yes or no) columns are optional,
+// and any other columns become member variables.
+//
+// Invalid rows, and rows in batches Mailgun rejects, are skipped and reported in the result,
+// rather than stopping the import.  An error is returned only if the CSV has no email column,
+// if r cannot be read, or if the context is cancelled, in which case the result reports the work done so far.
+//
+// Mailgun does not report which members of a batch were new, so Added and Updated are derived
+// from the change in the list's member count; other changes to the list during the import skew them,
+// and members of batches for which the count can't be fetched are reported as Uncounted.
+func (mg *MailgunImpl) ImportMailingList(ctx context.Context, listAddress string, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 || batchSize > maxImportBatchSize {
+		batchSize = maxImportBatchSize
+	}
+	result := &ImportResult{}
+	fail := func(row int, err error) {
+		result.Failed++
+		result.RowErrors = append(result.RowErrors, ImportRowError{Row: row, Err: err})
+		if opts.OnError != nil {
+			opts.OnError(row, err)
+		}
+	}
+
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	emailColumn := -1
+	for i, name := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(name))
+		if header[i] == "email" {
+			emailColumn = i
+		}
+	}
+	if emailColumn < 0 {
+		return nil, fmt.Errorf("CSV has no email column")
+	}
+
+	var batch []Member
+	var rows []int
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		defer func() {
+			batch, rows = batch[:0], rows[:0]
+		}()
+		// The counts only serve to tell added members from updated ones;
+		// failing to fetch them doesn't stop the batch, nor undo it.
+		before, countErr := mg.getListMembersCount(ctx, listAddress)
+		if err := mg.addMembersBatch(ctx, listAddress, opts.Upsert, batch); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			for _, row := range rows {
+				fail(row, err)
+			}
+			return nil
+		}
+		after, err := mg.getListMembersCount(ctx, listAddress)
+		if countErr != nil || err != nil {
+			result.Uncounted += len(batch)
+			return ctx.Err()
+		}
+		added := after - before
+		result.Added += added
+		if opts.Upsert {
+			result.Updated += len(batch) - added
+		}
+		return nil
+	}
+
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); !ok {
+				return result, err
+			}
+			fail(row, err)
+			continue
+		}
+		m, err := memberFromRecord(header, emailColumn, record)
+		if err != nil {
+			fail(row, err)
+			continue
+		}
+		batch = append(batch, m)
+		rows = append(rows, row)
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// memberFromRecord interprets a row of an import according to its header.
+func memberFromRecord(header []string, emailColumn int, record []string) (Member, error) {
+	m := Member{Address: strings.TrimSpace(record[emailColumn])}
+	if m.Address == "" {
+		return Member{}, fmt.Errorf("no email address")
+	}
+	for i, value := range record {
+		switch header[i] {
+		case "email":
+		case "name":
+			m.Name = value
+		case "subscribed":
+			switch strings.ToLower(strings.TrimSpace(value)) {
+			case "":
+			case "true", "yes":
+				m.Subscribed = &yes
+			case "false", "no":
+				m.Subscribed = &no
+			default:
+				return Member{}, fmt.Errorf("invalid subscribed value %q", value)
+			}
+		default:
+			if m.Vars == nil {
+				m.Vars = make(map[string]interface{})
+			}
+			m.Vars[header[i]] = value
+		}
+	}
+	return m, nil
+}
+
+// addMembersBatch adds a batch of members to the mailing list in a single API call.
+func (mg *MailgunImpl) addMembersBatch(ctx context.Context, addr string, upsert bool, members []Member) error {
+	r := newHTTPRequest(generateMemberApiUrl(mg, listsEndpoint, addr) + ".json")
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	bs, err := json.Marshal(members)
+	if err != nil {
+		return err
+	}
+	p := newFormDataPayload()
+	p.addValue("upsert", yesNo(upsert))
+	p.addValue("members", string(bs))
+	_, err = makePostRequest(r, p)
+	return err
+}
+
+// getListMembersCount returns the number of members the mailing list has.
+func (mg *MailgunImpl) getListMembersCount(ctx context.Context, addr string) (int, error) {
+	r := newHTTPRequest(generatePublicApiUrl(mg, listsEndpoint) + "/" + addr)
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	var envelope struct {
+		List struct {
+			MembersCount int `json:"members_count"`
+		} `json:"list"`
+	}
+	err := getResponseFromJSON(r, &envelope)
+	return envelope.List.MembersCount, err
+}
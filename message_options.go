@@ -0,0 +1,146 @@
+package mailgun
+
+import "time"
+
+// A MessageOption configures a message as NewMessageWithOptions builds it.
+// Each option does the work of one of the message's setters, such as AddCC or SetHtml,
+// so that a fully configured message can be built in a single expression:
+//
+//	m := mg.NewMessageWithOptions("me@example.com", "Hello", "Hello world!", []string{"you@example.com"},
+//		mailgun.WithCC("boss@example.com"),
+//		mailgun.WithTag("welcome"),
+//		mailgun.WithTracking(true),
+//	)
+//
+// Options whose setters can fail, such as WithTag, don't fail the constructor;
+// Send refuses the message instead, giving the setter's error.
+type MessageOption func(*Message)
+
+// NewMessageWithOptions works as NewMessage, taking the To: recipients as a list,
+// then applies each of the options to the message in order.
+// The message's setters remain available for changes after the fact.
+func (mg *MailgunImpl) NewMessageWithOptions(from, subject, text string, to []string, opts ...MessageOption) *Message {
+	m := mg.NewMessage(from, subject, text, to...)
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// optionFailed records the first error of an option's setter, for Send to report.
+func (m *Message) optionFailed(err error) {
+	if err != nil && m.optionErr == nil {
+		m.optionErr = err
+	}
+}
+
+// WithCC adds Cc: recipients to the message, as AddCC does.
+func WithCC(recipients ...string) MessageOption {
+	return func(m *Message) {
+		for _, r := range recipients {
+			m.AddCC(r)
+		}
+	}
+}
+
+// WithBCC adds Bcc: recipients to the message, as AddBCC does.
+func WithBCC(recipients ...string) MessageOption {
+	return func(m *Message) {
+		for _, r := range recipients {
+			m.AddBCC(r)
+		}
+	}
+}
+
+// WithHtml gives the message an HTML body, as SetHtml does.
+func WithHtml(html string) MessageOption {
+	return func(m *Message) {
+		m.SetHtml(html)
+	}
+}
+
+// WithTag attaches tags to the message, as AddTag does.
+func WithTag(tags ...string) MessageOption {
+	return func(m *Message) {
+		for _, t := range tags {
+			m.optionFailed(m.AddTag(t))
+		}
+	}
+}
+
+// WithCampaign assigns the message to a campaign, as AddCampaign does.
+func WithCampaign(campaign string) MessageOption {
+	return func(m *Message) {
+		m.optionFailed(m.AddCampaign(campaign))
+	}
+}
+
+// WithHeader adds a custom MIME header to the message, as AddHeader does.
+func WithHeader(header, value string) MessageOption {
+	return func(m *Message) {
+		m.AddHeader(header, value)
+	}
+}
+
+// WithReplyTo sets the message's Reply-To: header, as SetReplyTo does.
+func WithReplyTo(email string) MessageOption {
+	return func(m *Message) {
+		m.SetReplyTo(email)
+	}
+}
+
+// WithVariable associates a variable with the message, as AddVariable does.
+func WithVariable(variable string, value interface{}) MessageOption {
+	return func(m *Message) {
+		m.optionFailed(m.AddVariable(variable, value))
+	}
+}
+
+// WithTemplate names the stored template to render the message from, as SetTemplate does.
+func WithTemplate(name string) MessageOption {
+	return func(m *Message) {
+		m.SetTemplate(name)
+	}
+}
+
+// WithDeliveryTime schedules the message for delivery at the given time, as SetDeliveryTime does.
+func WithDeliveryTime(dt time.Time) MessageOption {
+	return func(m *Message) {
+		m.SetDeliveryTime(dt)
+	}
+}
+
+// WithTracking turns tracking on or off for the message, as SetTracking does.
+func WithTracking(tracking bool) MessageOption {
+	return func(m *Message) {
+		m.SetTracking(tracking)
+	}
+}
+
+// WithTrackingClicks turns click tracking on or off for the message, as SetTrackingClicks does.
+func WithTrackingClicks(trackingClicks bool) MessageOption {
+	return func(m *Message) {
+		m.SetTrackingClicks(trackingClicks)
+	}
+}
+
+// WithTrackingOpens turns open tracking on or off for the message, as SetTrackingOpens does.
+func WithTrackingOpens(trackingOpens bool) MessageOption {
+	return func(m *Message) {
+		m.SetTrackingOpens(trackingOpens)
+	}
+}
+
+// WithDKIM turns DKIM signing on or off for the message, as SetDKIM does.
+func WithDKIM(dkim bool) MessageOption {
+	return func(m *Message) {
+		m.SetDKIM(dkim)
+	}
+}
+
+// WithTestMode sends the message in test mode, as EnableTestMode does.
+func WithTestMode() MessageOption {
+	return func(m *Message) {
+		m.EnableTestMode()
+	}
+}
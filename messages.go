@@ -1,16 +1,123 @@
 package mailgun
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 // MaxNumberOfRecipients represents the largest batch of recipients that Mailgun can support in a single API call.
 // This figure includes To:, Cc:, Bcc:, etc. recipients.
 const MaxNumberOfRecipients = 1000
 
+// MaxNumberOfTags represents the most tags Mailgun records for a single message.
+const MaxNumberOfTags = 3
+
+// MaxNumberOfCampaigns represents the most campaigns a single message may belong to.
+const MaxNumberOfCampaigns = 3
+
+// idempotencyKeyHeader names the header marking a send with its idempotency key; see SendWithIdempotencyKey.
+const idempotencyKeyHeader = "X-Idempotency-Key"
+
+// These errors explain why Send refuses to send a message.
+var (
+	ErrNilMessage              = errors.New("no message given")
+	ErrNoSender                = errors.New("message has no sender")
+	ErrNoRecipients            = errors.New("message has no recipients")
+	ErrEmptyRecipient          = errors.New("message has an empty recipient address")
+	ErrNoBody                  = errors.New("message has no text body")
+	ErrEmptyTag                = errors.New("message has an empty tag")
+	ErrEmptyCampaign           = errors.New("message has an empty campaign ID")
+	ErrTooManyCampaigns        = errors.New("message belongs to more than three campaigns")
+	ErrStrayRecipientVariables = errors.New("message has recipient variables for an address it isn't sent to")
+	ErrNoMIMEBody              = errors.New("MIME message has no body")
+	ErrInvalidReplyTo          = errors.New("message has a malformed Reply-To: address")
+	ErrTooManyRecipients       = errors.New("message has more recipients than Mailgun accepts at once")
+	ErrTooManyTags             = errors.New("message has more tags than Mailgun records")
+	ErrAMPWithoutHTML          = errors.New("message has an AMP HTML body but no HTML fallback")
+	ErrInvalidMessageTTL       = errors.New("message has a time-to-live of zero or less")
+	ErrInvalidSendingIP        = errors.New("message has a malformed sending IP address")
+	ErrInvalidEnvelopeSender   = errors.New("message has a malformed envelope sender address")
+	ErrInvalidListUnsubscribe  = errors.New("message has neither a mailto: nor an https:// unsubscribe link")
+	ErrInvalidVariableJSON     = errors.New("message variable is not valid JSON")
+	ErrInvalidMessageID        = errors.New("message has a Message-Id not of the form <id@domain>")
+	ErrInvalidDeliveryTime     = errors.New("message has a delivery time not in RFC-2822 form")
+	ErrInvalidAddress          = errors.New("message has a malformed address")
+)
+
+// A TooManyRecipientsError reports how many recipients a message had, when Send refuses it for having
+// more than MaxNumberOfRecipients.  It matches ErrTooManyRecipients under errors.Is.
+type TooManyRecipientsError struct {
+	Count int
+}
+
+// Error() gives the count, along with the limit.
+func (e *TooManyRecipientsError) Error() string {
+	return fmt.Sprintf("%v: %d, but at most %d", ErrTooManyRecipients, e.Count, MaxNumberOfRecipients)
+}
+
+// Is() matches ErrTooManyRecipients.
+func (e *TooManyRecipientsError) Is(target error) bool {
+	return target == ErrTooManyRecipients
+}
+
+// An InvalidAddressError names the address, and the field it was given for, such as "to" or "from",
+// when Send refuses a message for having an address ParseAddress can't make sense of.
+// It matches ErrInvalidAddress under errors.Is.
+type InvalidAddressError struct {
+	Field   string
+	Address string
+	Err     error
+}
+
+func (e *InvalidAddressError) Error() string {
+	return fmt.Sprintf("%v in %s: %q: %v", ErrInvalidAddress, e.Field, e.Address, e.Err)
+}
+
+func (e *InvalidAddressError) Is(target error) bool {
+	return target == ErrInvalidAddress
+}
+
+// validateAddresses checks that every one of a list of addresses, given for the named field, can be parsed.
+func validateAddresses(field string, list []string) error {
+	for _, addr := range list {
+		if _, _, err := ParseAddress(addr); err != nil {
+			return &InvalidAddressError{Field: field, Address: addr, Err: err}
+		}
+	}
+	return nil
+}
+
+// An InvalidVariableJSONError names the message variable whose value isn't valid JSON, when Send refuses a message
+// for carrying one.  It matches ErrInvalidVariableJSON under errors.Is.
+type InvalidVariableJSONError struct {
+	Variable string
+}
+
+// Error() names the variable.
+func (e *InvalidVariableJSONError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrInvalidVariableJSON, e.Variable)
+}
+
+// Is() matches ErrInvalidVariableJSON.
+func (e *InvalidVariableJSONError) Is(target error) bool {
+	return target == ErrInvalidVariableJSON
+}
+
 // Message structures contain both the message text and the envelop for an e-mail message.
 type Message struct {
 	to                []string
@@ -18,30 +125,49 @@ type Message struct {
 	campaigns         []string
 	dkim              bool
 	deliveryTime      *time.Time
+	deliveryTimeText  string
+	messageTTL        *int
 	attachments       []string
 	readerAttachments []ReaderAttachment
+	readerInlines     []ReaderAttachment
 	inlines           []string
 
 	testMode           bool
 	tracking           bool
-	trackingClicks     bool
+	trackingClicks     TrackingClicksMode
 	trackingOpens      bool
+	requireTLS         bool
+	skipVerification   bool
+	sendingIP          string
 	headers            map[string]string
+	replyTo            string
+	envelopeSender     string
+	customMessageID    string
 	variables          map[string]string
 	recipientVariables map[string]map[string]interface{}
+	recipientCCs       map[string][]string
 
 	dkimSet           bool
 	trackingSet       bool
 	trackingClicksSet bool
 	trackingOpensSet  bool
 
+	requireTLSSet       bool
+	skipVerificationSet bool
+
 	specific features
 	mg       Mailgun
+
+	// optionErr holds the first error of the MessageOptions the message was built with.
+	optionErr error
 }
 
+// ReaderAttachment describes a file to attach to a message, whose contents come from ReadCloser.
+// ContentType gives the MIME type of the file; if empty, application/octet-stream is assumed.
 type ReaderAttachment struct {
-	Filename   string
-	ReadCloser io.ReadCloser
+	Filename    string
+	ReadCloser  io.ReadCloser
+	ContentType string
 }
 
 // StoredMessage structures contain the (parsed) message content for an email
@@ -51,11 +177,14 @@ type ReaderAttachment struct {
 // Each pair consists of a name [0] and value [1].  Array notation is used instead of a map
 // because that's how it's sent over the wire, and it's how encoding/json expects this field
 // to be.
+// BodyMime holds the message in full, but only in messages fetched with the Accept header GetStoredMessageRaw sends;
+// other messages leave it empty.
 type StoredMessage struct {
 	Recipients        string                 `json:"recipients"`
 	Sender            string                 `json:"sender"`
 	From              string                 `json:"from"`
 	Subject           string                 `json:"subject"`
+	BodyMime          string                 `json:"body-mime"`
 	BodyPlain         string                 `json:"body-plain"`
 	StrippedText      string                 `json:"stripped-text"`
 	StrippedSignature string                 `json:"stripped-signature"`
@@ -94,6 +223,10 @@ type plainMessage struct {
 	subject string
 	text    string
 	html    string
+	ampHtml string
+
+	template        string
+	templateVersion string
 }
 
 // mimeMessage contains fields relevant to pre-packaged MIME messages.
@@ -107,19 +240,31 @@ type sendMessageResponse struct {
 }
 
 // features abstracts the common characteristics between regular and MIME messages.
-// addCC, addBCC, recipientCount, and setHTML are invoked via the package-global AddCC, AddBCC,
-// RecipientCount, and SetHtml calls, as these functions are ignored for MIME messages.
+// addCC, addBCC, recipientCount, isCopied, setHTML, setAMPHtml, setTemplate, setTemplateVersion, setContent, content,
+// setSubject, and envelope are invoked via the package-global AddCC, AddBCC, RecipientCount, SetHtml, SetAMPHtml,
+// SetTemplate, SetTemplateVersion, SetContent, GetContent, SetSubject, GetSubject, and GetFrom calls,
+// as these functions are ignored for MIME messages.
 // Send() invokes addValues to add message-type-specific MIME headers for the API call
-// to Mailgun.  isValid yeilds true if and only if the message is valid enough for sending
-// through the API.  Finally, endpoint() tells Send() which endpoint to use to submit the API call.
+// to Mailgun.  validate yields nil if and only if the message is valid enough for sending
+// through the API, and otherwise explains the problem.  endpoint() tells Send() which endpoint to use to submit the API call.
+// Finally, clone copies the message-type-specific fields on behalf of Clone.
 type features interface {
 	addCC(string)
 	addBCC(string)
 	setHtml(string)
+	setAMPHtml(string)
+	setTemplate(string)
+	setTemplateVersion(string)
+	setContent(text, html string) error
+	content() (text, html string)
+	setSubject(string)
+	envelope() (from, subject string)
 	addValues(*formDataPayload)
-	isValid() bool
+	validate() error
 	endpoint() string
 	recipientCount() int
+	isCopied(address string) bool
+	clone() features
 }
 
 // NewMessage returns a new e-mail message with the simplest envelop needed to send.
@@ -167,6 +312,32 @@ func (mg *MailgunImpl) NewMessage(from, subject, text string, to ...string) *Mes
 	}
 }
 
+// NewHTMLMessage works as NewMessage, but gives the message an HTML body as well as a plain-text fallback.
+// Both bodies are required, as SetContent requires them; Send refuses a message made with either blank.
+//
+// DEPRECATED, like NewMessage.
+// Use Mailgun.NewHTMLMessage() instead.
+func NewHTMLMessage(from, subject, html, text string, to ...string) *Message {
+	m := NewMessage(from, subject, text, to...)
+	m.setHTMLBody(html, text)
+	return m
+}
+
+// NewHTMLMessage works as NewMessage, but gives the message an HTML body as well as a plain-text fallback,
+// sparing the separate call to SetHtml.
+// Both bodies are required, as SetContent requires them; Send refuses a message made with either blank,
+// giving SetContent's error.  Checks on the rest of the message wait until it's sent, as for any other message.
+func (mg *MailgunImpl) NewHTMLMessage(from, subject, html, text string, to ...string) *Message {
+	m := mg.NewMessage(from, subject, text, to...)
+	m.setHTMLBody(html, text)
+	return m
+}
+
+// setHTMLBody completes a message made by NewHTMLMessage, recording any problem with the bodies for Send to report.
+func (m *Message) setHTMLBody(html, text string) {
+	m.optionFailed(m.SetContent(text, html))
+}
+
 // NewMIMEMessage creates a new MIME message.  These messages are largely canned;
 // you do not need to invoke setters to set message-related headers.
 // However, you do still need to call setters for Mailgun-specific settings.
@@ -212,16 +383,131 @@ func (mg *MailgunImpl) NewMIMEMessage(body io.ReadCloser, to ...string) *Message
 	}
 }
 
+// Clone returns a copy of the message, which may be changed and sent without affecting the original:
+// recipients, tags, campaigns, headers, variables, attachments, and the like are all copied.
+// This suits sending variations of a message, to different recipients or with different tags, say.
+//
+// File contents given as readers, whether to AddReaderAttachment, AddBufferAttachment, and the like,
+// or as the body of a MIME message, are shared rather than copied, as a reader can be read only once;
+// send only one of the messages sharing them.
+func (m *Message) Clone() *Message {
+	c := *m
+	c.to = copyStrings(m.to)
+	c.tags = copyStrings(m.tags)
+	c.campaigns = copyStrings(m.campaigns)
+	c.attachments = copyStrings(m.attachments)
+	c.inlines = copyStrings(m.inlines)
+	c.readerAttachments = append([]ReaderAttachment(nil), m.readerAttachments...)
+	c.readerInlines = append([]ReaderAttachment(nil), m.readerInlines...)
+	if m.deliveryTime != nil {
+		dt := *m.deliveryTime
+		c.deliveryTime = &dt
+	}
+	if m.messageTTL != nil {
+		ttl := *m.messageTTL
+		c.messageTTL = &ttl
+	}
+	if m.headers != nil {
+		c.headers = make(map[string]string, len(m.headers))
+		for k, v := range m.headers {
+			c.headers[k] = v
+		}
+	}
+	if m.variables != nil {
+		c.variables = make(map[string]string, len(m.variables))
+		for k, v := range m.variables {
+			c.variables[k] = v
+		}
+	}
+	if m.recipientVariables != nil {
+		c.recipientVariables = make(map[string]map[string]interface{}, len(m.recipientVariables))
+		for recipient, vars := range m.recipientVariables {
+			c.recipientVariables[recipient] = make(map[string]interface{}, len(vars))
+			for k, v := range vars {
+				c.recipientVariables[recipient][k] = v
+			}
+		}
+	}
+	if m.recipientCCs != nil {
+		c.recipientCCs = make(map[string][]string, len(m.recipientCCs))
+		for recipient, ccs := range m.recipientCCs {
+			c.recipientCCs[recipient] = copyStrings(ccs)
+		}
+	}
+	c.specific = m.specific.clone()
+	return &c
+}
+
+func (pm *plainMessage) clone() features {
+	c := *pm
+	c.cc = copyStrings(pm.cc)
+	c.bcc = copyStrings(pm.bcc)
+	return &c
+}
+
+func (mm *mimeMessage) clone() features {
+	c := *mm
+	return &c
+}
+
+// copyStrings returns a copy of the list, which may be appended to or changed without affecting the original.
+func copyStrings(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(list)), list...)
+}
+
 // AddReaderAttachment arranges to send a file along with the e-mail message.
-// File contents are read from a io.ReadCloser.
+// File contents are read from an io.Reader, and streamed to Mailgun as the message is sent.
 // The filename parameter is the resulting filename of the attachment.
-// The readCloser parameter is the io.ReadCloser which reads the actual bytes to be used
-// as the contents of the attached file.
-func (m *Message) AddReaderAttachment(filename string, readCloser io.ReadCloser) {
-	ra := ReaderAttachment{Filename: filename, ReadCloser: readCloser}
+// The r parameter reads the actual bytes to be used as the contents of the attached file.
+// If r is also an io.Closer, it's closed once read.
+func (m *Message) AddReaderAttachment(filename string, r io.Reader) {
+	m.readerAttachments = append(m.readerAttachments, newReaderAttachment(filename, r))
+}
+
+// AddReaderInline works as AddReaderAttachment, but sends the file inline, as AddInline does.
+func (m *Message) AddReaderInline(filename string, r io.Reader) {
+	m.readerInlines = append(m.readerInlines, newReaderAttachment(filename, r))
+}
+
+// AddBufferAttachment works as AddReaderAttachment, but takes the contents of the file from memory.
+// It's AddAttachmentFromBytes with no MIME type given; prefer AddAttachmentFromBytes when the type is known.
+func (m *Message) AddBufferAttachment(filename string, data []byte) {
+	m.AddAttachmentFromBytes(filename, "", data)
+}
+
+// AddBufferInline works as AddReaderInline, but takes the contents of the file from memory.
+// It's AddInlineFromBytes with no MIME type given; prefer AddInlineFromBytes when the type is known.
+func (m *Message) AddBufferInline(filename string, data []byte) {
+	m.AddInlineFromBytes(filename, "", data)
+}
+
+// AddAttachmentFromBytes attaches a file whose contents are in memory, giving also its MIME type,
+// such as application/pdf for a report rendered in memory; if empty, application/octet-stream is assumed.
+// The data is sent as it stands when the message is sent, not copied, so leave it unchanged until then.
+func (m *Message) AddAttachmentFromBytes(filename, contentType string, data []byte) {
+	ra := newReaderAttachment(filename, bytes.NewReader(data))
+	ra.ContentType = contentType
 	m.readerAttachments = append(m.readerAttachments, ra)
 }
 
+// AddInlineFromBytes works as AddAttachmentFromBytes, but sends the file inline, as AddInline does.
+func (m *Message) AddInlineFromBytes(filename, contentType string, data []byte) {
+	ra := newReaderAttachment(filename, bytes.NewReader(data))
+	ra.ContentType = contentType
+	m.readerInlines = append(m.readerInlines, ra)
+}
+
+func newReaderAttachment(filename string, r io.Reader) ReaderAttachment {
+	rc, ok := r.(io.ReadCloser)
+	if !ok {
+		rc = ioutil.NopCloser(r)
+	}
+	return ReaderAttachment{Filename: filename, ReadCloser: rc}
+}
+
 // AddAttachment arranges to send a file from the filesystem along with the e-mail message.
 // The attachment parameter is a filename, which must refer to a file which actually resides
 // in the local filesystem.
@@ -261,6 +547,7 @@ func (m *Message) AddRecipientAndVariables(r string, vars map[string]interface{}
 		}
 		m.to = make([]string, len(m.to))
 		m.recipientVariables = make(map[string]map[string]interface{}, len(m.recipientVariables))
+		m.recipientCCs = nil
 	}
 	m.to = append(m.to, r)
 	if vars != nil {
@@ -272,6 +559,30 @@ func (m *Message) AddRecipientAndVariables(r string, vars map[string]interface{}
 	return nil
 }
 
+// AddRecipientVariable sets a variable for a single recipient of the message,
+// which Mailgun substitutes for %recipient.name% in the body sent to that recipient.
+// The recipient must be among the message's To:, Cc:, or Bcc: recipients by the time it's sent;
+// it needn't be added before its variables are.
+// The value must marshal as JSON.
+func (m *Message) AddRecipientVariable(recipient, name string, value interface{}) error {
+	if recipient == "" || name == "" {
+		return errors.New("recipient variables need both a recipient and a name")
+	}
+	if _, err := json.Marshal(value); err != nil {
+		return err
+	}
+	if m.recipientVariables == nil {
+		m.recipientVariables = make(map[string]map[string]interface{})
+	}
+	vars := m.recipientVariables[recipient]
+	if vars == nil {
+		vars = make(map[string]interface{})
+		m.recipientVariables[recipient] = vars
+	}
+	vars[name] = value
+	return nil
+}
+
 // RecipientCount returns the total number of recipients for the message.
 // This includes To:, Cc:, and Bcc: fields.
 //
@@ -291,12 +602,17 @@ func (pm *plainMessage) recipientCount() int {
 	return len(pm.bcc) + len(pm.cc)
 }
 
+// isCopied reports whether the address appears among the Cc: or Bcc: recipients.
+func (pm *plainMessage) isCopied(address string) bool {
+	return containsString(pm.cc, address) || containsString(pm.bcc, address)
+}
+
 func (mm *mimeMessage) recipientCount() int {
 	return 10
 }
 
 func (m *Message) send() (string, string, error) {
-	return m.mg.Send(m)
+	return m.mg.Send(context.Background(), m)
 }
 
 // AddCC appends a receiver to the carbon-copy header of a message.
@@ -310,6 +626,40 @@ func (pm *plainMessage) addCC(r string) {
 
 func (mm *mimeMessage) addCC(_ string) {}
 
+// SetRecipientCC arranges for the given addresses to receive a copy of the message sent to the primary recipient,
+// without copying them on what everyone else receives, as AddCC would.
+// The primary recipient must already be a To: recipient of the message.
+// Calling SetRecipientCC again for the same recipient replaces their CCs; passing no CCs removes them.
+//
+// Mailgun cannot vary the Cc: header by recipient, so Send splits the message into one send for each
+// recipient with CCs of their own, plus one for all the rest.
+// Any CCs and BCCs set with AddCC and AddBCC receive a copy from each of these sends.
+// MIME messages don't support CCs, and so SetRecipientCC returns an error for them.
+func (m *Message) SetRecipientCC(primaryEmail string, ccEmails ...string) error {
+	if _, ok := m.specific.(*mimeMessage); ok {
+		return fmt.Errorf("per-recipient CCs are not supported for MIME messages")
+	}
+	found := false
+	for _, to := range m.to {
+		if to == primaryEmail {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s is not a recipient of the message", primaryEmail)
+	}
+	if len(ccEmails) == 0 {
+		delete(m.recipientCCs, primaryEmail)
+		return nil
+	}
+	if m.recipientCCs == nil {
+		m.recipientCCs = make(map[string][]string)
+	}
+	m.recipientCCs[primaryEmail] = ccEmails
+	return nil
+}
+
 // AddBCC appends a receiver to the blind-carbon-copy header of a message.
 func (m *Message) AddBCC(recipient string) {
 	m.specific.addBCC(recipient)
@@ -333,15 +683,183 @@ func (pm *plainMessage) setHtml(h string) {
 
 func (mm *mimeMessage) setHtml(_ string) {}
 
+// SetAMPHtml bundles an AMP for Email version of the message, for the mail clients which render it,
+// alongside the HTML and plain-text bodies, which other clients fall back on.
+// Gmail, for one, shows the AMP version only if an HTML body comes with it,
+// so Send refuses a message with an AMP body but no HTML body, giving ErrAMPWithoutHTML.
+//
+// Mail clients impose their own content policies on AMP bodies:
+// the markup must be valid AMP4EMAIL, using only the components AMP for Email allows,
+// and senders must register with each provider, Gmail included, before their AMP bodies are shown.
+// Mailgun passes the body on as given, without checking it.
+// Like SetHtml, SetAMPHtml does nothing for MIME messages.
+func (m *Message) SetAMPHtml(ampHtml string) {
+	m.specific.setAMPHtml(ampHtml)
+}
+
+func (pm *plainMessage) setAMPHtml(h string) {
+	pm.ampHtml = h
+}
+
+func (mm *mimeMessage) setAMPHtml(_ string) {}
+
+// SetTemplate has Mailgun render the message's bodies from the named template, stored with CreateTemplate,
+// using the message's variables (see AddVariable) to fill it in.
+// A message with a template needs no text body of its own; any bodies it has take precedence over the template's.
+// Like SetHtml, SetTemplate does nothing for MIME messages.
+func (m *Message) SetTemplate(name string) {
+	m.specific.setTemplate(name)
+}
+
+// SetTemplateVersion picks the version, by tag, of the template given to SetTemplate;
+// otherwise, Mailgun uses the template's active version.
+func (m *Message) SetTemplateVersion(tag string) {
+	m.specific.setTemplateVersion(tag)
+}
+
+func (pm *plainMessage) setTemplate(name string) {
+	pm.template = name
+}
+
+func (pm *plainMessage) setTemplateVersion(tag string) {
+	pm.templateVersion = tag
+}
+
+func (mm *mimeMessage) setTemplate(_ string) {}
+
+func (mm *mimeMessage) setTemplateVersion(_ string) {}
+
+// SetContent replaces both the plain-text and HTML bodies of the message,
+// which Mailgun sends as the alternative parts of a multipart/alternative body.
+// As recipients should always be able to choose between the two, both are required;
+// HTML without a plain-text fallback also fares worse with spam filters.
+// An error results if either is blank, or if the message is already MIME encoded;
+// in either case, the message remains unchanged.
+func (m *Message) SetContent(text, html string) error {
+	if strings.TrimSpace(text) == "" || strings.TrimSpace(html) == "" {
+		return fmt.Errorf("both text and HTML bodies are required")
+	}
+	return m.specific.setContent(text, html)
+}
+
+func (pm *plainMessage) setContent(text, html string) error {
+	pm.text = text
+	pm.html = html
+	return nil
+}
+
+func (mm *mimeMessage) setContent(_, _ string) error {
+	return fmt.Errorf("cannot set the content of a MIME message")
+}
+
+// GetContent returns the plain-text and HTML bodies of the message.
+// Both are blank for MIME messages.
+func (m *Message) GetContent() (text, html string) {
+	return m.specific.content()
+}
+
+func (pm *plainMessage) content() (text, html string) {
+	return pm.text, pm.html
+}
+
+func (mm *mimeMessage) content() (text, html string) {
+	return "", ""
+}
+
+// GetText returns the plain-text body of the message, or a blank for MIME messages.
+func (m *Message) GetText() string {
+	text, _ := m.specific.content()
+	return text
+}
+
+// GetHtml returns the HTML body of the message, or a blank for MIME messages.
+func (m *Message) GetHtml() string {
+	_, html := m.specific.content()
+	return html
+}
+
+// SetSubject replaces the subject the message was created with,
+// as when sending clones of a message with subjects personalised for each group of recipients.
+// It has no effect on MIME messages, whose subject is part of their body.
+func (m *Message) SetSubject(subject string) {
+	m.specific.setSubject(subject)
+}
+
+func (pm *plainMessage) setSubject(subject string) {
+	pm.subject = subject
+}
+
+func (mm *mimeMessage) setSubject(_ string) {}
+
+// GetSubject returns the subject of the message, or a blank for MIME messages.
+func (m *Message) GetSubject() string {
+	_, subject := m.specific.envelope()
+	return subject
+}
+
+// GetFrom returns the sender of the message, or a blank for MIME messages.
+func (m *Message) GetFrom() string {
+	from, _ := m.specific.envelope()
+	return from
+}
+
+func (pm *plainMessage) envelope() (from, subject string) {
+	return pm.from, pm.subject
+}
+
+func (mm *mimeMessage) envelope() (from, subject string) {
+	return "", ""
+}
+
+// Lint returns warnings about parts of the message which, though Send accepts them, harm its deliverability
+// or its readers' experience, such as an HTML body with no plain-text alternative.
+// It returns nil if it finds nothing to warn about; MIME messages, whose bodies it doesn't inspect, always pass.
+func (m *Message) Lint() []string {
+	if _, ok := m.specific.(*mimeMessage); ok {
+		return nil
+	}
+	var warnings []string
+	text, html := m.specific.content()
+	if strings.TrimSpace(html) != "" && strings.TrimSpace(text) == "" {
+		warnings = append(warnings, "message has an HTML body but no plain-text alternative; use SetContent to give both")
+	}
+	if _, subject := m.specific.envelope(); strings.TrimSpace(subject) == "" {
+		warnings = append(warnings, "message has no subject")
+	}
+	return warnings
+}
+
 // AddTag attaches a tag to the message.  Tags are useful for metrics gathering and event tracking purposes.
+// Tags differing only in case count as the same tag, and adding a tag again has no effect.
+// Mailgun records at most MaxNumberOfTags tags per message, and ignores the rest;
+// rather than let that happen silently, AddTag refuses further tags with ErrTooManyTags.
 // Refer to the Mailgun documentation for further details.
-func (m *Message) AddTag(tag string) {
+func (m *Message) AddTag(tag string) error {
+	for _, t := range m.tags {
+		if strings.EqualFold(t, tag) {
+			return nil
+		}
+	}
+	if len(m.tags) >= MaxNumberOfTags {
+		return ErrTooManyTags
+	}
 	m.tags = append(m.tags, tag)
+	return nil
 }
 
 // This feature is deprecated for new software.
-func (m *Message) AddCampaign(campaign string) {
+//
+// A message belongs to at most MaxNumberOfCampaigns campaigns; AddCampaign refuses further campaigns
+// with ErrTooManyCampaigns.  Adding a campaign again has no effect.
+func (m *Message) AddCampaign(campaign string) error {
+	if containsString(m.campaigns, campaign) {
+		return nil
+	}
+	if len(m.campaigns) >= MaxNumberOfCampaigns {
+		return ErrTooManyCampaigns
+	}
 	m.campaigns = append(m.campaigns, campaign)
+	return nil
 }
 
 // SetDKIM arranges to send the o:dkim header with the message, and sets its value accordingly.
@@ -358,14 +876,42 @@ func (m *Message) EnableTestMode() {
 }
 
 // SetDeliveryTime schedules the message for transmission at the indicated time.
-// Pass nil to remove any installed schedule.
+// The time goes to Mailgun in its own time zone, so that, for example, "9am in Tokyo" stays so.
+// Pass the zero time, or call ClearDeliveryTime, to remove any installed schedule.
 // Refer to the Mailgun documentation for more information.
 func (m *Message) SetDeliveryTime(dt time.Time) {
+	m.deliveryTimeText = ""
+	if dt.IsZero() {
+		m.ClearDeliveryTime()
+		return
+	}
 	pdt := new(time.Time)
 	*pdt = dt
 	m.deliveryTime = pdt
 }
 
+// SetDeliveryTimeRFC2822 works as SetDeliveryTime, but takes the time already formatted as RFC 2822 requires,
+// as in "Mon, 02 Jan 2006 15:04:05 -0700", and passes it to Mailgun exactly as given.
+// It refuses a time in any other form with ErrInvalidDeliveryTime, leaving the message unchanged.
+func (m *Message) SetDeliveryTimeRFC2822(value string) error {
+	dt, err := time.Parse(time.RFC1123Z, value)
+	if err != nil {
+		// RFC 2822 lets the day of the month go without a leading zero, as Mailgun writes it.
+		if dt, err = time.Parse("Mon, 2 Jan 2006 15:04:05 -0700", value); err != nil {
+			return ErrInvalidDeliveryTime
+		}
+	}
+	m.SetDeliveryTime(dt)
+	m.deliveryTimeText = value
+	return nil
+}
+
+// ClearDeliveryTime removes any schedule installed by SetDeliveryTime, so that the message goes out as soon as it's sent.
+func (m *Message) ClearDeliveryTime() {
+	m.deliveryTime = nil
+	m.deliveryTimeText = ""
+}
+
 // SetTracking sets the o:tracking message parameter to adjust, on a message-by-message basis,
 // whether or not Mailgun will rewrite URLs to facilitate event tracking.
 // Events tracked includes opens, clicks, unsubscribes, etc.
@@ -379,17 +925,87 @@ func (m *Message) SetTracking(tracking bool) {
 }
 
 // Refer to the Mailgun documentation for more information.
+// To track clicks in the HTML part of the message only, use SetTrackingClicksMode instead.
 func (m *Message) SetTrackingClicks(trackingClicks bool) {
-	m.trackingClicks = trackingClicks
+	if trackingClicks {
+		m.SetTrackingClicksMode(TrackingClicksYes)
+	} else {
+		m.SetTrackingClicksMode(TrackingClicksNo)
+	}
+}
+
+// A TrackingClicksMode chooses which parts of a message Mailgun rewrites links in, to track clicks.
+type TrackingClicksMode string
+
+// TrackingClicksYes tracks clicks in every part of the message, TrackingClicksNo in none,
+// and TrackingClicksHTMLOnly in the HTML part only, leaving links in the text part untouched.
+const (
+	TrackingClicksYes      TrackingClicksMode = "yes"
+	TrackingClicksNo       TrackingClicksMode = "no"
+	TrackingClicksHTMLOnly TrackingClicksMode = "htmlonly"
+)
+
+// SetTrackingClicksMode works as SetTrackingClicks, but can also limit click tracking to the HTML part of the message.
+func (m *Message) SetTrackingClicksMode(mode TrackingClicksMode) {
+	m.trackingClicks = mode
 	m.trackingClicksSet = true
 }
 
+// SetTrackingOpens sets the o:tracking-opens message parameter,
+// which decides whether Mailgun adds a tracking pixel to the HTML part of the message to track opens.
+// Open and click tracking are independent of each other:
+// SetTrackingOpens(false) with SetTrackingClicksMode(TrackingClicksHTMLOnly), for instance,
+// rewrites links in the HTML part without adding the pixel.
 // Refer to the Mailgun documentation for more information.
 func (m *Message) SetTrackingOpens(trackingOpens bool) {
 	m.trackingOpens = trackingOpens
 	m.trackingOpensSet = true
 }
 
+// SetTrackingPixelOnly tracks opens, with the tracking pixel, but leaves every link of the message as written.
+// It comes to SetTrackingOpens(true) with SetTrackingClicks(false), overriding the domain's tracking settings.
+func (m *Message) SetTrackingPixelOnly() {
+	m.SetTrackingOpens(true)
+	m.SetTrackingClicks(false)
+}
+
+// SetRequireTLS sets the o:require-tls message parameter.
+// If true, Mailgun delivers the message only over a TLS connection, and gives up rather than fall back to plain text.
+// Refer to the Mailgun documentation for more information.
+func (m *Message) SetRequireTLS(v bool) {
+	m.requireTLS = v
+	m.requireTLSSet = true
+}
+
+// SetSkipVerification sets the o:skip-verification message parameter.
+// If true, Mailgun accepts recipients' TLS certificates without verifying them.
+// Leave it false where delivery must go only to servers with valid certificates.
+// Refer to the Mailgun documentation for more information.
+func (m *Message) SetSkipVerification(v bool) {
+	m.skipVerification = v
+	m.skipVerificationSet = true
+}
+
+// SetMessageTTL sets the o:message-ttl message parameter,
+// giving up on delivery of the message, rather than keep retrying, once ttl passes from the time Mailgun accepts it.
+// This suits time-sensitive mail, such as one-time passwords, which is worse than useless delivered late.
+// Mailgun counts whole seconds; a ttl with a fraction of a second left over counts up to the next second.
+// Send refuses a message with a ttl of zero or less, giving ErrInvalidMessageTTL.
+func (m *Message) SetMessageTTL(ttl time.Duration) {
+	seconds := int(ttl / time.Second)
+	if ttl > 0 && ttl%time.Second != 0 {
+		seconds++
+	}
+	m.messageTTL = &seconds
+}
+
+// SetSendingIP sets the o:sending-ip message parameter, having Mailgun send the message from the given address,
+// which must be one of the account's dedicated IPs (see GetIPs).
+// Send refuses a message whose address is neither a valid IPv4 nor IPv6 address, giving ErrInvalidSendingIP.
+func (m *Message) SetSendingIP(ip string) {
+	m.sendingIP = ip
+}
+
 // AddHeader allows you to send custom MIME headers with the message.
 func (m *Message) AddHeader(header, value string) {
 	if m.headers == nil {
@@ -398,6 +1014,107 @@ func (m *Message) AddHeader(header, value string) {
 	m.headers[header] = value
 }
 
+// SetListUnsubscribe sets the List-Unsubscribe: header of the message (RFC 2369),
+// which lets mail clients offer recipients an unsubscribe button of their own.
+// The header lists the mailto address, with or without its mailto: scheme, and the HTTP URL, whichever are given.
+// Given an HTTP URL, it also sets List-Unsubscribe-Post: List-Unsubscribe=One-Click (RFC 8058),
+// promising that a POST to the URL unsubscribes the recipient without further ado.
+// It returns ErrInvalidListUnsubscribe, leaving the message unchanged,
+// if both are blank, or the HTTP URL doesn't begin with https://.
+func (m *Message) SetListUnsubscribe(mailto, httpURL string) error {
+	if mailto == "" && httpURL == "" {
+		return ErrInvalidListUnsubscribe
+	}
+	if httpURL != "" && !strings.HasPrefix(httpURL, "https://") {
+		return ErrInvalidListUnsubscribe
+	}
+	var links []string
+	if mailto != "" {
+		if !strings.HasPrefix(strings.ToLower(mailto), "mailto:") {
+			mailto = "mailto:" + mailto
+		}
+		links = append(links, "<"+mailto+">")
+	}
+	if httpURL != "" {
+		links = append(links, "<"+httpURL+">")
+		m.AddHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	} else {
+		delete(m.headers, "List-Unsubscribe-Post")
+	}
+	m.AddHeader("List-Unsubscribe", strings.Join(links, ", "))
+	return nil
+}
+
+// SetReplyTo sets the Reply-To: header of the message, directing replies to an address other than the sender's.
+// It takes precedence over any Reply-To: header given to AddHeader.
+func (m *Message) SetReplyTo(email string) {
+	m.replyTo = email
+}
+
+// SetEnvelopeSender sets the Sender: header of the message, through the h:Sender message parameter.
+// Unlike the sender given to NewMessage, which becomes the From: header recipients see,
+// the envelope sender names the address responsible for sending the message, and so the one bounces are routed to;
+// it suits senders who handle bounces at an address of their own, apart from the one they write from.
+// It takes precedence over any Sender: header given to AddHeader.
+// Send refuses a message whose envelope sender lacks an @ sign, giving ErrInvalidEnvelopeSender.
+func (m *Message) SetEnvelopeSender(addr string) {
+	m.envelopeSender = addr
+}
+
+// GenerateMessageID creates a globally unique Message-ID of the form <uuid@domain>, as RFC 2822 requires.
+// The UUID is a random (version 4) UUID drawn from crypto/rand.
+// Unlike the message ID Mailgun assigns, this one is known before the message is sent.
+func GenerateMessageID(domain string) string {
+	return "<" + newUUID() + "@" + domain + ">"
+}
+
+// newUUID creates a random (version 4) UUID, drawn from crypto/rand.
+func newUUID() string {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		// Only the system's source of randomness can fail us here; fall back to the clock.
+		binary.BigEndian.PutUint64(u[:8], uint64(time.Now().UnixNano()))
+		binary.BigEndian.PutUint64(u[8:], uint64(os.Getpid()))
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// validMessageID reports whether id takes the form <left@right> RFC 5322 gives message IDs.
+func validMessageID(id string) bool {
+	if len(id) < 2 || id[0] != '<' || id[len(id)-1] != '>' {
+		return false
+	}
+	at := strings.Index(id, "@")
+	return at > 1 && at < len(id)-2 && !strings.ContainsAny(id[1:len(id)-1], "<> \t\r\n")
+}
+
+// SetCustomMessageID sets the Message-Id: header of the message, through the h:Message-Id message parameter,
+// as for threading replies under an ID of the application's own, or recognising a message sent again on retry.
+// Mailgun keeps a Message-Id given this way rather than generate one, so events about the message
+// report it in their message.headers.message-id field.
+// It takes precedence over any Message-Id: header given to AddHeader, or AutoSetMessageID.
+// Send refuses an ID not of the form <id@domain>, angle brackets and all, as RFC 5322 requires,
+// giving ErrInvalidMessageID.  GenerateMessageID makes IDs of that form.
+func (m *Message) SetCustomMessageID(id string) {
+	m.customMessageID = id
+}
+
+// AutoSetMessageID gives the message a Message-ID header generated by GenerateMessageID,
+// unless the message already has one.
+func (m *Message) AutoSetMessageID(domain string) {
+	if m.customMessageID != "" {
+		return
+	}
+	for header := range m.headers {
+		if strings.EqualFold(header, "Message-ID") {
+			return
+		}
+	}
+	m.AddHeader("Message-ID", GenerateMessageID(domain))
+}
+
 // AddVariable lets you associate a set of variables with messages you send,
 // which Mailgun can use to, in essence, complete form-mail.
 // Refer to the Mailgun documentation for more information.
@@ -413,96 +1130,357 @@ func (m *Message) AddVariable(variable string, value interface{}) error {
 	return nil
 }
 
+// AddVariableJSON works as AddVariable, but takes the variable's value already encoded as JSON,
+// such as a blob read from a database column, and sends it as is rather than encode it again.
+// It refuses values which aren't valid JSON with ErrInvalidVariableJSON, leaving the message unchanged.
+func (m *Message) AddVariableJSON(variable, rawJSON string) error {
+	if !json.Valid([]byte(rawJSON)) {
+		return ErrInvalidVariableJSON
+	}
+	if m.variables == nil {
+		m.variables = make(map[string]string)
+	}
+	m.variables[variable] = rawJSON
+	return nil
+}
+
+// A MimeMessage carries a message already rendered in MIME form, for sending with SendMime.
+// MimeBody reads the complete message, headers and all; if it's also an io.Closer, it's closed once read.
+// To lists the recipients to deliver it to, which needn't match its To: header.
+// From is for your own bookkeeping: Mailgun takes the sender from the From: header within MimeBody.
+type MimeMessage struct {
+	To       []string
+	From     string
+	MimeBody io.Reader
+}
+
+// SendMime queues a pre-rendered MIME message for delivery, returning the status and message ID as Send does.
+// It works as Send does with a message from NewMIMEMessage.
+func (m *MailgunImpl) SendMime(ctx context.Context, mm *MimeMessage) (string, string, error) {
+	if mm == nil {
+		return "", "", ErrNilMessage
+	}
+	if mm.MimeBody == nil {
+		return "", "", ErrNoMIMEBody
+	}
+	body, ok := mm.MimeBody.(io.ReadCloser)
+	if !ok {
+		body = ioutil.NopCloser(mm.MimeBody)
+	}
+	return m.Send(ctx, m.NewMIMEMessage(body, mm.To...))
+}
+
 // Send attempts to queue a message (see Message, NewMessage, and its methods) for delivery.
 // It returns the Mailgun server response, which consists of two components:
 // a human-readable status message, and a message ID.  The status and message ID are set only
 // if no error occurred.
-func (m *MailgunImpl) Send(message *Message) (mes string, id string, err error) {
-	if !isValid(message) {
-		err = errors.New("Message not valid")
-	} else {
-		payload := newFormDataPayload()
+// Messages with per-recipient CCs (see SetRecipientCC) take several API calls to send;
+// for those, the status and message ID of the first call are returned.
+func (m *MailgunImpl) Send(ctx context.Context, message *Message) (mes string, id string, err error) {
+	return m.sendFromDomain(ctx, m.Domain(), message, "")
+}
 
-		message.specific.addValues(payload)
-		for _, to := range message.to {
-			payload.addValue("to", to)
-		}
-		for _, tag := range message.tags {
-			payload.addValue("o:tag", tag)
-		}
-		for _, campaign := range message.campaigns {
-			payload.addValue("o:campaign", campaign)
-		}
-		if message.dkimSet {
-			payload.addValue("o:dkim", yesNo(message.dkim))
+// SendWithIdempotencyKey works as Send, but marks the send with the given key in an X-Idempotency-Key header.
+// Mailgun deduplicates sends bearing the same key within a rolling window of time,
+// so a caller unsure whether an earlier send went through, say after a timeout, may send again with the same key
+// without the message going out twice.  Keys must be unique to the message; a UUID serves well.
+// Messages with per-recipient CCs, which take several API calls, mark each call with a key derived from this one.
+// An empty key is refused with ErrEmptyParam.
+//
+// Send marks its calls with keys of its own when the client retries failed calls (see WithRetryPolicy),
+// reusing a call's key for each of its attempts, so that a retry doesn't repeat a send Mailgun accepted.
+func (m *MailgunImpl) SendWithIdempotencyKey(ctx context.Context, message *Message, key string) (mes string, id string, err error) {
+	if key == "" {
+		return "", "", ErrEmptyParam
+	}
+	return m.sendFromDomain(ctx, m.Domain(), message, key)
+}
+
+// SendWithTemplate has Mailgun render the message from the named template, stored with CreateTemplate,
+// and sends it as Send does.  It sets the message's template, its version (a blank version picks the active one),
+// and adds the variables given to any the message already has, for the template to fill itself in with.
+// The message keeps these settings afterwards.
+// MIME messages, which carry their bodies already rendered, cannot use templates, and are refused.
+func (m *MailgunImpl) SendWithTemplate(ctx context.Context, message *Message, templateName, version string, vars map[string]interface{}) (string, string, error) {
+	if message == nil {
+		return "", "", ErrNilMessage
+	}
+	if templateName == "" {
+		return "", "", ErrEmptyParam
+	}
+	if _, ok := message.specific.(*mimeMessage); ok {
+		return "", "", fmt.Errorf("cannot send a MIME message with a template")
+	}
+	for name, value := range vars {
+		if err := message.AddVariable(name, value); err != nil {
+			return "", "", fmt.Errorf("template variable %s: %v", name, err)
 		}
-		if message.deliveryTime != nil {
-			payload.addValue("o:deliverytime", formatMailgunTime(message.deliveryTime))
+	}
+	message.SetTemplate(templateName)
+	message.SetTemplateVersion(version)
+	return m.Send(ctx, message)
+}
+
+// sendFromDomain works as Send, but sends the message through the named domain
+// rather than the one configured for the client, marking it with the idempotency key given, if any.
+func (m *MailgunImpl) sendFromDomain(ctx context.Context, domain string, message *Message, key string) (mes string, id string, err error) {
+	if err := validateMessage(message); err != nil {
+		return "", "", err
+	}
+	if len(message.recipientCCs) == 0 {
+		return m.submit(ctx, domain, message, key, message.to, nil, message.readerAttachments, message.readerInlines)
+	}
+
+	// Mailgun cannot vary the Cc: header by recipient, so each recipient with CCs of their own
+	// gets a send of their own.  As every send reads the reader attachments anew, read them just once.
+	attachments, err := bufferReaderAttachments(message.readerAttachments)
+	if err != nil {
+		return "", "", err
+	}
+	inlines, err := bufferReaderAttachments(message.readerInlines)
+	if err != nil {
+		return "", "", err
+	}
+	var shared []string
+	for _, to := range message.to {
+		if _, ok := message.recipientCCs[to]; !ok {
+			shared = append(shared, to)
 		}
-		if message.testMode {
-			payload.addValue("o:testmode", "yes")
+	}
+	if len(shared) > 0 {
+		mes, id, err = m.submit(ctx, domain, message, key, shared, nil, attachments.readers(), inlines.readers())
+		if err != nil {
+			return "", "", err
 		}
-		if message.trackingSet {
-			payload.addValue("o:tracking", yesNo(message.tracking))
+	}
+	for _, to := range message.to {
+		cc, ok := message.recipientCCs[to]
+		if !ok {
+			continue
 		}
-		if message.trackingClicksSet {
-			payload.addValue("o:tracking-clicks", yesNo(message.trackingClicks))
+		status, sendID, err := m.submit(ctx, domain, message, recipientIdempotencyKey(key, to), []string{to}, cc, attachments.readers(), inlines.readers())
+		if err != nil {
+			return "", "", err
 		}
-		if message.trackingOpensSet {
-			payload.addValue("o:tracking-opens", yesNo(message.trackingOpens))
+		if id == "" {
+			mes, id = status, sendID
 		}
-		if message.headers != nil {
-			for header, value := range message.headers {
-				payload.addValue("h:"+header, value)
+	}
+	return mes, id, nil
+}
+
+// recipientIdempotencyKey derives the key marking the send to a recipient with CCs of their own
+// from the key marking the message, if any.
+func recipientIdempotencyKey(key, to string) string {
+	if key == "" {
+		return ""
+	}
+	return key + "/" + to
+}
+
+// submit makes a single API call to send the message to the given To: recipients,
+// marked with the idempotency key given.
+// Without a key, a client which retries failed calls makes one up, for every attempt at the call to carry.
+// See newSendPayload for the meaning of the remaining parameters.
+func (m *MailgunImpl) submit(ctx context.Context, domain string, message *Message, key string, to, cc []string, attachments, inlines []ReaderAttachment) (mes string, id string, err error) {
+	payload, err := newSendPayload(message, to, cc, attachments, inlines)
+	if err != nil {
+		return "", "", err
+	}
+
+	r := newHTTPRequest(generateApiUrlWithDomain(m, domain, message.specific.endpoint()))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	if key == "" && m.retryPolicy.MaxAttempts > 1 {
+		key = newUUID()
+	}
+	if key != "" {
+		r.addHeader(idempotencyKeyHeader, key)
+	}
+
+	var response sendMessageResponse
+	err = postResponseFromJSON(r, payload, &response)
+	if err == nil {
+		mes = response.Message
+		id = response.Id
+	}
+	return
+}
+
+// newSendPayload renders the form sending the message to the given To: recipients,
+// copying in the extra Cc: recipients along with those set on the message.
+// The reader attachments and inlines given replace the message's own.
+func newSendPayload(message *Message, to, cc []string, attachments, inlines []ReaderAttachment) (*formDataPayload, error) {
+	payload := newFormDataPayload()
+
+	message.specific.addValues(payload)
+	for _, t := range to {
+		payload.addValue("to", t)
+	}
+	for _, c := range cc {
+		payload.addValue("cc", c)
+	}
+	for _, tag := range message.tags {
+		payload.addValue("o:tag", tag)
+	}
+	for _, campaign := range message.campaigns {
+		payload.addValue("o:campaign", campaign)
+	}
+	if message.dkimSet {
+		payload.addValue("o:dkim", yesNo(message.dkim))
+	}
+	if message.deliveryTimeText != "" {
+		payload.addValue("o:deliverytime", message.deliveryTimeText)
+	} else if message.deliveryTime != nil {
+		payload.addValue("o:deliverytime", formatMailgunTime(message.deliveryTime))
+	}
+	if message.messageTTL != nil {
+		payload.addValue("o:message-ttl", strconv.Itoa(*message.messageTTL))
+	}
+	if message.testMode {
+		payload.addValue("o:testmode", "yes")
+	}
+	if message.trackingSet {
+		payload.addValue("o:tracking", yesNo(message.tracking))
+	}
+	if message.trackingClicksSet {
+		payload.addValue("o:tracking-clicks", string(message.trackingClicks))
+	}
+	if message.trackingOpensSet {
+		payload.addValue("o:tracking-opens", yesNo(message.trackingOpens))
+	}
+	if message.requireTLSSet {
+		payload.addValue("o:require-tls", yesNo(message.requireTLS))
+	}
+	if message.skipVerificationSet {
+		payload.addValue("o:skip-verification", yesNo(message.skipVerification))
+	}
+	if message.sendingIP != "" {
+		payload.addValue("o:sending-ip", message.sendingIP)
+	}
+	if message.headers != nil {
+		for header, value := range message.headers {
+			if message.replyTo != "" && strings.EqualFold(header, "Reply-To") {
+				continue
 			}
-		}
-		if message.variables != nil {
-			for variable, value := range message.variables {
-				payload.addValue("v:"+variable, value)
+			if message.envelopeSender != "" && strings.EqualFold(header, "Sender") {
+				continue
 			}
-		}
-		if message.recipientVariables != nil {
-			j, err := json.Marshal(message.recipientVariables)
-			if err != nil {
-				return "", "", err
+			if message.customMessageID != "" && strings.EqualFold(header, "Message-Id") {
+				continue
 			}
-			payload.addValue("recipient-variables", string(j))
+			payload.addValue("h:"+header, value)
 		}
-		if message.attachments != nil {
-			for _, attachment := range message.attachments {
-				payload.addFile("attachment", attachment)
-			}
+	}
+	if message.replyTo != "" {
+		payload.addValue("h:Reply-To", message.replyTo)
+	}
+	if message.envelopeSender != "" {
+		payload.addValue("h:Sender", message.envelopeSender)
+	}
+	if message.customMessageID != "" {
+		payload.addValue("h:Message-Id", message.customMessageID)
+	}
+	if message.variables != nil {
+		for variable, value := range message.variables {
+			payload.addValue("v:"+variable, value)
 		}
-		if message.readerAttachments != nil {
-			for _, readerAttachment := range message.readerAttachments {
-				payload.addReadCloser("attachment", readerAttachment.Filename, readerAttachment.ReadCloser)
+	}
+	if message.recipientVariables != nil {
+		vars := message.recipientVariables
+		if len(to) < len(message.to) {
+			vars = make(map[string]map[string]interface{}, len(to))
+			for recipient, v := range message.recipientVariables {
+				if containsString(to, recipient) || containsString(cc, recipient) || message.specific.isCopied(recipient) {
+					vars[recipient] = v
+				}
 			}
 		}
-		if message.inlines != nil {
-			for _, inline := range message.inlines {
-				payload.addFile("inline", inline)
-			}
+		j, err := json.Marshal(vars)
+		if err != nil {
+			return nil, err
 		}
+		payload.addValue("recipient-variables", string(j))
+	}
+	if message.attachments != nil {
+		for _, attachment := range message.attachments {
+			payload.addFile("attachment", attachment)
+		}
+	}
+	for _, readerAttachment := range attachments {
+		payload.addReadCloserWithType("attachment", readerAttachment.Filename, readerAttachment.ContentType, readerAttachment.ReadCloser)
+	}
+	if message.inlines != nil {
+		for _, inline := range message.inlines {
+			payload.addFile("inline", inline)
+		}
+	}
+	for _, readerInline := range inlines {
+		payload.addReadCloserWithType("inline", readerInline.Filename, readerInline.ContentType, readerInline.ReadCloser)
+	}
+	return payload, nil
+}
 
-		r := newHTTPRequest(generateApiUrl(m, message.specific.endpoint()))
-		r.setClient(m.Client())
-		r.setBasicAuth(basicAuthUser, m.ApiKey())
+// bufferedAttachments holds the contents of reader attachments in memory, so they may be sent repeatedly.
+type bufferedAttachments struct {
+	attachments []ReaderAttachment
+	contents    [][]byte
+}
 
-		var response sendMessageResponse
-		err = postResponseFromJSON(r, payload, &response)
-		if err == nil {
-			mes = response.Message
-			id = response.Id
+// bufferReaderAttachments reads, then closes, each of the reader attachments.
+func bufferReaderAttachments(attachments []ReaderAttachment) (*bufferedAttachments, error) {
+	b := &bufferedAttachments{attachments: attachments}
+	for _, ra := range attachments {
+		data, err := ioutil.ReadAll(ra.ReadCloser)
+		ra.ReadCloser.Close()
+		if err != nil {
+			return nil, err
 		}
+		b.contents = append(b.contents, data)
 	}
+	return b, nil
+}
 
-	return
+// readers returns fresh reader attachments over the buffered contents.
+func (b *bufferedAttachments) readers() []ReaderAttachment {
+	readers := make([]ReaderAttachment, len(b.attachments))
+	for i, ra := range b.attachments {
+		ra.ReadCloser = ioutil.NopCloser(bytes.NewReader(b.contents[i]))
+		readers[i] = ra
+	}
+	return readers
+}
+
+// SendVerificationMessage checks that the named domain can send mail, by sending a minimal
+// message from test@domain to postmaster@domain in test mode.
+// Mailgun accepts, but then discards, messages sent in test mode, so no mail is ever delivered.
+// A nil error, along with the returned message ID, indicates the domain is configured correctly,
+// which makes this function suitable for health checks at application startup.
+func (m *MailgunImpl) SendVerificationMessage(ctx context.Context, domain string) (string, error) {
+	message := m.NewMessage(
+		"test@"+domain,
+		"Mailgun configuration test",
+		"This message verifies that "+domain+" can send mail through Mailgun.",
+		"postmaster@"+domain,
+	)
+	message.EnableTestMode()
+	_, id, err := m.sendFromDomain(ctx, domain, message, "")
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		return "", fmt.Errorf("no message ID returned for the verification message")
+	}
+	return id, nil
 }
 
 func (pm *plainMessage) addValues(p *formDataPayload) {
 	p.addValue("from", pm.from)
 	p.addValue("subject", pm.subject)
-	p.addValue("text", pm.text)
+	if pm.text != "" {
+		p.addValue("text", pm.text)
+	}
 	for _, cc := range pm.cc {
 		p.addValue("cc", cc)
 	}
@@ -512,10 +1490,19 @@ func (pm *plainMessage) addValues(p *formDataPayload) {
 	if pm.html != "" {
 		p.addValue("html", pm.html)
 	}
+	if pm.ampHtml != "" {
+		p.addValue("amp-html", pm.ampHtml)
+	}
+	if pm.template != "" {
+		p.addValue("template", pm.template)
+	}
+	if pm.templateVersion != "" {
+		p.addValue("t:version", pm.templateVersion)
+	}
 }
 
 func (mm *mimeMessage) addValues(p *formDataPayload) {
-	p.addReadCloser("message", "message.mime", mm.body)
+	p.addReadCloserWithType("message", "message.mime", "message/rfc822", mm.body)
 }
 
 func (pm *plainMessage) endpoint() string {
@@ -535,54 +1522,146 @@ func yesNo(b bool) string {
 	}
 }
 
-// isValid returns true if, and only if,
+// validateMessage returns nil if, and only if,
 // a Message instance is sufficiently initialized to send via the Mailgun interface.
-func isValid(m *Message) bool {
+// Otherwise, it returns one of the errors above, explaining what's missing.
+func validateMessage(m *Message) error {
 	if m == nil {
-		return false
+		return ErrNilMessage
 	}
 
-	if !m.specific.isValid() {
-		return false
+	if m.optionErr != nil {
+		return m.optionErr
 	}
 
-	if !validateStringList(m.to, true) {
-		return false
+	if err := m.specific.validate(); err != nil {
+		return err
+	}
+
+	// Plain messages may go to Cc: or Bcc: recipients alone, but MIME messages need To: recipients.
+	if len(m.to) == 0 {
+		if _, ok := m.specific.(*mimeMessage); ok || m.specific.recipientCount() == 0 {
+			return ErrNoRecipients
+		}
+	}
+	if !validateStringList(m.to, false) {
+		return ErrEmptyRecipient
+	}
+	if err := validateAddresses("to", m.to); err != nil {
+		return err
+	}
+	if n := m.RecipientCount(); n > MaxNumberOfRecipients {
+		return &TooManyRecipientsError{Count: n}
 	}
 
 	if !validateStringList(m.tags, false) {
-		return false
+		return ErrEmptyTag
 	}
 
-	if !validateStringList(m.campaigns, false) || len(m.campaigns) > 3 {
-		return false
+	if !validateStringList(m.campaigns, false) {
+		return ErrEmptyCampaign
+	}
+	if len(m.campaigns) > MaxNumberOfCampaigns {
+		return ErrTooManyCampaigns
 	}
 
-	return true
+	for recipient := range m.recipientVariables {
+		if !containsString(m.to, recipient) && !m.specific.isCopied(recipient) {
+			return ErrStrayRecipientVariables
+		}
+	}
+
+	// Mailgun refuses v: variables which aren't valid JSON; report the first, by name, for a stable result.
+	var invalid []string
+	for variable, value := range m.variables {
+		if !json.Valid([]byte(value)) {
+			invalid = append(invalid, variable)
+		}
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return &InvalidVariableJSONError{Variable: invalid[0]}
+	}
+
+	if m.messageTTL != nil && *m.messageTTL <= 0 {
+		return ErrInvalidMessageTTL
+	}
+
+	if m.sendingIP != "" && net.ParseIP(m.sendingIP) == nil {
+		return ErrInvalidSendingIP
+	}
+
+	if m.replyTo != "" {
+		if _, _, err := ParseAddress(m.replyTo); err != nil {
+			return ErrInvalidReplyTo
+		}
+	}
+
+	if m.envelopeSender != "" && !strings.Contains(m.envelopeSender, "@") {
+		return ErrInvalidEnvelopeSender
+	}
+
+	if m.customMessageID != "" && !validMessageID(m.customMessageID) {
+		return ErrInvalidMessageID
+	}
+
+	return nil
 }
 
-func (pm *plainMessage) isValid() bool {
+func (pm *plainMessage) validate() error {
 	if pm.from == "" {
-		return false
+		return ErrNoSender
 	}
 
 	if !validateStringList(pm.cc, false) {
-		return false
+		return ErrEmptyRecipient
 	}
 
 	if !validateStringList(pm.bcc, false) {
-		return false
+		return ErrEmptyRecipient
 	}
 
-	if pm.text == "" {
-		return false
+	if err := validateAddresses("from", []string{pm.from}); err != nil {
+		return err
+	}
+	if err := validateAddresses("cc", pm.cc); err != nil {
+		return err
+	}
+	if err := validateAddresses("bcc", pm.bcc); err != nil {
+		return err
+	}
+
+	if pm.text == "" && pm.template == "" {
+		return ErrNoBody
+	}
+
+	if pm.ampHtml != "" && pm.html == "" {
+		return ErrAMPWithoutHTML
+	}
+
+	return nil
+}
+
+func (mm *mimeMessage) validate() error {
+	if mm.body == nil {
+		return ErrNoMIMEBody
 	}
+	return nil
+}
 
-	return true
+// isCopied always reports false, as the Cc: and Bcc: recipients of a MIME message lie within its body.
+func (mm *mimeMessage) isCopied(address string) bool {
+	return false
 }
 
-func (mm *mimeMessage) isValid() bool {
-	return mm.body != nil
+// containsString reports whether the list holds the string s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
 }
 
 // validateStringList returns true if, and only if,
@@ -610,39 +1689,172 @@ func validateStringList(list []string, requireOne bool) bool {
 // GetStoredMessage retrieves information about a received e-mail message.
 // This provides visibility into, e.g., replies to a message sent to a mailing list.
 func (mg *MailgunImpl) GetStoredMessage(id string) (StoredMessage, error) {
-	url := generateStoredMessageUrl(mg, messagesEndpoint, id)
-	r := newHTTPRequest(url)
-	r.setClient(mg.Client())
+	message, err := mg.GetStoredMessageByURL(context.Background(), generateStoredMessageUrl(mg, messagesEndpoint, id))
+	if err != nil {
+		return StoredMessage{}, err
+	}
+	return *message, nil
+}
+
+// GetStoredMessageByURL works as GetStoredMessage, but takes the URL Mailgun stores the message under,
+// as its events report in their storage.url field (see Event.StorageURL), and honors ctx.
+// The client's API key goes along with the request, so take the URL only from Mailgun.
+func (mg *MailgunImpl) GetStoredMessageByURL(ctx context.Context, storageURL string) (*StoredMessage, error) {
+	r := newHTTPRequest(storageURL)
+	r.setContext(ctx)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 
 	var response StoredMessage
-	err := getResponseFromJSON(r, &response)
-	return response, err
+	if err := getResponseFromJSON(r, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
 }
 
 // GetStoredMessageRaw retrieves the raw MIME body of a received e-mail message.
 // Compared to GetStoredMessage, it gives access to the unparsed MIME body, and
 // thus delegates to the caller the required parsing.
 func (mg *MailgunImpl) GetStoredMessageRaw(id string) (StoredMessageRaw, error) {
-	url := generateStoredMessageUrl(mg, messagesEndpoint, id)
+	return mg.getStoredMessageRaw(context.Background(), generateStoredMessageUrl(mg, messagesEndpoint, id))
+}
+
+// GetStoredMessageRawByURL works as GetStoredMessageByURL, but gives the message as the MIME it was sent or received as,
+// for callers which pipe messages into parsers of their own.
+// The caller must close the reader returned.  It's the same as GetStoredMessageMimeReader.
+func (mg *MailgunImpl) GetStoredMessageRawByURL(ctx context.Context, storageURL string) (io.ReadCloser, error) {
+	return mg.GetStoredMessageMimeReader(ctx, storageURL)
+}
+
+// GetStoredMessageMimeBytes retrieves the stored message at the given URL as the MIME it was sent or received as,
+// for forwarding or archiving it whole.  See GetStoredMessageByURL regarding the URL.
+func (mg *MailgunImpl) GetStoredMessageMimeBytes(ctx context.Context, storageURL string) ([]byte, error) {
+	r := newHTTPRequest(storageURL)
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	r.addHeader("Accept", "message/rfc2822")
+
+	resp, err := getStreamResponse(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var envelope struct {
+		BodyMime mimeBytes `json:"body-mime"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, err
+	}
+	return envelope.BodyMime, nil
+}
+
+// GetStoredMessageMimeReader works as GetStoredMessageMimeBytes, but gives the MIME as a reader,
+// to hand on to parsers, files, or other writers without a copy of the caller's own.
+// Mailgun sends the MIME wrapped in a JSON document, so the message is held in memory whole all the same,
+// while the reader is open.
+// The caller must close the reader returned.
+func (mg *MailgunImpl) GetStoredMessageMimeReader(ctx context.Context, storageURL string) (io.ReadCloser, error) {
+	data, err := mg.GetStoredMessageMimeBytes(ctx, storageURL)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// mimeBytes decodes a JSON string straight into bytes,
+// so a stored message's MIME isn't held as a string and then copied again into a slice.
+type mimeBytes []byte
+
+func (b *mimeBytes) UnmarshalJSON(data []byte) error {
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return fmt.Errorf("body-mime: expected a JSON string, got %.20q", data)
+	}
+	data = data[1 : len(data)-1]
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] != '\\' {
+			out = append(out, data[i])
+			continue
+		}
+		if i++; i == len(data) {
+			return errors.New("body-mime: unterminated escape")
+		}
+		switch data[i] {
+		case '"', '\\', '/':
+			out = append(out, data[i])
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'u':
+			r, ok := hexRune(data[i+1:])
+			if !ok {
+				return errors.New("body-mime: malformed \\u escape")
+			}
+			i += 4
+			if utf16.IsSurrogate(r) {
+				// A lone or mismatched surrogate decodes to U+FFFD, as encoding/json has it.
+				low, ok := rune(0), false
+				if i+2 < len(data) && data[i+1] == '\\' && data[i+2] == 'u' {
+					low, ok = hexRune(data[i+3:])
+				}
+				if pair := utf16.DecodeRune(r, low); ok && pair != utf8.RuneError {
+					r = pair
+					i += 6
+				} else {
+					r = utf8.RuneError
+				}
+			}
+			var buf [utf8.UTFMax]byte
+			out = append(out, buf[:utf8.EncodeRune(buf[:], r)]...)
+		default:
+			return fmt.Errorf("body-mime: invalid escape \\%c", data[i])
+		}
+	}
+	*b = out
+	return nil
+}
+
+// hexRune reads the four hex digits of a \u escape from the start of data.
+func hexRune(data []byte) (rune, bool) {
+	if len(data) < 4 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(string(data[:4]), 16, 16)
+	return rune(n), err == nil
+}
+
+func (mg *MailgunImpl) getStoredMessageRaw(ctx context.Context, url string) (StoredMessageRaw, error) {
 	r := newHTTPRequest(url)
-	r.setClient(mg.Client())
+	r.setContext(ctx)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	r.addHeader("Accept", "message/rfc2822")
 
 	var response StoredMessageRaw
 	err := getResponseFromJSON(r, &response)
 	return response, err
-
 }
 
 // DeleteStoredMessage removes a previously stored message.
 // Note that Mailgun institutes a policy of automatically deleting messages after a set time.
 // Consult the current Mailgun API documentation for more details.
 func (mg *MailgunImpl) DeleteStoredMessage(id string) error {
-	url := generateStoredMessageUrl(mg, messagesEndpoint, id)
-	r := newHTTPRequest(url)
-	r.setClient(mg.Client())
+	return mg.DeleteStoredMessageByURL(context.Background(), generateStoredMessageUrl(mg, messagesEndpoint, id))
+}
+
+// DeleteStoredMessageByURL works as DeleteStoredMessage, but takes the URL Mailgun stores the message under.
+func (mg *MailgunImpl) DeleteStoredMessageByURL(ctx context.Context, storageURL string) error {
+	r := newHTTPRequest(storageURL)
+	r.setContext(ctx)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	_, err := makeDeleteRequest(r)
 	return err
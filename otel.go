@@ -0,0 +1,97 @@
+//go:build otel
+// +build otel
+
+package mailgun
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans this package creates to the tracer provider.
+const tracerName = "github.com/mailgun/mailgun-go"
+
+// WithTracer arranges for the client to trace every API call it makes as a client span of the caller's context,
+// taken from the context given to the call, if any.
+// Spans are named mailgun.{operation}, as in mailgun.send, mailgun.listEvents, or mailgun.getBounces,
+// and carry the attributes mailgun.domain, http.status_code, and, for sends, mailgun.message_id.
+//
+// WithTracer wraps the transport of the client's HTTP client, so it must come after any option replacing that client;
+// a later call to SetClient drops the tracing.
+// It's only available when building with the otel tag, so that programs not using OpenTelemetry don't depend on it:
+//
+//	go build -tags otel
+func WithTracer(tp trace.TracerProvider) Option {
+	return func(m *MailgunImpl) {
+		c := *m.client
+		c.Transport = &tracingTransport{
+			tracer: tp.Tracer(tracerName),
+			next:   c.Transport,
+		}
+		m.client = &c
+	}
+}
+
+// tracingTransport traces the requests it sends on to the next transport.
+type tracingTransport struct {
+	tracer trace.Tracer
+	next   http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	operation, domain := spanOperation(req)
+	ctx, span := t.tracer.Start(req.Context(), "mailgun."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.method", req.Method),
+			attribute.String("http.url", req.URL.String()),
+		),
+	)
+	defer span.End()
+	if domain != "" {
+		span.SetAttributes(attribute.String("mailgun.domain", domain))
+	}
+
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+		return resp, nil
+	}
+	if operation == "send" || operation == "sendMime" {
+		if id := peekMessageID(resp); id != "" {
+			span.SetAttributes(attribute.String("mailgun.message_id", id))
+		}
+	}
+	return resp, nil
+}
+
+// peekMessageID reads the message ID out of the response to a send, leaving the body for the client to read in turn.
+func peekMessageID(resp *http.Response) string {
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	var body sendMessageResponse
+	if json.Unmarshal(data, &body) != nil {
+		return ""
+	}
+	return body.Id
+}
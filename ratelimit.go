@@ -0,0 +1,107 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// A RateLimitStatus reports the API rate limit which applied to the most recent API response.
+// Limit gives the number of calls permitted per period, Remaining the number of calls left,
+// and Reset the time at which the current period ends.
+type RateLimitStatus struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// Header names Mailgun uses to report rate limits.
+const (
+	rateLimitLimitHeader     = "X-RateLimit-Limit"
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
+	rateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// GetRateLimitStatus returns the rate limit reported by the most recent API response
+// received through this client.
+// If the client has yet to see a rate limit, GetRateLimitStatus makes an inexpensive API call to learn it.
+// An error results if Mailgun doesn't report rate limits for the account.
+func (m *MailgunImpl) GetRateLimitStatus(ctx context.Context) (*RateLimitStatus, error) {
+	if status := m.lastRateLimit(); status != nil {
+		return status, nil
+	}
+
+	r := newHTTPRequest(generatePublicApiUrl(m, domainsEndpoint))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	r.addParameter("limit", "1")
+	if _, err := makeGetRequest(r); err != nil {
+		return nil, err
+	}
+
+	if status := m.lastRateLimit(); status != nil {
+		return status, nil
+	}
+	return nil, fmt.Errorf("no rate limit reported by the API")
+}
+
+// SetRateLimitUpdateHook installs a function to call whenever an API response reports a rate limit.
+// The hook runs on the goroutine which made the API call, so it should return promptly.
+// Pass nil to remove the hook.
+func (m *MailgunImpl) SetRateLimitUpdateHook(hook func(RateLimitStatus)) {
+	m.rateLimitLock.Lock()
+	defer m.rateLimitLock.Unlock()
+	m.rateLimitHook = hook
+}
+
+// lastRateLimit returns a copy of the most recently recorded rate limit, or nil if none was recorded.
+func (m *MailgunImpl) lastRateLimit() *RateLimitStatus {
+	m.rateLimitLock.Lock()
+	defer m.rateLimitLock.Unlock()
+	if m.rateLimit == nil {
+		return nil
+	}
+	status := *m.rateLimit
+	return &status
+}
+
+// observeResponse records what the client needs to know about every API response it receives.
+func (m *MailgunImpl) observeResponse(resp *http.Response) {
+	status, ok := parseRateLimit(resp.Header)
+	if !ok {
+		return
+	}
+	m.rateLimitLock.Lock()
+	m.rateLimit = &status
+	hook := m.rateLimitHook
+	m.rateLimitLock.Unlock()
+
+	if hook != nil {
+		hook(status)
+	}
+}
+
+// parseRateLimit extracts the rate limit from the headers of an API response.
+// Mailgun reports the reset time as a Unix timestamp, sometimes in milliseconds.
+func parseRateLimit(h http.Header) (RateLimitStatus, bool) {
+	limit, err := strconv.Atoi(h.Get(rateLimitLimitHeader))
+	if err != nil {
+		return RateLimitStatus{}, false
+	}
+	remaining, err := strconv.Atoi(h.Get(rateLimitRemainingHeader))
+	if err != nil {
+		return RateLimitStatus{}, false
+	}
+	status := RateLimitStatus{Limit: limit, Remaining: remaining}
+	if reset, err := strconv.ParseInt(h.Get(rateLimitResetHeader), 10, 64); err == nil {
+		if reset > 1e12 {
+			status.Reset = time.Unix(0, reset*int64(time.Millisecond))
+		} else {
+			status.Reset = time.Unix(reset, 0)
+		}
+	}
+	return status, true
+}
@@ -0,0 +1,172 @@
+package mailgun
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRateLimitWait results when a RateLimitedTransport would have to hold a request back longer than its MaxWait.
+var ErrRateLimitWait = errors.New("mailgun: request would wait too long for the local rate limit")
+
+// An EndpointFamily names a group of API endpoints which Mailgun rate limits together.
+type EndpointFamily string
+
+// The endpoint families a RateLimitedTransport tells apart.
+// EndpointFamilySend covers sending messages, EndpointFamilyEvents listing events,
+// and EndpointFamilyValidation validating addresses; EndpointFamilyOther covers everything else.
+const (
+	EndpointFamilySend       EndpointFamily = "send"
+	EndpointFamilyEvents     EndpointFamily = "events"
+	EndpointFamilyValidation EndpointFamily = "validation"
+	EndpointFamilyOther      EndpointFamily = "other"
+)
+
+// A RateLimit caps the rate of requests to an endpoint family.
+// Rate gives the number of requests allowed per second, on average;
+// Burst gives the number which may go out at once after a quiet spell, and is taken as 1 if below that.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// A RateLimitedTransport holds requests back to keep within rate limits of the client's own, set per endpoint family,
+// so that heavy senders slow down before Mailgun refuses them, rather than after.
+// Requests to families without a limit go straight through.
+// When Mailgun rate limits a request regardless (HTTP 429), the transport holds back the rest of its family
+// for as long as Mailgun's Retry-After header asks, or until the family's bucket refills if it gives none.
+//
+// Requests wait no longer than MaxWait, failing with ErrRateLimitWait if they would have to, nor beyond the end of
+// their context; a zero MaxWait lets them wait as long as their context allows.
+// This is separate from, and works alongside, a client's RetryPolicy. Install it with SetClient:
+//
+//	mg.SetClient(&http.Client{Transport: mailgun.NewRateLimitedTransport(nil, map[mailgun.EndpointFamily]mailgun.RateLimit{
+//		mailgun.EndpointFamilySend: {Rate: 10, Burst: 20},
+//	}, time.Minute)})
+//
+// It's safe for concurrent use.
+type RateLimitedTransport struct {
+	next    http.RoundTripper
+	maxWait time.Duration
+
+	mu      sync.Mutex
+	buckets map[EndpointFamily]*tokenBucket
+}
+
+// NewRateLimitedTransport creates a transport sending requests on through next, within the given limits.
+// A nil next stands for http.DefaultTransport.
+func NewRateLimitedTransport(next http.RoundTripper, limits map[EndpointFamily]RateLimit, maxWait time.Duration) *RateLimitedTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t := &RateLimitedTransport{next: next, maxWait: maxWait, buckets: make(map[EndpointFamily]*tokenBucket)}
+	for family, limit := range limits {
+		if limit.Rate <= 0 {
+			continue
+		}
+		burst := limit.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		t.buckets[family] = &tokenBucket{rate: limit.Rate, burst: float64(burst), tokens: float64(burst)}
+	}
+	return t
+}
+
+// RoundTrip waits for the request's turn, if its family is limited, then sends it on.
+func (t *RateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	bucket := t.buckets[endpointFamily(req)]
+	if bucket == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	t.mu.Lock()
+	wait := bucket.reserve(time.Now(), t.maxWait)
+	t.mu.Unlock()
+	if wait < 0 {
+		return nil, ErrRateLimitWait
+	}
+	if wait > 0 {
+		if err := sleep(req.Context(), wait); err != nil {
+			t.mu.Lock()
+			bucket.cancel()
+			t.mu.Unlock()
+			return nil, err
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		t.mu.Lock()
+		bucket.drain(time.Now(), parseRetryAfter(resp.Header.Get("Retry-After")))
+		t.mu.Unlock()
+	}
+	return resp, err
+}
+
+// endpointFamily tells which family of endpoints a request addresses.
+func endpointFamily(req *http.Request) EndpointFamily {
+	operation, _ := spanOperation(req)
+	switch {
+	case operation == "send" || operation == "sendMime":
+		return EndpointFamilySend
+	case operation == "listEvents":
+		return EndpointFamilyEvents
+	case strings.Contains(req.URL.Path, "/address/"):
+		return EndpointFamilyValidation
+	}
+	return EndpointFamilyOther
+}
+
+// tokenBucket tracks the requests an endpoint family may make: one token per request,
+// refilled at rate tokens per second, up to burst.  Tokens go negative as requests reserve turns ahead.
+type tokenBucket struct {
+	rate, burst float64
+	tokens      float64
+	last        time.Time
+	blocked     time.Time
+}
+
+// reserve takes a token for a request, giving the time the request must wait for it.
+// It gives -1, and takes nothing, if the wait would exceed a non-zero maxWait.
+func (b *tokenBucket) reserve(now time.Time, maxWait time.Duration) time.Duration {
+	b.refill(now)
+	var wait time.Duration
+	if b.tokens < 1 {
+		wait = time.Duration(math.Ceil((1 - b.tokens) / b.rate * float64(time.Second)))
+	}
+	if blocked := b.blocked.Sub(now); blocked > wait {
+		wait = blocked
+	}
+	if maxWait > 0 && wait > maxWait {
+		return -1
+	}
+	b.tokens--
+	return wait
+}
+
+// cancel returns the token of a request which gave up waiting.
+func (b *tokenBucket) cancel() {
+	b.tokens++
+}
+
+// drain empties the bucket after Mailgun refused a request, holding requests back for retryAfter, if given.
+func (b *tokenBucket) drain(now time.Time, retryAfter time.Duration) {
+	b.refill(now)
+	if b.tokens > 0 {
+		b.tokens = 0
+	}
+	if retryAfter > 0 {
+		b.blocked = now.Add(retryAfter)
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
@@ -1,7 +1,14 @@
 package mailgun
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // The MailgunGoUserAgent identifies the client to the server, for logging purposes.
@@ -30,14 +37,154 @@ func (e *UnexpectedResponseError) Error() string {
 	return e.String()
 }
 
+// multiError bundles the errors of several API calls made on behalf of a single operation.
+type multiError []error
+
+// Error() joins the messages of the underlying errors.
+func (e multiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// combineErrors condenses a list of errors into a single error.
+// It returns nil for an empty list, and the sole error of a singleton list,
+// so that callers may still inspect it directly.
+func combineErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return multiError(errs)
+	}
+}
+
+// APIError describes a failed call to the Mailgun API.
+// Every method which calls the API reports its failures as an *APIError,
+// save for cancellation or expiry of the caller's context, which is reported as the context's own error.
+// StatusCode holds the HTTP status code of Mailgun's response, or zero if no response arrived at all.
+// Message holds Mailgun's explanation of the failure, or that of the transport.
+// Retryable reports whether the same call may succeed if made again later:
+// true for rate limiting (HTTP 429), server-side failures (HTTP 5xx), and transport failures.
+// RetryCount gives the number of times the call was retried under the client's RetryPolicy before giving up.
+// RawBody holds the body of Mailgun's response as it arrived, and RequestID the ID Mailgun's X-Request-Id header
+// gave the call; quote the latter when asking Mailgun's support about a failure.  See Debug.
+// Err holds the underlying error; for an unexpected response, it's an *UnexpectedResponseError.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Retryable  bool
+	RetryCount int
+	RawBody    []byte
+	RequestID  string
+	Err        error
+
+	retryAfter time.Duration
+}
+
+// Error() reports the status code, if any, and the message.
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("mailgun: %s", e.Message)
+	}
+	return fmt.Sprintf("mailgun: HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// Debug describes the failure in full, for logging: the status code, request ID, and URL of the call,
+// along with Mailgun's response body as it arrived.
+func (e *APIError) Debug() string {
+	var url string
+	var ure *UnexpectedResponseError
+	if errors.As(e.Err, &ure) {
+		url = ure.URL
+	}
+	return fmt.Sprintf("mailgun: APIError StatusCode=%d RequestID=%q URL=%q RetryCount=%d Message=%q Body=%q",
+		e.StatusCode, e.RequestID, url, e.RetryCount, e.Message, e.RawBody)
+}
+
+// RetryAfter gives the wait Mailgun asked for, with its Retry-After header, when it rate limited the call (HTTP 429).
+// It's zero if Mailgun asked for no particular wait, or the call failed for some other reason.
+func (e *APIError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// Unwrap() gives the underlying error.
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// IsAPIError finds the *APIError in err's chain, if it has one.
+func IsAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+// newTransportError wraps a failure to get a response from Mailgun at all.
+func newTransportError(err error) error {
+	return &APIError{Message: err.Error(), Retryable: true, Err: err}
+}
+
 // newError creates a new error condition to be returned.
 func newError(url string, expected []int, got *httpResponse) error {
-	return &UnexpectedResponseError{
-		URL:      url,
-		Expected: expected,
-		Actual:   got.Code,
-		Data:     got.Data,
+	apiErr := &APIError{
+		StatusCode: got.Code,
+		Message:    responseMessage(got),
+		Retryable:  got.Code == http.StatusTooManyRequests || got.Code >= 500,
+		RawBody:    got.Data,
+		RequestID:  got.Header.Get("X-Request-Id"),
+		Err: &UnexpectedResponseError{
+			URL:      url,
+			Expected: expected,
+			Actual:   got.Code,
+			Data:     got.Data,
+		},
+	}
+	if got.Code == http.StatusTooManyRequests {
+		apiErr.retryAfter = parseRetryAfter(got.Header.Get("Retry-After"))
+	}
+	return apiErr
+}
+
+// parseRetryAfter converts a Retry-After header, given either in seconds or as an HTTP date, to a duration.
+// It gives zero for a missing or malformed header, or a date already past.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
 	}
+	return 0
+}
+
+// responseMessage extracts Mailgun's explanation from an error response,
+// falling back on the body itself, or failing that, the status text.
+func responseMessage(got *httpResponse) string {
+	var body struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(got.Data, &body) == nil && body.Message != "" {
+		return body.Message
+	}
+	if msg := strings.TrimSpace(string(got.Data)); msg != "" {
+		return msg
+	}
+	return http.StatusText(got.Code)
 }
 
 // notGood searches a list of response codes (the haystack) for a matching entry (the needle).
@@ -59,6 +206,77 @@ var expected = []int{200, 202, 204}
 // See simplehttp.MakeRequest for more details.
 func makeRequest(r *httpRequest, kind string, p payload) (*httpResponse, error) {
 	r.addHeader("User-Agent", MailgunGoUserAgent)
+	if retryPolicy(r).MaxAttempts < 2 {
+		return checkedRequest(r, kind, p)
+	}
+
+	if p != nil {
+		buffered, err := newBufferedPayload(p)
+		if err != nil {
+			return nil, err
+		}
+		p = buffered
+	}
+	var rsp *httpResponse
+	err := withRetries(r, func() (err error) {
+		rsp, err = checkedRequest(r, kind, p)
+		return err
+	})
+	return rsp, err
+}
+
+// retryPolicy gives the retry policy of the client making the request.
+func retryPolicy(r *httpRequest) RetryPolicy {
+	if r.owner == nil {
+		return RetryPolicy{}
+	}
+	return r.owner.retryPolicy
+}
+
+// withRetries makes a call, and makes it again as the client's retry policy directs while it fails with a retryable APIError.
+// It returns the error of the last attempt.
+func withRetries(r *httpRequest, call func() error) error {
+	policy := retryPolicy(r)
+	for retry := 0; ; retry++ {
+		err := call()
+		apiErr, ok := IsAPIError(err)
+		if !ok {
+			return err
+		}
+		apiErr.RetryCount = retry
+		if !apiErr.Retryable || retry+1 >= policy.MaxAttempts {
+			return err
+		}
+		if err := sleep(r.Context, policy.wait(retry+1, apiErr)); err != nil {
+			return err
+		}
+	}
+}
+
+// getStreamResponse shim performs a GET request, checking for a positive outcome,
+// and hands back the response with its body unread, for the caller to stream and close.
+// Failed calls are retried as makeRequest retries them; once a response is handed back, it's up to the caller.
+func getStreamResponse(r *httpRequest) (*http.Response, error) {
+	r.addHeader("User-Agent", MailgunGoUserAgent)
+	var resp *http.Response
+	err := withRetries(r, func() error {
+		var err error
+		if resp, err = r.makeStreamRequest("GET"); err != nil {
+			return err
+		}
+		if notGood(resp.StatusCode, expected) {
+			data, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			err = newError(r.URL, expected, &httpResponse{Code: resp.StatusCode, Header: resp.Header, Data: data})
+			resp = nil
+		}
+		return err
+	})
+	return resp, err
+}
+
+// checkedRequest performs a single request, reporting any but an expected response code as an error.
+func checkedRequest(r *httpRequest, kind string, p payload) (*httpResponse, error) {
 	rsp, err := r.makeRequest(kind, p)
 	if (err == nil) && notGood(rsp.Code, expected) {
 		return rsp, newError(r.URL, expected, rsp)
@@ -69,85 +287,53 @@ func makeRequest(r *httpRequest, kind string, p payload) (*httpResponse, error)
 // getResponseFromJSON shim performs a GET request, checking for a positive outcome.
 // See simplehttp.GetResponseFromJSON for more details.
 func getResponseFromJSON(r *httpRequest, v interface{}) error {
-	r.addHeader("User-Agent", MailgunGoUserAgent)
-	response, err := r.makeGetRequest()
+	response, err := makeRequest(r, "GET", nil)
 	if err != nil {
 		return err
 	}
-	if notGood(response.Code, expected) {
-		return newError(r.URL, expected, response)
-	}
 	return response.parseFromJSON(v)
 }
 
 // postResponseFromJSON shim performs a POST request, checking for a positive outcome.
 // See simplehttp.PostResponseFromJSON for more details.
 func postResponseFromJSON(r *httpRequest, p payload, v interface{}) error {
-	r.addHeader("User-Agent", MailgunGoUserAgent)
-	response, err := r.makePostRequest(p)
+	response, err := makeRequest(r, "POST", p)
 	if err != nil {
 		return err
 	}
-	if notGood(response.Code, expected) {
-		return newError(r.URL, expected, response)
-	}
 	return response.parseFromJSON(v)
 }
 
 // putResponseFromJSON shim performs a PUT request, checking for a positive outcome.
 // See simplehttp.PutResponseFromJSON for more details.
 func putResponseFromJSON(r *httpRequest, p payload, v interface{}) error {
-	r.addHeader("User-Agent", MailgunGoUserAgent)
-	response, err := r.makePutRequest(p)
+	response, err := makeRequest(r, "PUT", p)
 	if err != nil {
 		return err
 	}
-	if notGood(response.Code, expected) {
-		return newError(r.URL, expected, response)
-	}
 	return response.parseFromJSON(v)
 }
 
 // makeGetRequest shim performs a GET request, checking for a positive outcome.
 // See simplehttp.MakeGetRequest for more details.
 func makeGetRequest(r *httpRequest) (*httpResponse, error) {
-	r.addHeader("User-Agent", MailgunGoUserAgent)
-	rsp, err := r.makeGetRequest()
-	if (err == nil) && notGood(rsp.Code, expected) {
-		return rsp, newError(r.URL, expected, rsp)
-	}
-	return rsp, err
+	return makeRequest(r, "GET", nil)
 }
 
 // makePostRequest shim performs a POST request, checking for a positive outcome.
 // See simplehttp.MakePostRequest for more details.
 func makePostRequest(r *httpRequest, p payload) (*httpResponse, error) {
-	r.addHeader("User-Agent", MailgunGoUserAgent)
-	rsp, err := r.makePostRequest(p)
-	if (err == nil) && notGood(rsp.Code, expected) {
-		return rsp, newError(r.URL, expected, rsp)
-	}
-	return rsp, err
+	return makeRequest(r, "POST", p)
 }
 
 // makePutRequest shim performs a PUT request, checking for a positive outcome.
 // See simplehttp.MakePutRequest for more details.
 func makePutRequest(r *httpRequest, p payload) (*httpResponse, error) {
-	r.addHeader("User-Agent", MailgunGoUserAgent)
-	rsp, err := r.makePutRequest(p)
-	if (err == nil) && notGood(rsp.Code, expected) {
-		return rsp, newError(r.URL, expected, rsp)
-	}
-	return rsp, err
+	return makeRequest(r, "PUT", p)
 }
 
 // makeDeleteRequest shim performs a DELETE request, checking for a positive outcome.
 // See simplehttp.MakeDeleteRequest for more details.
 func makeDeleteRequest(r *httpRequest) (*httpResponse, error) {
-	r.addHeader("User-Agent", MailgunGoUserAgent)
-	rsp, err := r.makeDeleteRequest()
-	if (err == nil) && notGood(rsp.Code, expected) {
-		return rsp, newError(r.URL, expected, rsp)
-	}
-	return rsp, err
+	return makeRequest(r, "DELETE", nil)
 }
@@ -0,0 +1,112 @@
+package mailgun
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"time"
+)
+
+// A RetryPolicy tells a client how to retry API calls which fail for reasons that may pass,
+// namely rate limiting (HTTP 429), server-side failures (HTTP 5xx), and transport failures such as timeouts;
+// see APIError's Retryable field.
+// MaxAttempts caps the number of times a call is made, including the first; values below 2 disable retries.
+// The client waits InitialBackoff before the first retry,
+// then multiplies the wait by Multiplier for each retry after, up to MaxBackoff.
+// A Multiplier below 1 is treated as 2, and a zero MaxBackoff leaves the wait uncapped.
+// Jitter, between 0 and 1, randomizes each wait by up to that fraction either way,
+// so that many clients failing together don't retry in lock step.
+// When Mailgun rate limits a call and says how long to wait, with a Retry-After header,
+// the client waits that long instead, up to MaxRetryAfter; a zero MaxRetryAfter leaves that wait uncapped.
+//
+// Retries apply to every call, including Send.  Lest a message whose call timed out after Mailgun accepted it
+// be sent twice, each send carries an idempotency key, the same for every attempt; see SendWithIdempotencyKey.
+// Other calls carry no such key, and may take effect twice.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         float64
+	MaxRetryAfter  time.Duration
+}
+
+// WithRetryPolicy arranges for the client to retry failed API calls according to the given policy.
+// Clients made without it make each call once.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(m *MailgunImpl) {
+		m.retryPolicy = p
+	}
+}
+
+// wait gives the time to wait before the given retry, counting from one, of a call which failed with err.
+func (p RetryPolicy) wait(retry int, err *APIError) time.Duration {
+	if d := err.RetryAfter(); d > 0 {
+		if p.MaxRetryAfter > 0 && d > p.MaxRetryAfter {
+			return p.MaxRetryAfter
+		}
+		return d
+	}
+	return p.backoff(retry)
+}
+
+// backoff gives the time to wait before the given retry, counting from one.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	wait := float64(p.InitialBackoff)
+	for i := 1; i < retry; i++ {
+		wait *= multiplier
+		if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		wait += wait * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(wait)
+}
+
+// sleep waits for d to pass, or for ctx to end, whichever comes first.
+// It returns the context's error in the latter case.
+func sleep(ctx context.Context, d time.Duration) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// bufferedPayload holds a payload already rendered, so that it can be sent more than once.
+// Payloads built from readers can otherwise be rendered only once.
+type bufferedPayload struct {
+	data        []byte
+	contentType string
+}
+
+func newBufferedPayload(p payload) (*bufferedPayload, error) {
+	buf, err := p.getPayloadBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return &bufferedPayload{data: buf.Bytes(), contentType: p.getContentType()}, nil
+}
+
+func (b *bufferedPayload) getPayloadBuffer() (*bytes.Buffer, error) {
+	return bytes.NewBuffer(b.data), nil
+}
+
+func (b *bufferedPayload) getContentType() string {
+	return b.contentType
+}
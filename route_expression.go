@@ -0,0 +1,242 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// RouteTestResult reports how TestRoute found a route expression to treat a sample message.
+// Matched tells whether the expression matches the message.
+// Actions lists what the routes already configured for the account would do with the message,
+// in the order Mailgun would do it, so that a new expression can be judged alongside the pipeline it joins.
+// SkippedRoutes lists, by ID and with the reason, the configured routes whose expressions TestRoute couldn't evaluate,
+// such as those using regular expression features Go lacks; Actions leaves them out, and may be incomplete for it.
+type RouteTestResult struct {
+	Matched       bool
+	Actions       []string
+	SkippedRoutes []string
+}
+
+// routeMessage holds the parts of an inbound message that route filters examine.
+type routeMessage struct {
+	recipient string
+	headers   map[string]string
+}
+
+// header returns the named header of the message, if it has one; header names are case-insensitive.
+func (rm routeMessage) header(name string) (string, bool) {
+	v, ok := rm.headers[strings.ToLower(name)]
+	return v, ok
+}
+
+// A routeFilter is one of the filters of a route expression, such as match_recipient("pattern").
+type routeFilter func(routeMessage) bool
+
+// TestRoute checks a route expression against a sample message before a route with it is deployed,
+// which helps in working out complex filters.
+// Mailgun offers no way to try an expression without saving a route, so the SDK evaluates the expression itself,
+// understanding match_recipient, match_header, and catch_all filters, combined with and.
+// Patterns are matched from the start of the recipient or header, as Mailgun does, using Go's regexp syntax,
+// which agrees with Mailgun's for the patterns routes commonly use.
+// Only the From: and Subject: headers are known, from the message given, along with To:, which holds the recipient;
+// bodyPlain is accepted for completeness, but no filter examines the body.
+// An error results if the expression can't be parsed, or if the account's routes can't be retrieved;
+// configured routes which can't be parsed are skipped, and reported in the result.
+func (mg *MailgunImpl) TestRoute(ctx context.Context, expression, recipient, from, subject, bodyPlain string) (*RouteTestResult, error) {
+	match, err := parseRouteExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	msg := routeMessage{
+		recipient: recipient,
+		headers:   map[string]string{"from": from, "subject": subject, "to": recipient},
+	}
+
+	var routes []Route
+	it := mg.ListRoutes(ListOptions{})
+	for it.Next(ctx) {
+		routes = append(routes, it.Item())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	actions, skipped := routeActions(routes, msg)
+	return &RouteTestResult{Matched: match(msg), Actions: actions, SkippedRoutes: skipped}, nil
+}
+
+// routeActions gathers the actions of every route matching a message, by order of priority,
+// stopping after a matching route with a stop() action.
+// It skips routes whose expressions can't be parsed, describing each in skipped.
+func routeActions(routes []Route, msg routeMessage) (actions, skipped []string) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Priority < routes[j].Priority
+	})
+	for _, route := range routes {
+		match, err := parseRouteExpression(route.Expression)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("route %s: %v", route.ID, err))
+			continue
+		}
+		if !match(msg) {
+			continue
+		}
+		actions = append(actions, route.Actions...)
+		if containsString(route.Actions, "stop()") {
+			break
+		}
+	}
+	return actions, skipped
+}
+
+// parseRouteExpression compiles a route expression into a filter which matches messages
+// if every filter of the expression does.
+func parseRouteExpression(expression string) (routeFilter, error) {
+	p := &routeParser{src: expression}
+	var filters []routeFilter
+	for {
+		f, err := p.filter()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+		if p.atEnd() {
+			break
+		}
+		if word := p.ident(); word != "and" {
+			return nil, p.errorf("expected and between filters; got %q", word)
+		}
+	}
+	return func(msg routeMessage) bool {
+		for _, f := range filters {
+			if !f(msg) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// routeParser reads a route expression from left to right.
+type routeParser struct {
+	src string
+	pos int
+}
+
+func (p *routeParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("route expression %q, at offset %d: %s", p.src, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *routeParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *routeParser) atEnd() bool {
+	p.skipSpace()
+	return p.pos == len(p.src)
+}
+
+// ident reads a name, such as match_recipient or and.
+func (p *routeParser) ident() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsLetter(rune(p.src[p.pos]))) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// expect reads a single punctuation character.
+func (p *routeParser) expect(c byte) error {
+	p.skipSpace()
+	if p.pos == len(p.src) || p.src[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+// str reads a double-quoted string.  A backslash escapes a quote; other backslashes are kept,
+// for the pattern to interpret.
+func (p *routeParser) str() (string, error) {
+	if err := p.expect('"'); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch {
+		case c == '"':
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.src) && p.src[p.pos] == '"':
+			b.WriteByte('"')
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// pattern reads a string holding a regular expression, anchored at the start as Mailgun anchors patterns.
+func (p *routeParser) pattern() (*regexp.Regexp, error) {
+	s, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile("^(?:" + s + ")")
+	if err != nil {
+		return nil, p.errorf("bad pattern %q: %v", s, err)
+	}
+	return re, nil
+}
+
+// filter reads a single filter, with its arguments.
+func (p *routeParser) filter() (routeFilter, error) {
+	name := p.ident()
+	if name == "" {
+		return nil, p.errorf("expected a filter")
+	}
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	var f routeFilter
+	switch name {
+	case "catch_all":
+		f = func(routeMessage) bool { return true }
+	case "match_recipient":
+		re, err := p.pattern()
+		if err != nil {
+			return nil, err
+		}
+		f = func(msg routeMessage) bool { return re.MatchString(msg.recipient) }
+	case "match_header":
+		header, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(','); err != nil {
+			return nil, err
+		}
+		re, err := p.pattern()
+		if err != nil {
+			return nil, err
+		}
+		f = func(msg routeMessage) bool {
+			v, ok := msg.header(header)
+			return ok && re.MatchString(v)
+		}
+	default:
+		return nil, p.errorf("unknown filter %s", name)
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
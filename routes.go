@@ -1,17 +1,25 @@
 package mailgun
 
 import (
+	"context"
 	"strconv"
+	"strings"
 )
 
 // A Route structure contains information on a configured or to-be-configured route.
 // The Priority field indicates how soon the route works relative to other configured routes.
+// Lower numbers come first, so a route of priority 0 is consulted before one of priority 10.
 // Routes of equal priority are consulted in chronological order.
+// Every matching route's actions apply, unless one of them calls stop().
 // The Description field provides a human-readable description for the route.
 // Mailgun ignores this field except to provide the description when viewing the Mailgun web control panel.
-// The Expression field lets you specify a pattern to match incoming messages against.
+// The Expression field lets you specify a pattern to match incoming messages against,
+// written in Mailgun's filter syntax: match_recipient("pattern"), match_header("header", "pattern"), or catch_all(),
+// where patterns are regular expressions, and filters may be combined with and.
+// Every route needs an expression; use catch_all() to match everything.
 // The Actions field contains strings specifying what to do
-// with any message which matches the provided expression.
+// with any message which matches the provided expression,
+// such as forward("https://example.com/inbound"), store(), or stop().
 // The CreatedAt field provides a time-stamp for when the route came into existence.
 // Finally, the ID field provides a unique identifier for this route.
 //
@@ -33,14 +41,19 @@ type Route struct {
 // messages sent to a specfic address on your domain.
 // See the Mailgun documentation for more information.
 func (mg *MailgunImpl) GetRoutes(limit, skip int) (int, []Route, error) {
-	r := newHTTPRequest(generatePublicApiUrl(routesEndpoint))
+	return mg.getRoutes(context.Background(), limit, skip)
+}
+
+func (mg *MailgunImpl) getRoutes(ctx context.Context, limit, skip int) (int, []Route, error) {
+	r := newHTTPRequest(generatePublicApiUrl(mg, routesEndpoint))
+	r.setContext(ctx)
 	if limit != DefaultLimit {
 		r.addParameter("limit", strconv.Itoa(limit))
 	}
 	if skip != DefaultSkip {
 		r.addParameter("skip", strconv.Itoa(skip))
 	}
-	r.setClient(mg.Client())
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 
 	var envelope struct {
@@ -58,9 +71,13 @@ func (mg *MailgunImpl) GetRoutes(limit, skip int) (int, []Route, error) {
 // The route structure you provide serves as a template, and
 // only a subset of the fields influence the operation.
 // See the Route structure definition for more details.
+// CreateRoute returns ErrEmptyParam without calling Mailgun if the prototype has no expression.
 func (mg *MailgunImpl) CreateRoute(prototype Route) (Route, error) {
-	r := newHTTPRequest(generatePublicApiUrl(routesEndpoint))
-	r.setClient(mg.Client())
+	if strings.TrimSpace(prototype.Expression) == "" {
+		return Route{}, ErrEmptyParam
+	}
+	r := newHTTPRequest(generatePublicApiUrl(mg, routesEndpoint))
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newUrlEncodedPayload()
 	p.addValue("priority", strconv.Itoa(prototype.Priority))
@@ -74,15 +91,18 @@ func (mg *MailgunImpl) CreateRoute(prototype Route) (Route, error) {
 		*Route  `json:"route"`
 	}
 	err := postResponseFromJSON(r, p, &envelope)
-	return *envelope.Route, err
+	if err != nil || envelope.Route == nil {
+		return Route{}, err
+	}
+	return *envelope.Route, nil
 }
 
 // DeleteRoute removes the specified route from your domain's configuration.
 // To avoid ambiguity, Mailgun identifies the route by unique ID.
 // See the Route structure definition and the Mailgun API documentation for more details.
 func (mg *MailgunImpl) DeleteRoute(id string) error {
-	r := newHTTPRequest(generatePublicApiUrl(routesEndpoint) + "/" + id)
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generatePublicApiUrl(mg, routesEndpoint) + "/" + id)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	_, err := makeDeleteRequest(r)
 	return err
@@ -90,23 +110,26 @@ func (mg *MailgunImpl) DeleteRoute(id string) error {
 
 // GetRouteByID retrieves the complete route definition associated with the unique route ID.
 func (mg *MailgunImpl) GetRouteByID(id string) (Route, error) {
-	r := newHTTPRequest(generatePublicApiUrl(routesEndpoint) + "/" + id)
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generatePublicApiUrl(mg, routesEndpoint) + "/" + id)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	var envelope struct {
 		Message string `json:"message"`
 		*Route  `json:"route"`
 	}
 	err := getResponseFromJSON(r, &envelope)
-	return *envelope.Route, err
+	if err != nil || envelope.Route == nil {
+		return Route{}, err
+	}
+	return *envelope.Route, nil
 }
 
 // UpdateRoute provides an "in-place" update of the specified route.
 // Only those route fields which are non-zero or non-empty are updated.
 // All other fields remain as-is.
 func (mg *MailgunImpl) UpdateRoute(id string, route Route) (Route, error) {
-	r := newHTTPRequest(generatePublicApiUrl(routesEndpoint) + "/" + id)
-	r.setClient(mg.Client())
+	r := newHTTPRequest(generatePublicApiUrl(mg, routesEndpoint) + "/" + id)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newUrlEncodedPayload()
 	if route.Priority != 0 {
@@ -0,0 +1,87 @@
+package mailgun
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	sandboxRecipientsEndpoint = "sandbox/auth_recipients"
+)
+
+// sandboxRecipient describes one authorized recipient of a sandbox domain.
+// Activated reports whether the recipient has confirmed they're willing to receive mail from the sandbox.
+type sandboxRecipient struct {
+	Email     string `json:"email"`
+	Activated bool   `json:"activated"`
+}
+
+// ListSandboxAllowedRecipients returns the addresses authorized to receive mail from the client's sandbox domain.
+// Mailgun asks each recipient to confirm their authorization; the list includes those who have yet to do so.
+// Use IsSandboxRecipientAllowed to learn whether Mailgun will actually deliver to an address.
+func (mg *MailgunImpl) ListSandboxAllowedRecipients(ctx context.Context) ([]string, error) {
+	recipients, err := mg.getSandboxRecipients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	emails := make([]string, len(recipients))
+	for i, r := range recipients {
+		emails[i] = r.Email
+	}
+	return emails, nil
+}
+
+// AddSandboxAllowedRecipient authorizes the address to receive mail from the client's sandbox domain.
+// Mailgun then e-mails the address, asking its owner to confirm.
+func (mg *MailgunImpl) AddSandboxAllowedRecipient(ctx context.Context, email string) error {
+	r := newHTTPRequest(generateV5ApiUrl(mg, sandboxRecipientsEndpoint))
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	r.addParameter("domain", mg.Domain())
+	p := newUrlEncodedPayload()
+	p.addValue("email", email)
+	_, err := makePostRequest(r, p)
+	return err
+}
+
+// DeleteSandboxAllowedRecipient withdraws the address's authorization to receive mail from the client's sandbox domain.
+func (mg *MailgunImpl) DeleteSandboxAllowedRecipient(ctx context.Context, email string) error {
+	r := newHTTPRequest(generateV5ApiUrl(mg, sandboxRecipientsEndpoint) + "/" + email)
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	r.addParameter("domain", mg.Domain())
+	_, err := makeDeleteRequest(r)
+	return err
+}
+
+// IsSandboxRecipientAllowed reports whether the client's sandbox domain will deliver mail to the address:
+// that is, whether the address is authorized, and its owner has confirmed the authorization.
+// Sending to any other address from a sandbox domain fails.
+// Addresses are compared without regard to case.
+func (mg *MailgunImpl) IsSandboxRecipientAllowed(ctx context.Context, email string) (bool, error) {
+	recipients, err := mg.getSandboxRecipients(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range recipients {
+		if strings.EqualFold(r.Email, email) {
+			return r.Activated, nil
+		}
+	}
+	return false, nil
+}
+
+func (mg *MailgunImpl) getSandboxRecipients(ctx context.Context) ([]sandboxRecipient, error) {
+	r := newHTTPRequest(generateV5ApiUrl(mg, sandboxRecipientsEndpoint))
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	r.addParameter("domain", mg.Domain())
+	var envelope struct {
+		Recipients []sandboxRecipient `json:"recipients"`
+	}
+	err := getResponseFromJSON(r, &envelope)
+	return envelope.Recipients, err
+}
@@ -1,6 +1,8 @@
 package mailgun
 
 import (
+	"context"
+	"io"
 	"strconv"
 )
 
@@ -28,7 +30,7 @@ type complaintsEnvelope struct {
 // indicating that the message they received is, to them, spam.
 func (m *MailgunImpl) GetComplaints(limit, skip int) (int, []Complaint, error) {
 	r := newHTTPRequest(generateApiUrl(m, complaintsEndpoint))
-	r.setClient(m.Client())
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 
 	if limit != -1 {
@@ -50,7 +52,7 @@ func (m *MailgunImpl) GetComplaints(limit, skip int) (int, []Complaint, error) {
 // If no complaint exists, the Complaint instance returned will be empty.
 func (m *MailgunImpl) GetSingleComplaint(address string) (Complaint, error) {
 	r := newHTTPRequest(generateApiUrl(m, complaintsEndpoint) + "/" + address)
-	r.setClient(m.Client())
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 
 	var c Complaint
@@ -62,7 +64,7 @@ func (m *MailgunImpl) GetSingleComplaint(address string) (Complaint, error) {
 // from your domain.
 func (m *MailgunImpl) CreateComplaint(address string) error {
 	r := newHTTPRequest(generateApiUrl(m, complaintsEndpoint))
-	r.setClient(m.Client())
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 	p := newUrlEncodedPayload()
 	p.addValue("address", address)
@@ -70,11 +72,49 @@ func (m *MailgunImpl) CreateComplaint(address string) error {
 	return err
 }
 
+// complaintImport is the form Mailgun takes complaints in, when importing many at once.
+type complaintImport struct {
+	Address   string `json:"address"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// ImportComplaints registers many addresses as recipients who have complained at once,
+// posting them to Mailgun as JSON in batches of up to 1000, the most Mailgun accepts per call.
+// Each complaint needs at least its Address set; CreatedAt, if set, must be in RFC-2822 form.  Counts are ignored.
+// If a batch fails, the complaints of earlier batches remain registered.
+func (m *MailgunImpl) ImportComplaints(ctx context.Context, complaints []Complaint) error {
+	for start := 0; start < len(complaints); start += maxImportBatchSize {
+		end := start + maxImportBatchSize
+		if end > len(complaints) {
+			end = len(complaints)
+		}
+		batch := make([]complaintImport, 0, end-start)
+		for _, c := range complaints[start:end] {
+			batch = append(batch, complaintImport{Address: c.Address, CreatedAt: c.CreatedAt})
+		}
+		if err := importSuppressions(ctx, m, complaintsEndpoint, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportComplaints downloads the named domain's spam complaints as CSV, in the manner of ExportBounces.
+// The caller must close the reader returned.
+func (m *MailgunImpl) ExportComplaints(ctx context.Context, domain string) (io.ReadCloser, error) {
+	return exportSuppressions(ctx, m, domain, complaintsEndpoint)
+}
+
+// WriteComplaintsCSV works as ExportComplaints, but copies the CSV into w.
+func (m *MailgunImpl) WriteComplaintsCSV(ctx context.Context, domain string, w io.Writer) error {
+	return writeSuppressionsCSV(ctx, m, domain, complaintsEndpoint, w)
+}
+
 // DeleteComplaint removes a previously registered e-mail address from the list of people who complained
 // of receiving spam from your domain.
 func (m *MailgunImpl) DeleteComplaint(address string) error {
 	r := newHTTPRequest(generateApiUrl(m, complaintsEndpoint) + "/" + address)
-	r.setClient(m.Client())
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 	_, err := makeDeleteRequest(r)
 	return err
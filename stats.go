@@ -1,7 +1,12 @@
 package mailgun
 
 import (
+	"context"
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -13,6 +18,117 @@ type Stat struct {
 	Tags       map[string]int `json:"tags"`
 }
 
+// StatsOptions narrows the statistics gathered.
+// Start and End bound the period; leave either at its zero value to leave the period open at that end.
+// Event limits the statistics to the named events (e.g., "delivered" or "opened");
+// leave it empty to gather all of them.  Functions built on the events API, like GetClicksForURL,
+// determine the events of interest themselves, and ignore Event.
+// Resolution sets the period each point of GetStatsTotal covers: "hour", "day", or "month".
+// If left blank, Mailgun assumes "day".
+type StatsOptions struct {
+	Start, End time.Time
+	Event      []string
+	Resolution string
+}
+
+// A StatPoint counts the events of a single period, as GetStatsTotal reports them.
+// Time marks the start of the period.  Counters are keyed by event name, such as "delivered";
+// the counter for "failed" totals both temporary and permanent failures.
+type StatPoint struct {
+	Time     time.Time
+	Counters map[string]StatCounter
+}
+
+// A StatCounter counts a single event over a period.
+// For deliveries, ESP counts those made over SMTP to recipients' mail servers,
+// and Webhook those posted over HTTP; both remain zero for other events.
+// For failures, Temporary counts those Mailgun retries, and Permanent those it gives up on;
+// both remain zero for other events.
+type StatCounter struct {
+	Total     int
+	ESP       int
+	Webhook   int
+	Temporary int
+	Permanent int
+}
+
+// allStatsEvents lists the events reported by the stats API.
+var allStatsEvents = []string{"accepted", "delivered", "failed", "stored", "opened", "clicked", "unsubscribed", "complained"}
+
+// DomainStats totals the events recorded for a single domain over the period given to GetAggregateStats.
+// Failures are broken down into temporary failures, which Mailgun retries, and permanent ones, which it does not.
+// Counts for events not requested in StatsOptions remain zero.
+type DomainStats struct {
+	Domain            string
+	Accepted          int
+	Delivered         int
+	TemporaryFailures int
+	PermanentFailures int
+	Stored            int
+	Opened            int
+	Clicked           int
+	Unsubscribed      int
+	Complained        int
+}
+
+// TotalStats totals the events recorded for a single domain over the period given to GetDomainStats.
+// Sent counts the outgoing messages Mailgun accepted, while Accepted also counts incoming ones, bound for routes.
+// Counts for events not requested in StatsOptions remain zero.
+type TotalStats struct {
+	Sent         StatCounter
+	Accepted     StatCounter
+	Delivered    StatCounter
+	Failed       StatCounter
+	Opened       StatCounter
+	Clicked      StatCounter
+	Unsubscribed StatCounter
+	Complained   StatCounter
+	Stored       StatCounter
+}
+
+// AggregateStatsError reports the domains for which GetAggregateStats failed to gather statistics, along with why.
+type AggregateStatsError map[string]error
+
+// Error() lists the failed domains, in alphabetical order.
+func (e AggregateStatsError) Error() string {
+	domains := make([]string, 0, len(e))
+	for d := range e {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	msgs := make([]string, len(domains))
+	for i, d := range domains {
+		msgs[i] = fmt.Sprintf("%s: %v", d, e[d])
+	}
+	return "stats unavailable for " + strings.Join(msgs, "; ")
+}
+
+type statsCounter struct {
+	Total    int `json:"total"`
+	SMTP     int `json:"smtp"`
+	HTTP     int `json:"http"`
+	Outgoing int `json:"outgoing"`
+}
+
+type statsTotalItem struct {
+	Time         string       `json:"time"`
+	Accepted     statsCounter `json:"accepted"`
+	Delivered    statsCounter `json:"delivered"`
+	Stored       statsCounter `json:"stored"`
+	Opened       statsCounter `json:"opened"`
+	Clicked      statsCounter `json:"clicked"`
+	Unsubscribed statsCounter `json:"unsubscribed"`
+	Complained   statsCounter `json:"complained"`
+	Failed       struct {
+		Temporary map[string]int `json:"temporary"`
+		Permanent map[string]int `json:"permanent"`
+	} `json:"failed"`
+}
+
+type statsTotalEnvelope struct {
+	Stats []statsTotalItem `json:"stats"`
+}
+
 type statsEnvelope struct {
 	TotalCount int    `json:"total_count"`
 	Items      []Stat `json:"items"`
@@ -38,7 +154,7 @@ func (m *MailgunImpl) GetStats(limit int, skip int, startDate *time.Time, event
 	for _, e := range event {
 		r.addParameter("event", e)
 	}
-	r.setClient(m.Client())
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
 
 	var res statsEnvelope
@@ -50,11 +166,163 @@ func (m *MailgunImpl) GetStats(limit int, skip int, startDate *time.Time, event
 	}
 }
 
-// DeleteTag removes all counters for a particular tag, including the tag itself.
-func (m *MailgunImpl) DeleteTag(tag string) error {
-	r := newHTTPRequest(generateApiUrl(m, deleteTagEndpoint) + "/" + tag)
-	r.setClient(m.Client())
+// GetAggregateStats gathers the statistics of each of the domains concurrently,
+// making at most the client's concurrency limit of API calls at once (see WithConcurrencyLimit).
+// The results are keyed by domain.
+// If statistics for some domains cannot be gathered, the results for the remaining domains are still returned,
+// along with an AggregateStatsError detailing the failures.
+func (m *MailgunImpl) GetAggregateStats(ctx context.Context, domains []string, opts StatsOptions) (map[string]*DomainStats, error) {
+	results := make(map[string]*DomainStats, len(domains))
+	failures := make(AggregateStatsError)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, m.concurrencyLimit)
+	for _, domain := range domains {
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			stats, err := m.getDomainStats(ctx, domain, opts)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failures[domain] = err
+			} else {
+				results[domain] = stats
+			}
+		}(domain)
+	}
+	wg.Wait()
+	if len(failures) > 0 {
+		return results, failures
+	}
+	return results, nil
+}
+
+// GetStatsTotal retrieves the named domain's statistics as a series of points, one per period of opts.Resolution.
+func (m *MailgunImpl) GetStatsTotal(ctx context.Context, domain string, opts StatsOptions) ([]StatPoint, error) {
+	items, err := m.getStatsTotal(ctx, domain, opts)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]StatPoint, len(items))
+	for i, item := range items {
+		t, err := parseMailgunTime(item.Time)
+		if err != nil {
+			return nil, err
+		}
+		points[i] = StatPoint{
+			Time: t,
+			Counters: map[string]StatCounter{
+				"accepted":     {Total: item.Accepted.Total},
+				"delivered":    {Total: item.Delivered.Total, ESP: item.Delivered.SMTP, Webhook: item.Delivered.HTTP},
+				"failed":       failureCounter(item),
+				"stored":       {Total: item.Stored.Total},
+				"opened":       {Total: item.Opened.Total},
+				"clicked":      {Total: item.Clicked.Total},
+				"unsubscribed": {Total: item.Unsubscribed.Total},
+				"complained":   {Total: item.Complained.Total},
+			},
+		}
+	}
+	return points, nil
+}
+
+// GetDomainStats totals the named domain's statistics over the period of opts,
+// for dashboards to show delivery health at a glance.
+// Use GetStatsTotal to see how the counts vary over the period instead.
+func (m *MailgunImpl) GetDomainStats(ctx context.Context, domain string, opts StatsOptions) (*TotalStats, error) {
+	items, err := m.getStatsTotal(ctx, domain, opts)
+	if err != nil {
+		return nil, err
+	}
+	stats := &TotalStats{}
+	for _, item := range items {
+		stats.Sent.Total += item.Accepted.Outgoing
+		stats.Accepted.Total += item.Accepted.Total
+		stats.Delivered.Total += item.Delivered.Total
+		stats.Delivered.ESP += item.Delivered.SMTP
+		stats.Delivered.Webhook += item.Delivered.HTTP
+		failed := failureCounter(item)
+		stats.Failed.Total += failed.Total
+		stats.Failed.Temporary += failed.Temporary
+		stats.Failed.Permanent += failed.Permanent
+		stats.Opened.Total += item.Opened.Total
+		stats.Clicked.Total += item.Clicked.Total
+		stats.Unsubscribed.Total += item.Unsubscribed.Total
+		stats.Complained.Total += item.Complained.Total
+		stats.Stored.Total += item.Stored.Total
+	}
+	return stats, nil
+}
+
+// getDomainStats totals the statistics of a single domain.
+func (m *MailgunImpl) getDomainStats(ctx context.Context, domain string, opts StatsOptions) (*DomainStats, error) {
+	total, err := m.GetDomainStats(ctx, domain, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &DomainStats{
+		Domain:            domain,
+		Accepted:          total.Accepted.Total,
+		Delivered:         total.Delivered.Total,
+		TemporaryFailures: total.Failed.Temporary,
+		PermanentFailures: total.Failed.Permanent,
+		Stored:            total.Stored.Total,
+		Opened:            total.Opened.Total,
+		Clicked:           total.Clicked.Total,
+		Unsubscribed:      total.Unsubscribed.Total,
+		Complained:        total.Complained.Total,
+	}, nil
+}
+
+// getStatsTotal retrieves the statistics of a single domain, one item per period.
+func (m *MailgunImpl) getStatsTotal(ctx context.Context, domain string, opts StatsOptions) ([]statsTotalItem, error) {
+	r := newHTTPRequest(generateApiUrlWithDomain(m, domain, statsEndpoint) + "/total")
+	r.setContext(ctx)
+	r.setClient(m)
 	r.setBasicAuth(basicAuthUser, m.ApiKey())
-	_, err := makeDeleteRequest(r)
-	return err
+	events := opts.Event
+	if len(events) == 0 {
+		events = allStatsEvents
+	}
+	for _, e := range events {
+		r.addParameter("event", e)
+	}
+	if !opts.Start.IsZero() {
+		r.addParameter("start", strconv.FormatInt(opts.Start.Unix(), 10))
+	}
+	if !opts.End.IsZero() {
+		r.addParameter("end", strconv.FormatInt(opts.End.Unix(), 10))
+	}
+	if opts.Resolution != "" {
+		r.addParameter("resolution", opts.Resolution)
+	}
+
+	var envelope statsTotalEnvelope
+	if err := getResponseFromJSON(r, &envelope); err != nil {
+		return nil, err
+	}
+	return envelope.Stats, nil
+}
+
+// failureCounter counts the failures of a single period, broken down into temporary and permanent ones.
+func failureCounter(item statsTotalItem) StatCounter {
+	temporary, permanent := failureTotal(item.Failed.Temporary), failureTotal(item.Failed.Permanent)
+	return StatCounter{Total: temporary + permanent, Temporary: temporary, Permanent: permanent}
+}
+
+// failureTotal totals a breakdown of failures by reason.
+// Mailgun includes the total in some breakdowns, but not others.
+func failureTotal(reasons map[string]int) int {
+	if total, ok := reasons["total"]; ok {
+		return total
+	}
+	sum := 0
+	for _, n := range reasons {
+		sum += n
+	}
+	return sum
 }
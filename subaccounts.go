@@ -0,0 +1,128 @@
+package mailgun
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+const (
+	subaccountsEndpoint = "accounts/subaccounts"
+
+	// onBehalfOfHeader names the subaccount an API call acts for; see WithSubaccount.
+	onBehalfOfHeader = "X-Mailgun-On-Behalf-Of"
+)
+
+// A Subaccount is one of the accounts a Mailgun account on the Flex plan manages on behalf of its own customers,
+// as SaaS platforms do.  Status is "open" for subaccounts which may send mail, and "disabled" for those which may not.
+// CreatedAt gives the time Mailgun created the subaccount, in RFC-2822 form.
+type Subaccount struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	CreatedAt string `json:"created_at"`
+}
+
+type subaccountEnvelope struct {
+	Subaccount Subaccount `json:"subaccount"`
+}
+
+// WithSubaccount makes every API call of the client on behalf of the subaccount with the given ID,
+// so that, for instance, it sends mail from the subaccount's domains.
+// The client authenticates with the API key of the primary account.
+func WithSubaccount(id string) Option {
+	return func(m *MailgunImpl) {
+		m.subaccount = id
+	}
+}
+
+// GetSubaccounts retrieves the account's subaccounts, paging through them as limit and skip direct.
+// Pass DefaultLimit and DefaultSkip to rely on Mailgun's paging defaults.
+func (mg *MailgunImpl) GetSubaccounts(ctx context.Context, limit, skip int) ([]Subaccount, error) {
+	r := newHTTPRequest(generateV5ApiUrl(mg, subaccountsEndpoint))
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	if limit != DefaultLimit {
+		r.addParameter("limit", strconv.Itoa(limit))
+	}
+	if skip != DefaultSkip {
+		r.addParameter("skip", strconv.Itoa(skip))
+	}
+
+	var envelope struct {
+		Subaccounts []Subaccount `json:"subaccounts"`
+	}
+	if err := getResponseFromJSON(r, &envelope); err != nil {
+		return nil, err
+	}
+	return envelope.Subaccounts, nil
+}
+
+// GetSubaccount retrieves a single subaccount of the account.
+func (mg *MailgunImpl) GetSubaccount(ctx context.Context, id string) (*Subaccount, error) {
+	if id == "" {
+		return nil, ErrEmptyParam
+	}
+	r := newHTTPRequest(generateSubaccountUrl(mg, id))
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+
+	var envelope subaccountEnvelope
+	if err := getResponseFromJSON(r, &envelope); err != nil {
+		return nil, err
+	}
+	return &envelope.Subaccount, nil
+}
+
+// CreateSubaccount creates a subaccount with the given name, and returns it as created.
+// Use its ID with WithSubaccount to act on its behalf.
+func (mg *MailgunImpl) CreateSubaccount(ctx context.Context, name string) (*Subaccount, error) {
+	if name == "" {
+		return nil, ErrEmptyParam
+	}
+	r := newHTTPRequest(generateV5ApiUrl(mg, subaccountsEndpoint))
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+
+	p := newUrlEncodedPayload()
+	p.addValue("name", name)
+	var envelope subaccountEnvelope
+	if err := postResponseFromJSON(r, p, &envelope); err != nil {
+		return nil, err
+	}
+	return &envelope.Subaccount, nil
+}
+
+// EnableSubaccount lets a disabled subaccount send mail again, and returns it as updated.
+func (mg *MailgunImpl) EnableSubaccount(ctx context.Context, id string) (*Subaccount, error) {
+	return mg.setSubaccountStatus(ctx, id, "enable")
+}
+
+// DisableSubaccount stops a subaccount from sending mail, and returns it as updated.
+func (mg *MailgunImpl) DisableSubaccount(ctx context.Context, id string) (*Subaccount, error) {
+	return mg.setSubaccountStatus(ctx, id, "disable")
+}
+
+func (mg *MailgunImpl) setSubaccountStatus(ctx context.Context, id, action string) (*Subaccount, error) {
+	if id == "" {
+		return nil, ErrEmptyParam
+	}
+	r := newHTTPRequest(generateSubaccountUrl(mg, id) + "/" + action)
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+
+	var envelope subaccountEnvelope
+	if err := postResponseFromJSON(r, newUrlEncodedPayload(), &envelope); err != nil {
+		return nil, err
+	}
+	return &envelope.Subaccount, nil
+}
+
+// generateSubaccountUrl generates the URL of one of the account's subaccounts.
+func generateSubaccountUrl(m Mailgun, id string) string {
+	return generateV5ApiUrl(m, subaccountsEndpoint) + "/" + url.PathEscape(id)
+}
@@ -0,0 +1,80 @@
+package mailgun
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// A TagItem describes one of the tags Mailgun has seen on a domain's messages.
+// FirstSeen and LastSeen give the times of the first and latest messages to carry the tag, in ISO-8601 form.
+type TagItem struct {
+	Tag         string `json:"tag"`
+	Description string `json:"description"`
+	FirstSeen   string `json:"first-seen"`
+	LastSeen    string `json:"last-seen"`
+}
+
+type tagsEnvelope struct {
+	Items []TagItem `json:"items"`
+}
+
+// GetTags retrieves the tags Mailgun has seen on the named domain's messages.
+// Limit caps the number of tags returned; pass DefaultLimit to rely on Mailgun's default of 100.
+// Mailgun returns at most 1000 tags at once.
+func (m *MailgunImpl) GetTags(ctx context.Context, domain string, limit int) ([]TagItem, error) {
+	r := newHTTPRequest(generateApiUrlWithDomain(m, domain, tagsEndpoint))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	if limit != DefaultLimit {
+		r.addParameter("limit", strconv.Itoa(limit))
+	}
+
+	var envelope tagsEnvelope
+	if err := getResponseFromJSON(r, &envelope); err != nil {
+		return nil, err
+	}
+	return envelope.Items, nil
+}
+
+// GetTag retrieves a single tag of the named domain.
+func (m *MailgunImpl) GetTag(ctx context.Context, domain, tag string) (*TagItem, error) {
+	if tag == "" {
+		return nil, ErrEmptyParam
+	}
+	r := newHTTPRequest(generateTagUrl(m, domain, tag))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+
+	var t TagItem
+	if err := getResponseFromJSON(r, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// DeleteTag removes all counters for a particular tag of the client's domain, including the tag itself.
+func (m *MailgunImpl) DeleteTag(tag string) error {
+	return m.DeleteDomainTag(context.Background(), m.Domain(), tag)
+}
+
+// DeleteDomainTag works as DeleteTag, but for the named domain.
+// Use it to clear away tags left behind by old campaigns.
+func (m *MailgunImpl) DeleteDomainTag(ctx context.Context, domain, tag string) error {
+	if tag == "" {
+		return ErrEmptyParam
+	}
+	r := newHTTPRequest(generateTagUrl(m, domain, tag))
+	r.setContext(ctx)
+	r.setClient(m)
+	r.setBasicAuth(basicAuthUser, m.ApiKey())
+	_, err := makeDeleteRequest(r)
+	return err
+}
+
+// generateTagUrl generates the URL of one of the named domain's tags.
+func generateTagUrl(m Mailgun, domain, tag string) string {
+	return generateApiUrlWithDomain(m, domain, tagsEndpoint) + "/" + url.PathEscape(tag)
+}
@@ -0,0 +1,293 @@
+package mailgun
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+const (
+	templatesEndpoint = "templates"
+
+	// templatesPageSize is the number of templates, or versions of a template, fetched per API call.
+	templatesPageSize = 100
+)
+
+// A TemplateEngine names the language a stored template is written in.
+type TemplateEngine string
+
+const (
+	// TemplateEngineHandlebars renders templates written in Handlebars.  This is the default.
+	TemplateEngineHandlebars TemplateEngine = "handlebars"
+	// TemplateEngineGo renders templates written for Go's text/template package.
+	TemplateEngineGo TemplateEngine = "go"
+)
+
+// A Template is a message body stored with Mailgun, for messages to name with SetTemplate
+// rather than carry bodies of their own.
+// Each template keeps one or more versions, of which one is active;
+// Version holds the active version, or the one asked for, when Mailgun reports it.
+// CreatedAt gives the time Mailgun created the template, in RFC-2822 form.
+type Template struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	CreatedAt   string          `json:"createdAt"`
+	Version     TemplateVersion `json:"version"`
+}
+
+// A TemplateVersion is one revision of a stored template.
+// Tag names the version within its template, and Template holds its source, written for Engine.
+// Active marks the version messages get unless they ask for another with SetTemplateVersion.
+type TemplateVersion struct {
+	Tag       string         `json:"tag"`
+	Template  string         `json:"template"`
+	Engine    TemplateEngine `json:"engine"`
+	CreatedAt string         `json:"createdAt"`
+	Comment   string         `json:"comment"`
+	Active    bool           `json:"active"`
+}
+
+type templateEnvelope struct {
+	Template Template `json:"template"`
+}
+
+type templatePaging struct {
+	Next string `json:"next"`
+}
+
+// CreateTemplate stores a new template for the client's domain.
+// The template needs at least a Name; if its Version has a Template, Mailgun stores that as its first, active version.
+// On success, the template is updated with what Mailgun reports of it, such as CreatedAt.
+func (mg *MailgunImpl) CreateTemplate(ctx context.Context, t *Template) error {
+	if t.Name == "" {
+		return ErrEmptyParam
+	}
+	r := newHTTPRequest(generateApiUrl(mg, templatesEndpoint))
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+
+	p := newUrlEncodedPayload()
+	p.addValue("name", t.Name)
+	if t.Description != "" {
+		p.addValue("description", t.Description)
+	}
+	if t.Version.Template != "" {
+		addTemplateVersionValues(p, &t.Version)
+	}
+
+	var envelope templateEnvelope
+	if err := postResponseFromJSON(r, p, &envelope); err != nil {
+		return err
+	}
+	*t = envelope.Template
+	return nil
+}
+
+// GetTemplate retrieves a stored template of the client's domain, along with its active version.
+func (mg *MailgunImpl) GetTemplate(ctx context.Context, name string) (*Template, error) {
+	if name == "" {
+		return nil, ErrEmptyParam
+	}
+	r := newHTTPRequest(generateTemplateUrl(mg, name))
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	r.addParameter("active", "yes")
+
+	var envelope templateEnvelope
+	if err := getResponseFromJSON(r, &envelope); err != nil {
+		return nil, err
+	}
+	return &envelope.Template, nil
+}
+
+// UpdateTemplate changes the description of a stored template, as named by its Name.
+// Use the version methods, such as CreateTemplateVersion, to change the template's content.
+func (mg *MailgunImpl) UpdateTemplate(ctx context.Context, t *Template) error {
+	if t.Name == "" {
+		return ErrEmptyParam
+	}
+	r := newHTTPRequest(generateTemplateUrl(mg, t.Name))
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+
+	p := newUrlEncodedPayload()
+	p.addValue("description", t.Description)
+	_, err := makePutRequest(r, p)
+	return err
+}
+
+// DeleteTemplate removes a stored template of the client's domain, along with all its versions.
+func (mg *MailgunImpl) DeleteTemplate(ctx context.Context, name string) error {
+	if name == "" {
+		return ErrEmptyParam
+	}
+	r := newHTTPRequest(generateTemplateUrl(mg, name))
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	_, err := makeDeleteRequest(r)
+	return err
+}
+
+// ListTemplates returns every template stored for the client's domain, fetching as many pages as it takes.
+// Mailgun leaves out the templates' versions; use GetTemplate or ListTemplateVersions for those.
+func (mg *MailgunImpl) ListTemplates(ctx context.Context) ([]Template, error) {
+	var templates []Template
+	next := generateApiUrl(mg, templatesEndpoint) + "?limit=" + strconv.Itoa(templatesPageSize)
+	for next != "" {
+		r := newHTTPRequest(next)
+		r.setContext(ctx)
+		r.setClient(mg)
+		r.setBasicAuth(basicAuthUser, mg.ApiKey())
+		var envelope struct {
+			Items  []Template     `json:"items"`
+			Paging templatePaging `json:"paging"`
+		}
+		if err := getResponseFromJSON(r, &envelope); err != nil {
+			return nil, err
+		}
+		if len(envelope.Items) == 0 {
+			break
+		}
+		templates = append(templates, envelope.Items...)
+		next = envelope.Paging.Next
+	}
+	return templates, nil
+}
+
+// CreateTemplateVersion adds a version to the named template.
+// The version needs at least a Tag and a Template; set Active to make messages use it from now on.
+// An empty Engine leaves Mailgun to assume Handlebars.
+func (mg *MailgunImpl) CreateTemplateVersion(ctx context.Context, templateName string, v *TemplateVersion) error {
+	if templateName == "" || v.Tag == "" || v.Template == "" {
+		return ErrEmptyParam
+	}
+	r := newHTTPRequest(generateTemplateUrl(mg, templateName) + "/versions")
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+
+	p := newUrlEncodedPayload()
+	addTemplateVersionValues(p, v)
+
+	var envelope templateEnvelope
+	if err := postResponseFromJSON(r, p, &envelope); err != nil {
+		return err
+	}
+	*v = envelope.Template.Version
+	return nil
+}
+
+// GetTemplateVersion retrieves one version of the named template, by tag.
+func (mg *MailgunImpl) GetTemplateVersion(ctx context.Context, templateName, tag string) (*TemplateVersion, error) {
+	if templateName == "" || tag == "" {
+		return nil, ErrEmptyParam
+	}
+	r := newHTTPRequest(generateTemplateUrl(mg, templateName) + "/versions/" + url.PathEscape(tag))
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+
+	var envelope templateEnvelope
+	if err := getResponseFromJSON(r, &envelope); err != nil {
+		return nil, err
+	}
+	return &envelope.Template.Version, nil
+}
+
+// UpdateTemplateVersion changes the content, comment, or activity of a version of the named template,
+// as named by its Tag.
+// A blank Template or Comment leaves that part of the version as it was.
+// Setting Active makes the version the template's active one; clearing it leaves the template's activity unchanged,
+// as a template always has an active version.
+func (mg *MailgunImpl) UpdateTemplateVersion(ctx context.Context, templateName string, v *TemplateVersion) error {
+	if templateName == "" || v.Tag == "" {
+		return ErrEmptyParam
+	}
+	r := newHTTPRequest(generateTemplateUrl(mg, templateName) + "/versions/" + url.PathEscape(v.Tag))
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+
+	p := newUrlEncodedPayload()
+	if v.Template != "" {
+		p.addValue("template", v.Template)
+	}
+	if v.Comment != "" {
+		p.addValue("comment", v.Comment)
+	}
+	if v.Active {
+		p.addValue("active", yesNo(true))
+	}
+	_, err := makePutRequest(r, p)
+	return err
+}
+
+// DeleteTemplateVersion removes one version of the named template, by tag.
+func (mg *MailgunImpl) DeleteTemplateVersion(ctx context.Context, templateName, tag string) error {
+	if templateName == "" || tag == "" {
+		return ErrEmptyParam
+	}
+	r := newHTTPRequest(generateTemplateUrl(mg, templateName) + "/versions/" + url.PathEscape(tag))
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	_, err := makeDeleteRequest(r)
+	return err
+}
+
+// ListTemplateVersions returns every version of the named template, fetching as many pages as it takes.
+// Mailgun leaves out the versions' content; use GetTemplateVersion for that.
+func (mg *MailgunImpl) ListTemplateVersions(ctx context.Context, templateName string) ([]TemplateVersion, error) {
+	if templateName == "" {
+		return nil, ErrEmptyParam
+	}
+	var versions []TemplateVersion
+	next := generateTemplateUrl(mg, templateName) + "/versions?limit=" + strconv.Itoa(templatesPageSize)
+	for next != "" {
+		r := newHTTPRequest(next)
+		r.setContext(ctx)
+		r.setClient(mg)
+		r.setBasicAuth(basicAuthUser, mg.ApiKey())
+		var envelope struct {
+			Template struct {
+				Versions []TemplateVersion `json:"versions"`
+			} `json:"template"`
+			Paging templatePaging `json:"paging"`
+		}
+		if err := getResponseFromJSON(r, &envelope); err != nil {
+			return nil, err
+		}
+		if len(envelope.Template.Versions) == 0 {
+			break
+		}
+		versions = append(versions, envelope.Template.Versions...)
+		next = envelope.Paging.Next
+	}
+	return versions, nil
+}
+
+// addTemplateVersionValues adds the fields of a new template version to a payload.
+func addTemplateVersionValues(p *urlEncodedPayload, v *TemplateVersion) {
+	p.addValue("template", v.Template)
+	if v.Tag != "" {
+		p.addValue("tag", v.Tag)
+	}
+	if v.Engine != "" {
+		p.addValue("engine", string(v.Engine))
+	}
+	if v.Comment != "" {
+		p.addValue("comment", v.Comment)
+	}
+	if v.Active {
+		p.addValue("active", yesNo(true))
+	}
+}
+
+// generateTemplateUrl generates the URL of one of the client domain's stored templates.
+func generateTemplateUrl(m Mailgun, name string) string {
+	return generateApiUrl(m, templatesEndpoint) + "/" + url.PathEscape(name)
+}
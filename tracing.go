@@ -0,0 +1,60 @@
+package mailgun
+
+import (
+	"net/http"
+	"strings"
+)
+
+// topLevelEndpoints lists the endpoints whose URLs don't begin with a domain.
+var topLevelEndpoints = map[string]bool{
+	domainsEndpoint:   true,
+	listsEndpoint:     true,
+	routesEndpoint:    true,
+	"address":         true,
+	ipsEndpoint:       true,
+	ipPoolsEndpoint:   true,
+	"dkim_management": true,
+	"accounts":        true,
+}
+
+// spanOperation names the API call a request makes, for tracing (see WithTracer, under the otel build tag),
+// and gives the domain it concerns, if any.
+// Sends are named "send", or "sendMime" for MIME messages, and event listings "listEvents";
+// other calls are named for their HTTP method and the endpoint they address, as in "getBounces" or "putTracking".
+func spanOperation(req *http.Request) (operation, domain string) {
+	segs := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	// Skip the API version, and anything before it, such as the path prefix of a mock server.
+	for i, seg := range segs {
+		if len(seg) > 1 && seg[0] == 'v' && strings.Trim(seg[1:], "0123456789") == "" {
+			segs = segs[i+1:]
+			break
+		}
+	}
+	if len(segs) == 0 || segs[0] == "" {
+		return strings.ToLower(req.Method), ""
+	}
+
+	endpoint := segs[0]
+	switch {
+	case segs[0] == domainsEndpoint && len(segs) > 2:
+		domain, endpoint = segs[1], segs[2]
+	case segs[0] == domainsEndpoint && len(segs) == 2:
+		domain = segs[1]
+	case !topLevelEndpoints[segs[0]] && len(segs) > 1:
+		domain, endpoint = segs[0], segs[1]
+	}
+
+	switch {
+	case req.Method == http.MethodPost && endpoint == messagesEndpoint:
+		return "send", domain
+	case req.Method == http.MethodPost && endpoint == messagesEndpoint+".mime":
+		return "sendMime", domain
+	case req.Method == http.MethodGet && endpoint == eventsEndpoint:
+		return "listEvents", domain
+	}
+	operation = strings.ToLower(req.Method)
+	for _, word := range strings.FieldsFunc(endpoint, func(r rune) bool { return r == '_' || r == '.' || r == '-' }) {
+		operation += strings.ToUpper(word[:1]) + word[1:]
+	}
+	return operation, domain
+}
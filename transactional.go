@@ -0,0 +1,64 @@
+package mailgun
+
+import (
+	"context"
+	"fmt"
+)
+
+// A TransactionalSpec describes a transactional message, such as a password reset or an
+// appointment reminder, which regulations may require to reach the recipient regardless
+// of their marketing preferences.
+//
+// RequiredHeaders lists the compliance headers the message must carry; each needs a value.
+// ComplianceTag is attached to the message as a tag, so that its events may be audited later.
+//
+// Mailgun offers no way to send past its suppression lists: recipients who have unsubscribed,
+// bounced, or complained remain suppressed.  BypassOptOut instead disables tracking for the message,
+// so that Mailgun neither inserts an unsubscribe link nor records the message against the
+// recipient's tracking preferences.
+type TransactionalSpec struct {
+	*Message
+	BypassOptOut    bool
+	RequiredHeaders map[string]string
+	ComplianceTag   string
+}
+
+// CreateTransactionalMessage applies the compliance settings of the spec to its message,
+// and then sends it.
+// It returns an error without sending anything if the spec lacks a message, a compliance tag,
+// or a value for any of its required headers.
+func (mg *MailgunImpl) CreateTransactionalMessage(ctx context.Context, spec TransactionalSpec) (string, string, error) {
+	if err := spec.validate(); err != nil {
+		return "", "", err
+	}
+
+	for header, value := range spec.RequiredHeaders {
+		spec.AddHeader(header, value)
+	}
+	if err := spec.AddTag(spec.ComplianceTag); err != nil {
+		return "", "", err
+	}
+	if spec.BypassOptOut {
+		spec.SetTracking(false)
+	}
+	return mg.Send(ctx, spec.Message)
+}
+
+// validate ensures the spec carries everything needed for a compliant send.
+func (spec TransactionalSpec) validate() error {
+	if spec.Message == nil {
+		return fmt.Errorf("transactional spec has no message")
+	}
+	if spec.ComplianceTag == "" {
+		return fmt.Errorf("transactional spec has no compliance tag")
+	}
+	if len(spec.RequiredHeaders) == 0 {
+		return fmt.Errorf("transactional spec has no required headers")
+	}
+	for header, value := range spec.RequiredHeaders {
+		if value == "" {
+			return fmt.Errorf("required header %s has no value", header)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,13 @@
 package mailgun
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
 	"strconv"
 )
 
@@ -21,7 +28,7 @@ func (mg *MailgunImpl) GetUnsubscribes(limit, skip int) (int, []Unsubscription,
 	if skip != DefaultSkip {
 		r.addParameter("skip", strconv.Itoa(skip))
 	}
-	r.setClient(mg.Client())
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	var envelope struct {
 		TotalCount int              `json:"total_count"`
@@ -35,7 +42,7 @@ func (mg *MailgunImpl) GetUnsubscribes(limit, skip int) (int, []Unsubscription,
 // Zero is a valid list length.
 func (mg *MailgunImpl) GetUnsubscribesByAddress(a string) (int, []Unsubscription, error) {
 	r := newHTTPRequest(generateApiUrlWithTarget(mg, unsubscribesEndpoint, a))
-	r.setClient(mg.Client())
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	var envelope struct {
 		TotalCount int              `json:"total_count"`
@@ -48,7 +55,7 @@ func (mg *MailgunImpl) GetUnsubscribesByAddress(a string) (int, []Unsubscription
 // Unsubscribe adds an e-mail address to the domain's unsubscription table.
 func (mg *MailgunImpl) Unsubscribe(a, t string) error {
 	r := newHTTPRequest(generateApiUrl(mg, unsubscribesEndpoint))
-	r.setClient(mg.Client())
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newUrlEncodedPayload()
 	p.addValue("address", a)
@@ -57,13 +64,97 @@ func (mg *MailgunImpl) Unsubscribe(a, t string) error {
 	return err
 }
 
+// unsubscriptionImport is the form Mailgun takes unsubscriptions in, when importing many at once.
+type unsubscriptionImport struct {
+	Address   string   `json:"address"`
+	Tags      []string `json:"tags,omitempty"`
+	CreatedAt string   `json:"created_at,omitempty"`
+}
+
+// ImportUnsubscribes adds many e-mail addresses to the domain's unsubscription table at once,
+// posting them to Mailgun as JSON in batches of up to 1000, the most Mailgun accepts per call.
+// Each unsubscription needs at least its Address set; leave Tag blank to unsubscribe the address from everything.
+// CreatedAt, if set, must be in RFC-2822 form.  IDs are ignored.
+// If a batch fails, the unsubscriptions of earlier batches remain in place.
+func (mg *MailgunImpl) ImportUnsubscribes(ctx context.Context, unsubscribes []Unsubscription) error {
+	for start := 0; start < len(unsubscribes); start += maxImportBatchSize {
+		end := start + maxImportBatchSize
+		if end > len(unsubscribes) {
+			end = len(unsubscribes)
+		}
+		batch := make([]unsubscriptionImport, 0, end-start)
+		for _, u := range unsubscribes[start:end] {
+			item := unsubscriptionImport{Address: u.Address, CreatedAt: u.CreatedAt}
+			if u.Tag != "" {
+				item.Tags = []string{u.Tag}
+			}
+			batch = append(batch, item)
+		}
+		if err := importSuppressions(ctx, mg, unsubscribesEndpoint, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportUnsubscribes downloads the named domain's unsubscriptions as CSV, in the manner of ExportBounces.
+// The caller must close the reader returned.
+func (mg *MailgunImpl) ExportUnsubscribes(ctx context.Context, domain string) (io.ReadCloser, error) {
+	return exportSuppressions(ctx, mg, domain, unsubscribesEndpoint)
+}
+
+// WriteUnsubscribesCSV works as ExportUnsubscribes, but copies the CSV into w.
+func (mg *MailgunImpl) WriteUnsubscribesCSV(ctx context.Context, domain string, w io.Writer) error {
+	return writeSuppressionsCSV(ctx, mg, domain, unsubscribesEndpoint, w)
+}
+
 // RemoveUnsubscribe removes the e-mail address given from the domain's unsubscription table.
 // If passing in an ID (discoverable from, e.g., GetUnsubscribes()), the e-mail address associated
 // with the given ID will be removed.
 func (mg *MailgunImpl) RemoveUnsubscribe(a string) error {
 	r := newHTTPRequest(generateApiUrlWithTarget(mg, unsubscribesEndpoint, a))
-	r.setClient(mg.Client())
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	_, err := makeDeleteRequest(r)
 	return err
 }
+
+// GetUnsubscribeURL builds a one-click unsubscribe link for the address, for use with an unsubscribe page you host yourself.
+// The link points to https://domain/unsubscribe, and carries the domain, address, and tag (which may be empty)
+// as query parameters, along with a signature made with the client's API key.
+// Mailgun doesn't publish how its own hosted unsubscribe pages sign their links, so these links work only with your page:
+// have it check the link with VerifyUnsubscribeURL, then call Unsubscribe.
+// Rewrite the scheme, host, or path of the link as your page requires; the signature covers only the query parameters.
+func (mg *MailgunImpl) GetUnsubscribeURL(domain, address, tag string) string {
+	q := url.Values{}
+	q.Set("domain", domain)
+	q.Set("address", address)
+	q.Set("tag", tag)
+	q.Set("signature", mg.unsubscribeSignature(domain, address, tag))
+	u := url.URL{Scheme: "https", Host: domain, Path: "/unsubscribe", RawQuery: q.Encode()}
+	return u.String()
+}
+
+// VerifyUnsubscribeURL checks that a link built by GetUnsubscribeURL hasn't been tampered with,
+// and returns the domain, address, and tag it carries.
+// An error results if the link cannot be parsed, or if its signature doesn't match.
+func (mg *MailgunImpl) VerifyUnsubscribeURL(rawurl string) (domain, address, tag string, err error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", "", "", err
+	}
+	q := u.Query()
+	domain, address, tag = q.Get("domain"), q.Get("address"), q.Get("tag")
+	expected := mg.unsubscribeSignature(domain, address, tag)
+	if address == "" || !hmac.Equal([]byte(q.Get("signature")), []byte(expected)) {
+		return "", "", "", fmt.Errorf("invalid unsubscribe link")
+	}
+	return domain, address, tag, nil
+}
+
+// unsubscribeSignature computes the HMAC-SHA256, keyed with the API key, of the fields of an unsubscribe link.
+func (mg *MailgunImpl) unsubscribeSignature(domain, address, tag string) string {
+	mac := hmac.New(sha256.New, []byte(mg.ApiKey()))
+	fmt.Fprintf(mac, "%s\n%s\n%s", domain, address, tag)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -1,13 +1,117 @@
 package mailgun
 
-// GetWebhooks returns the complete set of webhooks configured for your domain.
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+)
+
+// A WebhookEvent names the kind of event a webhook reports.
+type WebhookEvent string
+
+// The kinds of event Mailgun can report through webhooks.
+// EventFailed covers permanent failures, after which Mailgun gives up on delivery;
+// EventTemporaryFailed covers failures Mailgun will retry.
+const (
+	EventClicked         WebhookEvent = "clicked"
+	EventComplained      WebhookEvent = "complained"
+	EventDelivered       WebhookEvent = "delivered"
+	EventFailed          WebhookEvent = "permanent_fail"
+	EventTemporaryFailed WebhookEvent = "temporary_fail"
+	EventOpened          WebhookEvent = "opened"
+	EventUnsubscribed    WebhookEvent = "unsubscribed"
+)
+
+// A WebhookConfig lists the URLs registered for each kind of webhook on a domain.
+// Mailgun's legacy webhook names map onto the same fields:
+// deliver onto Delivered, bounce onto Bounced, click onto Clicked, open onto Opened,
+// unsubscribe onto Unsubscribed, spam onto Complained, and drop onto PermanentFail.
+type WebhookConfig struct {
+	Delivered     []string
+	Bounced       []string
+	Clicked       []string
+	Opened        []string
+	Unsubscribed  []string
+	Complained    []string
+	PermanentFail []string
+	TemporaryFail []string
+}
+
+// AllURLs returns every URL registered for any webhook, each appearing only once,
+// in the order of the fields of WebhookConfig.
+// This is useful when allowing Mailgun through a firewall, for example.
+func (c *WebhookConfig) AllURLs() []string {
+	var all []string
+	seen := make(map[string]bool)
+	lists := [][]string{
+		c.Delivered, c.Bounced, c.Clicked, c.Opened,
+		c.Unsubscribed, c.Complained, c.PermanentFail, c.TemporaryFail,
+	}
+	for _, urls := range lists {
+		for _, u := range urls {
+			if !seen[u] {
+				seen[u] = true
+				all = append(all, u)
+			}
+		}
+	}
+	return all
+}
+
+// field locates the list of URLs for the named webhook, or returns nil for unknown names.
+func (c *WebhookConfig) field(name string) *[]string {
+	switch name {
+	case "delivered", "deliver":
+		return &c.Delivered
+	case "bounce":
+		return &c.Bounced
+	case "clicked", "click":
+		return &c.Clicked
+	case "opened", "open":
+		return &c.Opened
+	case "unsubscribed", "unsubscribe":
+		return &c.Unsubscribed
+	case "complained", "spam":
+		return &c.Complained
+	case "permanent_fail", "drop":
+		return &c.PermanentFail
+	case "temporary_fail":
+		return &c.TemporaryFail
+	}
+	return nil
+}
+
+// webhookSettings decodes one webhook as Mailgun reports it: with a list of URLs in v3,
+// or with a single URL in the legacy form.
+type webhookSettings struct {
+	Url  string   `json:"url"`
+	Urls []string `json:"urls"`
+}
+
+// first gives the first URL registered for the webhook, or "" if none is.
+func (w webhookSettings) first() string {
+	if len(w.Urls) > 0 {
+		return w.Urls[0]
+	}
+	return w.Url
+}
+
+// GetWebhooks returns the complete set of webhooks configured for your domain,
+// giving the first URL registered for each; see GetDomainWebhooks for them all.
 // Note that a zero-length mapping is not an error.
 func (mg *MailgunImpl) GetWebhooks() (map[string]string, error) {
 	r := newHTTPRequest(generateDomainApiUrl(mg, webhooksEndpoint))
-	r.setClient(mg.Client())
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	var envelope struct {
-		Webhooks map[string]interface{} `json:"webhooks"`
+		Webhooks map[string]webhookSettings `json:"webhooks"`
 	}
 	err := getResponseFromJSON(r, &envelope)
 	hooks := make(map[string]string, 0)
@@ -15,29 +119,39 @@ func (mg *MailgunImpl) GetWebhooks() (map[string]string, error) {
 		return hooks, err
 	}
 	for k, v := range envelope.Webhooks {
-		object := v.(map[string]interface{})
-		url := object["url"]
-		hooks[k] = url.(string)
+		hooks[k] = v.first()
 	}
 	return hooks, nil
 }
 
 // CreateWebhook installs a new webhook for your domain.
 func (mg *MailgunImpl) CreateWebhook(t, u string) error {
-	r := newHTTPRequest(generateDomainApiUrl(mg, webhooksEndpoint))
-	r.setClient(mg.Client())
+	return mg.CreateDomainWebhook(context.Background(), mg.Domain(), WebhookEvent(t), u)
+}
+
+// CreateDomainWebhook installs a new webhook for the named domain.
+func (mg *MailgunImpl) CreateDomainWebhook(ctx context.Context, domain string, event WebhookEvent, url string) error {
+	r := newHTTPRequest(generateDomainApiUrlWithDomain(mg, domain, webhooksEndpoint))
+	r.setContext(ctx)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newUrlEncodedPayload()
-	p.addValue("id", t)
-	p.addValue("url", u)
+	p.addValue("id", string(event))
+	p.addValue("url", url)
 	_, err := makePostRequest(r, p)
 	return err
 }
 
 // DeleteWebhook removes the specified webhook from your domain's configuration.
 func (mg *MailgunImpl) DeleteWebhook(t string) error {
-	r := newHTTPRequest(generateDomainApiUrl(mg, webhooksEndpoint) + "/" + t)
-	r.setClient(mg.Client())
+	return mg.DeleteDomainWebhook(context.Background(), mg.Domain(), WebhookEvent(t))
+}
+
+// DeleteDomainWebhook removes the specified webhook from the named domain's configuration.
+func (mg *MailgunImpl) DeleteDomainWebhook(ctx context.Context, domain string, event WebhookEvent) error {
+	r := newHTTPRequest(generateDomainApiUrlWithDomain(mg, domain, webhooksEndpoint) + "/" + string(event))
+	r.setContext(ctx)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	_, err := makeDeleteRequest(r)
 	return err
@@ -45,25 +159,159 @@ func (mg *MailgunImpl) DeleteWebhook(t string) error {
 
 // GetWebhookByType retrieves the currently assigned webhook URL associated with the provided type of webhook.
 func (mg *MailgunImpl) GetWebhookByType(t string) (string, error) {
-	r := newHTTPRequest(generateDomainApiUrl(mg, webhooksEndpoint) + "/" + t)
-	r.setClient(mg.Client())
+	return mg.GetDomainWebhook(context.Background(), mg.Domain(), WebhookEvent(t))
+}
+
+// GetDomainWebhook retrieves the webhook URL the named domain has assigned to the provided kind of event,
+// or the first of them, if it has several.
+func (mg *MailgunImpl) GetDomainWebhook(ctx context.Context, domain string, event WebhookEvent) (string, error) {
+	r := newHTTPRequest(generateDomainApiUrlWithDomain(mg, domain, webhooksEndpoint) + "/" + string(event))
+	r.setContext(ctx)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	var envelope struct {
-		Webhook struct {
-			Url string `json:"url"`
-		} `json:"webhook"`
+		Webhook webhookSettings `json:"webhook"`
 	}
 	err := getResponseFromJSON(r, &envelope)
-	return envelope.Webhook.Url, err
+	return envelope.Webhook.first(), err
 }
 
 // UpdateWebhook replaces one webhook setting for another.
 func (mg *MailgunImpl) UpdateWebhook(t, u string) error {
-	r := newHTTPRequest(generateDomainApiUrl(mg, webhooksEndpoint) + "/" + t)
-	r.setClient(mg.Client())
+	return mg.UpdateDomainWebhook(context.Background(), mg.Domain(), WebhookEvent(t), u)
+}
+
+// UpdateDomainWebhook replaces the URL the named domain has assigned to the provided kind of event.
+func (mg *MailgunImpl) UpdateDomainWebhook(ctx context.Context, domain string, event WebhookEvent, url string) error {
+	r := newHTTPRequest(generateDomainApiUrlWithDomain(mg, domain, webhooksEndpoint) + "/" + string(event))
+	r.setContext(ctx)
+	r.setClient(mg)
 	r.setBasicAuth(basicAuthUser, mg.ApiKey())
 	p := newUrlEncodedPayload()
-	p.addValue("url", u)
+	p.addValue("url", url)
 	_, err := makePutRequest(r, p)
 	return err
 }
+
+// GetDomainWebhooks returns the complete webhook configuration of the named domain.
+// Unlike GetWebhooks, it reports every URL registered for a webhook, not just the first.
+// Webhooks of kinds unknown to WebhookConfig are ignored.
+func (mg *MailgunImpl) GetDomainWebhooks(ctx context.Context, domain string) (*WebhookConfig, error) {
+	r := newHTTPRequest(generatePublicApiUrl(mg, domainsEndpoint) + "/" + domain + "/" + webhooksEndpoint)
+	r.setContext(ctx)
+	r.setClient(mg)
+	r.setBasicAuth(basicAuthUser, mg.ApiKey())
+	var envelope struct {
+		Webhooks map[string]webhookSettings `json:"webhooks"`
+	}
+	if err := getResponseFromJSON(r, &envelope); err != nil {
+		return nil, err
+	}
+
+	config := &WebhookConfig{}
+	for name, hook := range envelope.Webhooks {
+		urls := config.field(name)
+		if urls == nil {
+			continue
+		}
+		if hook.Urls != nil {
+			*urls = append(*urls, hook.Urls...)
+		} else if hook.Url != "" {
+			*urls = append(*urls, hook.Url)
+		}
+	}
+	return config, nil
+}
+
+// ErrInvalidWebhookSignature results when a webhook request doesn't carry a valid signature.
+var ErrInvalidWebhookSignature = fmt.Errorf("invalid webhook signature")
+
+// WebhookSignature holds the fields Mailgun signs each webhook request with.
+type WebhookSignature struct {
+	Timestamp string `json:"timestamp"`
+	Token     string `json:"token"`
+	Signature string `json:"signature"`
+}
+
+// WebhookPayload holds the body of a webhook request, as Mailgun posts it:
+// the signature, and the event being reported.
+type WebhookPayload struct {
+	Signature WebhookSignature `json:"signature"`
+	EventData Event            `json:"event-data"`
+}
+
+// VerifyWebhookSignature reports whether the signature Mailgun attached to a webhook request is genuine.
+// The apiKey parameter takes the key Mailgun signs webhooks with.
+// Mailgun computes the signature as the hex-encoded HMAC-SHA256, keyed with that key,
+// of the timestamp followed by the token.
+func VerifyWebhookSignature(apiKey, token, timestamp, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(apiKey))
+	mac.Write([]byte(timestamp + token))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// ParseWebhookPayload decodes the JSON body of a webhook request,
+// checking its signature against the client's API key.
+// ErrInvalidWebhookSignature results if the signature isn't genuine.
+// Note that a genuine request may still be a replay; to guard against those,
+// check the timestamp is recent, and refuse tokens seen before.
+func (mg *MailgunImpl) ParseWebhookPayload(r *http.Request) (*WebhookPayload, error) {
+	var payload WebhookPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+	sig := payload.Signature
+	if !VerifyWebhookSignature(mg.ApiKey(), sig.Token, sig.Timestamp, sig.Signature) {
+		return nil, ErrInvalidWebhookSignature
+	}
+	return &payload, nil
+}
+
+// WebhookMiddleware guards a handler for webhook requests, or for the inbound messages a route's forward() action posts,
+// by checking each request's signature against apiKey before passing the request on to next.
+// It understands both the JSON bodies of Mailgun's webhooks and the form-encoded bodies
+// of its legacy webhooks and of forwarded messages, telling them apart by their Content-Type.
+// A request without a genuine signature is refused with 403 Forbidden and a body of {"error":"invalid signature"},
+// and never reaches next.
+// The request next sees can be read again as if fresh: a JSON body is buffered,
+// and a form, once parsed, stays parsed, so ParseWebhookPayload and ParseInboundMessage work as ever.
+// As with those, a genuine request may still be a replay.
+func WebhookMiddleware(apiKey string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !verifyWebhookRequest(apiKey, w, r) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":"invalid signature"}`))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// verifyWebhookRequest reports whether a webhook request carries a genuine signature,
+// leaving its body for the handler to read.  Requests whose body can't be read or decoded don't.
+func verifyWebhookRequest(apiKey string, w http.ResponseWriter, r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		if err := r.ParseMultipartForm(inboundMaxMemory); err != nil && err != http.ErrNotMultipart {
+			return false
+		}
+		return VerifyWebhookSignature(apiKey, r.FormValue("token"), r.FormValue("timestamp"), r.FormValue("signature"))
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, inboundMaxMemory))
+	r.Body.Close()
+	if err != nil {
+		return false
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	var payload struct {
+		Signature WebhookSignature `json:"signature"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return false
+	}
+	sig := payload.Signature
+	return VerifyWebhookSignature(apiKey, sig.Token, sig.Timestamp, sig.Signature)
+}